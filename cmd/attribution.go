@@ -0,0 +1,125 @@
+/*
+Copyright © 2021 willbenica
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/willbenica/lf-cli/internal"
+)
+
+var (
+	// attributionBy is the view of the report: channel or lead
+	attributionBy string
+	// halfLife is the half-life in days of the time decay model
+	halfLife float64
+)
+
+// attributionCmd represents the attribution command
+var attributionCmd = &cobra.Command{
+	Use:   "attribution <campaigns|sources|mediums> [file...]",
+	Short: "Credit campaigns, sources or mediums with the leads they brought in",
+	Long: `Order each lead's visits by when they started and credit the campaigns,
+sources or mediums of the visits with the lead, using four models:
+  first_touch  all credit to the first visit
+  last_touch   all credit to the last visit
+  linear       equal credit to every visit
+  time_decay   credit halving with every --half-life days a visit is older
+               than the lead's last visit
+
+--by channel (the default) sums the credits over all leads, so each column
+adds up to the number of leads. --by lead prints the credits of every lead.
+
+The visits are read from files written by 'lf-cli get visits', or requested
+for --start-date to --end-date if no files are given. Lead names are taken
+from leads files, with --by lead the leads are requested too:
+  lf-cli attribution sources -s 2021-05-01 -e 2021-05-31
+  lf-cli attribution campaigns visits_*.json leads_*.json --by lead -o csv
+  lf-cli attribution mediums visits_*.json --half-life 3 -o json`,
+	ValidArgs: internal.AttributionDimensions,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(args) == 0 {
+			return errors.New("a dimension is required: " + strings.Join(internal.AttributionDimensions, ", "))
+		}
+		if !internal.IsValidAttributionDimension(args[0]) {
+			return fmt.Errorf("invalid dimension %q, use %s", args[0], strings.Join(internal.AttributionDimensions, ", "))
+		}
+		return nil
+	},
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := validateReportFlags(); err != nil {
+			return err
+		}
+		if attributionBy != internal.AttributionByChannel && attributionBy != internal.AttributionByLead {
+			return fmt.Errorf("invalid value %q for --by, use channel or lead", attributionBy)
+		}
+		if halfLife <= 0 {
+			return errors.New("--half-life must be positive")
+		}
+		return setFilter("visits")
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// Leads are only requested for their names
+		endpoints := []string{"visits"}
+		if attributionBy == internal.AttributionByLead {
+			endpoints = append(endpoints, "leads")
+		}
+		ds, err := loadDataset(args[1:], endpoints...)
+		if err != nil {
+			return err
+		}
+		if ds, err = prepareDataset(ds); err != nil {
+			return err
+		}
+		attributions, err := internal.Attribute(ds.Leads, ds.Visits, args[0], halfLife)
+		if err != nil {
+			return err
+		}
+		if attributionBy == internal.AttributionByLead {
+			internal.RoundCredits(attributions)
+			if reportTop > 0 && len(attributions) > reportTop {
+				attributions = attributions[:reportTop]
+			}
+			err = writeReport(internal.LeadAttributionTable(attributions, args[0]), attributions)
+		} else {
+			channels := internal.ByChannel(attributions)
+			if reportTop > 0 && len(channels) > reportTop {
+				channels = channels[:reportTop]
+			}
+			err = writeReport(internal.ChannelAttributionTable(channels, args[0]), channels)
+		}
+		if err != nil {
+			return err
+		}
+		reportFiltered()
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(attributionCmd)
+	attributionCmd.Flags().SortFlags = false
+
+	attributionCmd.Flags().StringVar(&attributionBy, "by", internal.AttributionByChannel, "Report the credits per channel or per lead")
+	attributionCmd.Flags().Float64Var(&halfLife, "half-life", internal.DefaultHalfLifeDays, "Half-life in days of the time decay model")
+	addRangeFlags(attributionCmd)
+	addReportFlags(attributionCmd)
+	addWhereFlag(attributionCmd)
+}
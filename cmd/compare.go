@@ -0,0 +1,168 @@
+/*
+Copyright © 2021 willbenica
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/willbenica/lf-cli/internal"
+)
+
+// defaultCompareTop is how many sources and pages compare shows without --top
+const defaultCompareTop = 5
+
+var (
+	// comparePeriod is the length of the windows: week or month
+	comparePeriod string
+	// compareAsOf is the last day of the current window
+	compareAsOf string
+	// compareCacheDir keeps the data of complete windows so they are only requested once
+	compareCacheDir string
+)
+
+// compareCmd represents the compare command
+var compareCmd = &cobra.Command{
+	Use:   "compare --period <week|month> [file...]",
+	Short: "Compare this week or month with the one before",
+	Long: `Compare the week (starting on Monday) or month up to --as-of with the same
+days of the week or month before, e.g. Monday to Wednesday of this week with
+Monday to Wednesday of last week. Pass the last day of a week or month to
+compare whole periods.
+
+For new leads (first visit in the window), visits, unique companies (leads
+with visits), the average lead quality, and the visits of the top sources and
+views of the top pages, compare prints both values, the change and the change
+in percent.
+
+Leads, locations and visits are read from files written by 'lf-cli get' and
+split into the two windows, or requested for each window if no files are
+given. --cache-dir keeps the data of windows which have ended, so comparing
+again only requests the current window:
+  lf-cli compare --period week
+  lf-cli compare --period month --as-of 2021-05-31 --cache-dir ~/.cache/lf-cli
+  lf-cli compare --period week leads_*.json visits_*.json -o json`,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := validateReportFlags(); err != nil {
+			return err
+		}
+		if comparePeriod != internal.PeriodWeek && comparePeriod != internal.PeriodMonth {
+			return fmt.Errorf("invalid value %q for --period, use week or month", comparePeriod)
+		}
+		if _, err := compareDate(); err != nil {
+			return err
+		}
+		if compareCacheDir != "" && len(args) > 0 {
+			return errors.New("--cache-dir only applies to requested data, not to files")
+		}
+		return nil
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		asOf, _ := compareDate()
+		previousWindow, currentWindow, err := internal.CompareWindows(comparePeriod, asOf)
+		if err != nil {
+			return err
+		}
+		var previous, current internal.Dataset
+		if len(args) > 0 {
+			ds, err := internal.ReadDataFiles(args...)
+			if err != nil {
+				return err
+			}
+			previous, current = previousWindow.Select(ds), currentWindow.Select(ds)
+		} else {
+			if previous, err = windowDataset(previousWindow); err != nil {
+				return err
+			}
+			if current, err = windowDataset(currentWindow); err != nil {
+				return err
+			}
+		}
+		top := reportTop
+		if !cmd.Flags().Changed("top") {
+			top = defaultCompareTop
+		}
+		comparison := internal.Compare(comparePeriod, previousWindow, currentWindow, previous, current, top)
+		if reportOutput == reportTable {
+			fmt.Fprintf(os.Stderr, "Comparing %s to %s with %s to %s\n", currentWindow.Start, currentWindow.End, previousWindow.Start, previousWindow.End)
+		}
+		return writeReport(comparison.Table(), comparison)
+	},
+}
+
+// compareDate parses --as-of
+func compareDate() (time.Time, error) {
+	t, err := time.Parse("2006-01-02", internal.TodayOrDate(compareAsOf))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q for --as-of, use YYYY-MM-DD or today", compareAsOf)
+	}
+	return t, nil
+}
+
+// windowDataset requests the leads and visits of a window, or reads them from
+// --cache-dir. Windows which have ended are written to the cache.
+func windowDataset(w internal.Window) (internal.Dataset, error) {
+	if compareCacheDir == "" {
+		return fetchDataset(w.Start, w.End, "leads", "visits")
+	}
+	name := fmt.Sprintf("%s_%s_%s_%s.json", cacheHost(baseURL), accountID, w.Start, w.End)
+	path := filepath.Join(compareCacheDir, name)
+	if _, err := os.Stat(path); err == nil {
+		return internal.ReadDataFiles(path)
+	}
+	ds, err := fetchDataset(w.Start, w.End, "leads", "visits")
+	if err != nil {
+		return internal.Dataset{}, err
+	}
+	if w.End < internal.TodayOrDate("today") {
+		data := internal.Leads{Data: ds.Leads}.GetAllData() + internal.Locations{Data: ds.Locations}.GetAllData() +
+			internal.Visits{Data: ds.Visits}.GetAllData()
+		// A partly written file must not be taken for the cached window later on
+		tmp := fmt.Sprintf("%s.%d.tmp", name, os.Getpid())
+		err := internal.WriteToFile(compareCacheDir, tmp, data)
+		if err == nil {
+			err = os.Rename(filepath.Join(compareCacheDir, tmp), path)
+		}
+		if err != nil {
+			os.Remove(filepath.Join(compareCacheDir, tmp))
+			return internal.Dataset{}, fmt.Errorf("could not cache the data of %s to %s: %w", w.Start, w.End, err)
+		}
+	}
+	return ds, nil
+}
+
+// cacheHost turns the base URL into a part of a file name, so data of
+// different servers is cached apart
+func cacheHost(url string) string {
+	host := strings.TrimSuffix(strings.TrimPrefix(strings.TrimPrefix(url, "https://"), "http://"), "/")
+	return strings.NewReplacer("/", "_", ":", "_").Replace(host)
+}
+
+func init() {
+	rootCmd.AddCommand(compareCmd)
+	compareCmd.Flags().SortFlags = false
+
+	compareCmd.Flags().StringVar(&comparePeriod, "period", internal.PeriodWeek, "Length of the windows: week or month")
+	compareCmd.Flags().StringVar(&compareAsOf, "as-of", "today", "Last day of the current window. Use YYYY-MM-DD or today")
+	compareCmd.Flags().StringVar(&compareCacheDir, "cache-dir", "", "Directory keeping the data of windows which have ended")
+	addReportFlags(compareCmd)
+}
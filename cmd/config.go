@@ -0,0 +1,243 @@
+/*
+Copyright © 2021 willbenica
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+	"github.com/willbenica/lf-cli/internal"
+)
+
+// configCmd represents the config command
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Inspect the configuration of lf-cli",
+	Long: `Every flag of lf-cli and of the get command can also be set with an environment
+variable, e.g. --start-date with LF_CLI_START_DATE. The connection settings use
+the names of the config file keys: LF_CLI_ACCOUNT, LF_CLI_TOKEN, LF_CLI_LF_URL
+and LF_CLI_PROFILE. Environment variables are read whether or not a config file exists.`,
+}
+
+// configShowCmd represents the config show command
+var configShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Print the effective configuration and where each value came from",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		file := viper.ConfigFileUsed()
+		if _, err := os.Stat(file); file == "" || err != nil {
+			file = "(none)"
+		}
+		fmt.Printf("config file: %s\n\n", file)
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "SETTING\tVALUE\tSOURCE\tENV")
+		for _, s := range []struct {
+			name string
+			env  string
+			internal.Setting
+		}{
+			{"profile", internal.EnvName("profile"), activeConfig.Profile},
+			{"account", internal.EnvName("account"), activeConfig.Account},
+			{"token", internal.EnvName("token"), maskedToken(activeConfig.Token)},
+			{"lf-url", internal.EnvName("lf-url"), activeConfig.BaseURL},
+			{"credential_file", "", activeConfig.CredentialFile},
+		} {
+			source := s.Source
+			if source == "" {
+				source = "unset"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", s.name, s.Value, source, s.env)
+		}
+
+		printFlag := func(f *pflag.Flag) {
+			if connectionFlags[f.Name] || f.Name == "help" {
+				return
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", f.Name, f.Value.String(), flagSource(f), internal.EnvName(f.Name))
+		}
+		rootCmd.PersistentFlags().VisitAll(printFlag)
+		fmt.Fprintln(w, "\t\t\t")
+		fmt.Fprintln(w, "GET FLAGS\t\t\t")
+		// Shows the values get would run with
+		bindFlagsToEnv(getCmd.Flags())
+		getCmd.Flags().VisitAll(printFlag)
+		return w.Flush()
+	},
+}
+
+var (
+	// noVerify skips checking the credentials when running config init
+	noVerify bool
+	// offline skips the network checks of config validate
+	offline bool
+)
+
+// configInitCmd represents the config init command
+var configInitCmd = &cobra.Command{
+	Use:   "init",
+	Short: "Create a config file by answering a few questions",
+	Long: `Prompt for the account ID and API token, check them against the leadfeeder API
+and write them to $HOME/.config/lf-cli/.lf-cli.yaml (or the file given with --config)
+with 0600 permissions. With --profile the values are written to that profile,
+other values in an existing file are kept.`,
+	Args: cobra.NoArgs,
+	// --profile may name the profile to create
+	Annotations: map[string]string{createsProfile: "true"},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path := cfgFile
+		if path == "" {
+			path = defaultConfigFile()
+		}
+
+		account, err := promptLine("Account ID", accountID)
+		if err != nil {
+			return err
+		}
+		if account == "" {
+			return errors.New("an account ID is required")
+		}
+		t, err := readToken()
+		if err != nil {
+			return err
+		}
+		url, err := promptLine("leadfeeder URL", baseURL)
+		if err != nil {
+			return err
+		}
+
+		if !noVerify {
+			fmt.Fprintln(os.Stderr, "Checking the account and token...")
+			if err := internal.CheckCredentials(url, t, account); err != nil {
+				return fmt.Errorf("the credentials could not be verified, the config file was not written: %w", err)
+			}
+		}
+
+		values := map[string]string{"account": account, "token": t}
+		if url != internal.DefaultBaseURL {
+			values["lf-url"] = url
+		}
+		if err := internal.WriteConfigFile(path, profile, values); err != nil {
+			return err
+		}
+		fmt.Printf("Config written to %s\n", path)
+		return nil
+	},
+}
+
+// configValidateCmd represents the config validate command
+var configValidateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Check the config file for unknown keys, missing values, insecure permissions and unreachable URLs",
+	Args:  cobra.NoArgs,
+	// The problems found are the output, usage would only hide them
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path := viper.ConfigFileUsed()
+		raw, err := ioutil.ReadFile(path)
+		if path == "" || err != nil {
+			return errors.New("no config file found, run 'lf-cli config init' to create one")
+		}
+
+		problems, err := internal.ValidateConfig(raw)
+		if err != nil {
+			return err
+		}
+		permissions, err := internal.CheckFilePermissions(path)
+		if err != nil {
+			return err
+		}
+		if permissions != nil {
+			problems = append(problems, *permissions)
+		}
+		if !offline {
+			problems = append(problems, checkBaseURLs()...)
+		}
+
+		fmt.Printf("config file: %s\n\n", path)
+		if len(problems) == 0 {
+			fmt.Println("No problems found")
+			return nil
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "LEVEL\tKEY\tPROBLEM")
+		errCount := 0
+		for _, p := range problems {
+			if p.Level == internal.LevelError {
+				errCount++
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\n", p.Level, p.Key, p.Message)
+		}
+		w.Flush()
+		if errCount > 0 {
+			return fmt.Errorf("found %d error(s) in %s", errCount, path)
+		}
+		return nil
+	},
+}
+
+// checkBaseURLs reports every lf-url of the config file that cannot be reached
+func checkBaseURLs() []internal.ConfigProblem {
+	urls := map[string]string{}
+	keys := []string{}
+	add := func(key string, url string) {
+		if url == "" {
+			url = internal.DefaultBaseURL
+		}
+		if _, ok := urls[url]; !ok {
+			urls[url] = key
+			keys = append(keys, url)
+		}
+	}
+	add("lf-url", configFile.BaseURL)
+	for _, name := range configFile.ProfileNames() {
+		p := configFile.Profiles[name]
+		if p.BaseURL != "" {
+			add("profiles."+name+".lf-url", p.BaseURL)
+		}
+	}
+
+	var problems []internal.ConfigProblem
+	for _, url := range keys {
+		if err := internal.CheckReachable(url, 10*time.Second); err != nil {
+			problems = append(problems, internal.ConfigProblem{
+				Level:   internal.LevelError,
+				Key:     urls[url],
+				Message: fmt.Sprintf("%s is unreachable: %s", url, err),
+			})
+		}
+	}
+	return problems
+}
+
+func init() {
+	rootCmd.AddCommand(configCmd)
+	configCmd.AddCommand(configShowCmd)
+	configCmd.AddCommand(configInitCmd)
+	configCmd.AddCommand(configValidateCmd)
+
+	configInitCmd.Flags().BoolVar(&noVerify, "no-verify", false, "Write the config file without checking the credentials against the API")
+	configValidateCmd.Flags().BoolVar(&offline, "offline", false, "Skip checking if the lf-url is reachable")
+}
@@ -0,0 +1,216 @@
+/*
+Copyright © 2021 willbenica
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package cmd
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/willbenica/lf-cli/internal"
+	"go.uber.org/zap"
+)
+
+var (
+	// convertOut is the file convert writes to, stdout if empty or -
+	convertOut string
+	// convertFormat is the format convert writes
+	convertFormat string
+	// convertVisitRows controls how visit routes are flattened for csv and tsv
+	convertVisitRows string
+)
+
+// convertCmd represents the convert command
+var convertCmd = &cobra.Command{
+	Use:   "convert <file> [file...]",
+	Short: "Convert saved leads or visits to another format, e.g. csv, without calling the API",
+	Long: `Convert files written by 'lf-cli get' (one record per line) or raw API
+responses to another format. To add the location to each lead, pass the
+locations file together with the leads file:
+  lf-cli convert leads_from_2021-05-01.json locations_from_2021-05-01.json --format csv
+
+Leads and visits have to be converted separately, except for --format sqlite
+and xlsx which merge all files into one database or workbook:
+  lf-cli convert leads_*.json locations_*.json visits_*.json --format sqlite -o data.db
+  lf-cli convert leads_*.json locations_*.json visits_*.json --format xlsx -o weekly.xlsx
+
+gzip and zstd compressed files are read transparently, so compressed exports
+can be re-emitted uncompressed, e.g. to pipe them into jq:
+  lf-cli convert visits_from_2021-05-01.json.zst --format json | jq .
+
+With --format json the locations follow the leads in the output. Parquet files
+are written with --output, the locations of leads go to a second file next to
+it, e.g. leads_locations.parquet:
+  lf-cli convert visits_from_2021-05-01.json --format parquet -o visits.parquet
+  lf-cli convert leads_*.json locations_*.json --format parquet -o leads.parquet`,
+	Args: cobra.MinimumNArgs(1),
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := validateFormatFlags(convertFormat, convertVisitRows); err != nil {
+			return err
+		}
+		if err := validateCompression(convertFormat); err != nil {
+			return err
+		}
+		// - names stdout like --output - of get
+		if convertOut == "-" {
+			convertOut = ""
+		}
+		return validateFieldsFormat(convertFormat)
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ds, err := internal.ReadDataFiles(args...)
+		if err != nil {
+			return err
+		}
+		// The kind of records is decided before --where, which may filter out all of them
+		hasLeads, hasVisits := len(ds.Leads) > 0, len(ds.Visits) > 0
+		if err := loadContentGroups(); err != nil {
+			return err
+		}
+		if contentGroups != nil {
+			contentGroups.Apply(ds.Visits)
+		}
+		if where != "" {
+			if hasLeads && hasVisits {
+				return errors.New("--where applies to either leads or visits, convert them separately")
+			}
+			endpoint := "locations"
+			switch {
+			case hasLeads:
+				endpoint = "leads"
+			case hasVisits:
+				endpoint = "visits"
+			}
+			if err := setFilter(endpoint); err != nil {
+				return err
+			}
+			if ds, err = filterDataset(ds); err != nil {
+				return err
+			}
+			defer reportFiltered()
+		}
+
+		if convertFormat == internal.FormatSQLite {
+			if convertOut == "" {
+				return errors.New("--format sqlite requires --output <database file>")
+			}
+			return internal.WriteSQLite(convertOut, ds)
+		}
+		if convertFormat == internal.FormatXLSX && len(fields) == 0 {
+			if convertOut == "" {
+				return errors.New("--format xlsx requires --output <workbook file>")
+			}
+			return internal.WriteXLSXFile(filepath.Dir(convertOut), filepath.Base(convertOut), ds)
+		}
+
+		var data interface{}
+		var locations internal.Locations
+		endpoint := "locations"
+		switch {
+		case hasLeads && hasVisits:
+			return errors.New("the files contain leads and visits, convert them separately")
+		case hasLeads:
+			data, locations, endpoint = internal.Leads{Data: ds.Leads}, internal.Locations{Data: ds.Locations}, "leads"
+		case hasVisits:
+			data, endpoint = internal.Visits{Data: ds.Visits}, "visits"
+		case len(ds.Locations) > 0:
+			data = internal.Locations{Data: ds.Locations}
+		default:
+			return errors.New("the files contain no records")
+		}
+		if len(fields) > 0 && endpoint == "locations" {
+			return errors.New("--fields applies to leads and visits")
+		}
+		if err := setProjection(endpoint, convertFormat); err != nil {
+			return err
+		}
+		if convertFormat == internal.FormatXLSX {
+			if convertOut == "" {
+				return errors.New("--format xlsx requires --output <workbook file>")
+			}
+			return writeWorkbook(filepath.Dir(convertOut), filepath.Base(convertOut), endpoint, ds)
+		}
+
+		if convertFormat == internal.FormatParquet {
+			if convertOut == "" {
+				return errors.New("--format parquet requires --output <file>")
+			}
+			if err := internal.WriteParquetFile(filepath.Dir(convertOut), filepath.Base(convertOut), data, parquetOptions()); err != nil {
+				return err
+			}
+			// Parquet files hold one kind of record, the locations of leads get their own file
+			if len(locations.Data) == 0 {
+				return nil
+			}
+			name := locationsFileName(convertOut)
+			logger.Info("Writing to file", zap.String("file", name))
+			return internal.WriteParquetFile(filepath.Dir(convertOut), name, locations, parquetOptions())
+		}
+
+		out, err := formatData(data, locations, convertFormat, convertVisitRows)
+		if err != nil {
+			return err
+		}
+		// The locations follow the leads like in the output of get, so the
+		// result can be converted again. Projected leads contain their location.
+		if convertFormat == internal.FormatJSON && projection == nil {
+			out += locations.GetAllData()
+		}
+
+		if convertOut == "" {
+			w, err := internal.NewCompressWriter(os.Stdout, compression)
+			if err != nil {
+				return err
+			}
+			if _, err := io.WriteString(w, out); err != nil {
+				return err
+			}
+			return w.Close()
+		}
+		if suffix := internal.CompressionSuffix(compression); !strings.HasSuffix(convertOut, suffix) {
+			convertOut += suffix
+		}
+		return internal.WriteCompressedFile(filepath.Dir(convertOut), filepath.Base(convertOut), out, compression)
+	},
+}
+
+// locationsFileName names the file next to out the locations of leads are
+// written to, e.g. leads_locations.parquet for leads.parquet
+func locationsFileName(out string) string {
+	base := filepath.Base(out)
+	ext := filepath.Ext(base)
+	return strings.TrimSuffix(base, ext) + "_locations" + ext
+}
+
+func init() {
+	rootCmd.AddCommand(convertCmd)
+	convertCmd.Flags().SortFlags = false
+
+	convertCmd.Flags().StringVar(&convertFormat, "format", internal.FormatCSV, "Output format: json, csv, tsv, sqlite, parquet or xlsx")
+	convertCmd.Flags().StringVar(&convertVisitRows, "visit-rows", internal.VisitRowsVisit, "How csv/tsv flatten visits: 'visit' (one row per visit, route summarised) or 'step' (one row per route step)")
+	convertCmd.Flags().StringVarP(&convertOut, "output", "o", "", "File to write to, - or none for stdout (the database with --format sqlite)")
+	convertCmd.Flags().StringVar(&convertOut, "out", "", "File to write to, defaults to stdout")
+	convertCmd.Flags().MarkDeprecated("out", "use --output instead")
+	addParquetFlags(convertCmd)
+	addCompressFlag(convertCmd)
+	addFieldsFlags(convertCmd)
+	addWhereFlag(convertCmd)
+	addContentGroupsFlag(convertCmd)
+}
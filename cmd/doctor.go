@@ -0,0 +1,83 @@
+/*
+Copyright © 2021 willbenica
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/willbenica/lf-cli/internal"
+)
+
+// doctorJSON prints the doctor report as JSON instead of a table
+var doctorJSON bool
+
+// doctorCmd represents the doctor command
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Diagnose connectivity and authentication problems",
+	Long: `Run a series of checks against the leadfeeder API using the same transport
+settings as 'get' (e.g. HTTPS_PROXY):
+  credentials  the token can be loaded, e.g. from the credential file
+  config       an account and token are configured
+  proxy        which proxy is used, if any
+  dns          the host of lf-url resolves
+  tls          a TLS handshake completes
+  auth         an authenticated request is accepted
+  account      the token has access to the account
+  clock        the local clock agrees with the server
+  rate-limit   there are requests left in the current rate-limit window
+
+The exit code is non-zero if any check fails, so doctor can be used in cron jobs.`,
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// A token that can't be loaded fails the credentials check, the checks
+		// which need no token still run
+		report := internal.RunDoctor(baseURL, token, accountID, loadToken())
+
+		if doctorJSON {
+			e := json.NewEncoder(os.Stdout)
+			e.SetIndent("", "  ")
+			if err := e.Encode(report); err != nil {
+				return err
+			}
+		} else {
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "STATUS\tCHECK\tDETAIL\tTIME")
+			for _, c := range report.Checks {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%dms\n", strings.ToUpper(c.Status), c.Name, c.Detail, c.DurationMs)
+			}
+			w.Flush()
+		}
+
+		if !report.OK {
+			return errors.New("at least one check failed")
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(doctorCmd)
+	doctorCmd.Flags().BoolVar(&doctorJSON, "json", false, "Print the report as JSON")
+}
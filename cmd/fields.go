@@ -0,0 +1,96 @@
+/*
+Copyright © 2021 willbenica
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/willbenica/lf-cli/internal"
+)
+
+var (
+	// fields are the dotted paths selected with --fields
+	fields []string
+	// rename maps selected paths to output names
+	rename map[string]string
+	// projection is built from --fields and --rename, nil writes whole records
+	projection *internal.Projection
+)
+
+// addFieldsFlags adds --fields and --rename to cmd
+func addFieldsFlags(cmd *cobra.Command) {
+	cmd.Flags().StringSliceVar(&fields, "fields", nil, "Write only these fields, as dotted paths, e.g. id,attributes.name,attributes.quality,location.city. Applies to json, csv, tsv and xlsx, sqlite and parquet keep their fixed schema")
+	cmd.Flags().StringToStringVar(&rename, "rename", nil, "Names of the fields selected with --fields in the output, e.g. attributes.name=company,location.city=city")
+}
+
+// setProjection builds projection from --fields and --rename for endpoint
+func setProjection(endpoint string, format string) error {
+	projection = nil
+	if len(fields) == 0 {
+		if len(rename) > 0 {
+			return errors.New("--rename requires --fields")
+		}
+		return nil
+	}
+	if err := validateFieldsFormat(format); err != nil {
+		return err
+	}
+	p, err := internal.NewProjection(endpoint, fields, rename)
+	if err != nil {
+		return err
+	}
+	projection = &p
+	return nil
+}
+
+// validateFieldsFormat rejects --fields for the fixed schemas of sqlite and parquet
+func validateFieldsFormat(format string) error {
+	if len(fields) > 0 && (format == internal.FormatSQLite || format == internal.FormatParquet) {
+		return fmt.Errorf("--fields applies to json, csv, tsv and xlsx, --format %s has a fixed schema", format)
+	}
+	return nil
+}
+
+// writeWorkbook writes the records of endpoint to a workbook, a single sheet
+// of the fields of projection if --fields is given
+func writeWorkbook(dir string, name string, endpoint string, ds internal.Dataset) error {
+	if projection == nil {
+		return internal.WriteXLSXFile(dir, name, ds)
+	}
+	records, err := projectData(primaryData(endpoint, ds))
+	if err != nil {
+		return err
+	}
+	sheet := internal.SheetVisits
+	if endpoint == "leads" {
+		sheet = internal.SheetLeads
+	}
+	return internal.WriteXLSXRecordsFile(dir, name, sheet, records, *projection)
+}
+
+// projectData selects the fields of projection from Leads or Visits
+func projectData(data interface{}, locations internal.Locations) ([]internal.Record, error) {
+	switch d := data.(type) {
+	case internal.Leads:
+		return internal.ProjectLeads(d.Data, locations.Data, *projection)
+	case internal.Visits:
+		return internal.ProjectVisits(d.Data, *projection)
+	}
+	return nil, fmt.Errorf("--fields can't be applied to %T", data)
+}
@@ -0,0 +1,116 @@
+/*
+Copyright © 2021 willbenica
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/willbenica/lf-cli/internal"
+)
+
+// geoJSON writes the breakdown as GeoJSON instead of a report
+var geoJSON bool
+
+// geoCmd represents the geo command
+var geoCmd = &cobra.Command{
+	Use:   "geo <countries|regions|cities> [file...]",
+	Short: "Summarise leads and visits by country, region or city",
+	Long: `Join leads to their locations and print the number of leads, visits and
+the total visit length of each country, region or city, the places with the
+most leads first. Leads without a location are counted as (none).
+
+--geojson writes a GeoJSON FeatureCollection instead, with a point per place
+and the numbers as properties. The points are the approximate centres of the
+countries, and of the regions of Australia, Canada, Germany, the United
+Kingdom and the United States, from a table built into lf-cli. Cities are
+placed at the centre of their region or country.
+
+Leads, locations and visits are read from files written by 'lf-cli get', or
+requested for --start-date to --end-date if no files are given:
+  lf-cli geo countries -s 2021-05-01 -e 2021-05-31
+  lf-cli geo cities leads_*.json locations_*.json visits_*.json --top 20
+  lf-cli geo regions -s 2021-05-01 --geojson > leads.geojson`,
+	ValidArgs: internal.GeoLevels,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(args) == 0 {
+			return errors.New("a level is required: " + strings.Join(internal.GeoLevels, ", "))
+		}
+		if !internal.IsValidGeoLevel(args[0]) {
+			return fmt.Errorf("invalid level %q, use %s", args[0], strings.Join(internal.GeoLevels, ", "))
+		}
+		return nil
+	},
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := validateReportFlags(); err != nil {
+			return err
+		}
+		if geoJSON && cmd.Flags().Changed("output") {
+			return errors.New("--geojson can't be combined with --output")
+		}
+		return setFilter("leads")
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ds, err := loadDataset(args[1:], "leads", "visits")
+		if err != nil {
+			return err
+		}
+		if len(ds.Leads) == 0 {
+			return errors.New("no leads to locate, pass the leads and locations files")
+		}
+		if ds, err = prepareDataset(ds); err != nil {
+			return err
+		}
+		stats, err := internal.GeoBreakdown(ds.Leads, ds.Locations, ds.Visits, args[0])
+		if err != nil {
+			return err
+		}
+		if reportTop > 0 && len(stats) > reportTop {
+			stats = stats[:reportTop]
+		}
+		if geoJSON {
+			features, missing := internal.GeoFeatures(stats)
+			e := json.NewEncoder(os.Stdout)
+			e.SetEscapeHTML(false)
+			e.SetIndent("", "  ")
+			if err := e.Encode(features); err != nil {
+				return err
+			}
+			if missing > 0 {
+				fmt.Fprintf(os.Stderr, "%d places without a known location were left out\n", missing)
+			}
+		} else if err := writeReport(internal.GeoTable(stats, args[0]), stats); err != nil {
+			return err
+		}
+		reportFiltered()
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(geoCmd)
+	geoCmd.Flags().SortFlags = false
+
+	geoCmd.Flags().BoolVar(&geoJSON, "geojson", false, "Write GeoJSON points instead of a report")
+	addRangeFlags(geoCmd)
+	addReportFlags(geoCmd)
+	addWhereFlag(geoCmd)
+}
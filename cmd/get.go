@@ -17,14 +17,19 @@ along with this program. If not, see <http://www.gnu.org/licenses/>.
 package cmd
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/willbenica/lf-cli/internal"
 	"go.uber.org/zap"
+	"golang.org/x/term"
 )
 
 const (
@@ -33,11 +38,54 @@ const (
 )
 
 var (
-	// Folder is where data should be written to
+	// Folder is where data should be written to, replaced by --output-dir
 	Folder string
 	Cwd    string
+	// format is the output format: json, csv, tsv, sqlite, parquet or xlsx
+	format string
+	// outFile is the database written by --format sqlite, replaced by --output
+	outFile string
+	// visitRows controls how visit routes are flattened for csv and tsv
+	visitRows string
+	// parquetCompression is the codec used by --format parquet
+	parquetCompression string
+	// parquetRowGroupMB is the row group size of --format parquet in MB
+	parquetRowGroupMB int64
+	// printFormat is how a single page is printed: table, wide, json, ndjson or yaml
+	printFormat string
+	// output is - to stream all records to stdout, or the file to write to
+	output string
+	// columns overrides the columns printed by --print table and wide
+	columns []string
+	// outputDir is the directory files are written to, one per resource
+	outputDir string
+	// nameTemplate names the files written to outputDir
+	nameTemplate string
+	// chunkSize splits the files written to outputDir after this many records
+	chunkSize int
+	// compression compresses json, csv and tsv output with gzip or zstd
+	compression string
 )
 
+// Where get writes the data to
+const (
+	// destPrint prints a single page with --print table, wide, json, ndjson or yaml
+	destPrint = iota
+	// destStdout streams the records of all pages to stdout with --output -
+	destStdout
+	// destFile writes all records to the file given with --output
+	destFile
+	// destDir writes one file per resource to --output-dir
+	destDir
+	// destQuery prints the results of --query for each page
+	destQuery
+)
+
+type destination struct {
+	kind int
+	path string
+}
+
 // getCmd represents the get command
 var getCmd = &cobra.Command{
 	Use:   "get <endpoint name>",
@@ -46,7 +94,28 @@ var getCmd = &cobra.Command{
 https://api.leadfeeder.com/accounts/...
 	leads
 	visits (all visits)
-  Unsuported: Getting an invidvidual lead's visists`,
+  Unsuported: Getting an invidvidual lead's visists
+
+Where the data goes is the same with and without --get-all:
+  --print table|wide|json|ndjson|yaml  print a single page (json is the default),
+                                       formerly --output table|wide|json|ndjson|yaml
+  --output -                           stream all records to stdout, as NDJSON, csv or tsv
+  --output <file>                      write all records to one file
+  --output-dir <dir>                   write one file per resource, named by --name-template
+--get-all, --format parquet and --format xlsx write to the working directory
+if neither --output nor --output-dir is given.
+
+--query runs a jq expression on the response of each page and prints the
+results, with --get-all for every page:
+  lf-cli get visits -a --query '.data[].attributes.campaign' -r
+The expression sees one page at a time, so group_by, length and other
+aggregations are per page. Write the pages to files and use
+'lf-cli query --slurp' to aggregate over all of them.
+
+--name-template is a Go template with the fields .Endpoint, .Account,
+.StartDate, .EndDate, .Chunk and .Ext, e.g.
+  --name-template '{{.Account}}_{{.Endpoint}}_{{.StartDate}}_{{.Chunk}}.{{.Ext}}'
+.Chunk is the part number with --chunk-size and 0 otherwise.`,
 	Args: func(cmd *cobra.Command, args []string) error {
 		if len(args) == 0 {
 			return errors.New(missingEndpointMsg)
@@ -56,7 +125,65 @@ https://api.leadfeeder.com/accounts/...
 		}
 		return fmt.Errorf(invalidEndPointMsg, args[0])
 	},
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := validateFormatFlags(format, visitRows); err != nil {
+			return err
+		}
+		if err := validateCompression(format); err != nil {
+			return err
+		}
+		if err := setProjection(args[0], format); err != nil {
+			return err
+		}
+		if err := setFilter(args[0]); err != nil {
+			return err
+		}
+		if err := loadContentGroups(); err != nil {
+			return err
+		}
+		if err := setQuery(); err != nil {
+			return err
+		}
+		if len(columns) > 0 && projection != nil {
+			return errors.New("use either --columns or --fields")
+		}
+		// --output table|wide|json|ndjson|yaml printed a page before --print
+		// took over, it keeps working with a warning
+		if internal.IsValidOutput(output) {
+			if cmd.Flags().Changed("print") {
+				return errors.New("use either --print or --output")
+			}
+			fmt.Fprintf(os.Stderr, "--output %s is deprecated, use --print %s\n", output, output)
+			if err := cmd.Flags().Set("print", output); err != nil {
+				return err
+			}
+			output = ""
+		}
+		dest, err := outputDestination(cmd.Flags().Changed("print"))
+		if err != nil {
+			return err
+		}
+		if chunkSize < 0 || (chunkSize > 0 && dest.kind != destDir) {
+			return errors.New("--chunk-size must be positive and only applies to --output-dir")
+		}
+		// Render the template once up front, so a broken template fails before any request
+		first, err := fileName(args[0], 1)
+		if err != nil {
+			return err
+		}
+		if second, _ := fileName(args[0], 2); chunkSize > 0 && first == second {
+			return errors.New("--chunk-size requires {{.Chunk}} in --name-template, otherwise the parts overwrite each other")
+		}
+		return nil
+	},
 	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := loadToken(); err != nil {
+			return err
+		}
+		dest, err := outputDestination(cmd.Flags().Changed("print"))
+		if err != nil {
+			return err
+		}
 
 		flags := internal.Flags{
 			StartDate:  internal.TodayOrDate(startDate),
@@ -69,7 +196,7 @@ https://api.leadfeeder.com/accounts/...
 		}
 
 		// Raise loglevel to Error if use is printing response to the console
-		if !all || len(Folder) > 0 || quiet {
+		if !all || dest.kind == destStdout || dest.kind == destQuery || quiet {
 			logConfig.Level.SetLevel(zap.ErrorLevel)
 			internal.LogConfig.Level.SetLevel(zap.ErrorLevel)
 		}
@@ -79,134 +206,660 @@ https://api.leadfeeder.com/accounts/...
 			internal.LogConfig.Level.SetLevel(zap.DebugLevel)
 		}
 
-		if !all {
-			logger.Debug("Retrieving ONLY one response, not looping to the last page")
-			data, err := internal.GetEndPointData(args[0], baseURL, token, accountID, flags.StartDate, flags.EndDate, flags.PageSize, flags.PageNumber)
-			if err != nil {
+		startTime := time.Now()
+		data, err := internal.GetEndPointData(args[0], baseURL, token, accountID, flags.StartDate, flags.EndDate, pageSize, pageNumber)
+		if err != nil {
+			return err
+		}
+		if data == nil {
+			logger.Error("Data failed to process - unknown issue.")
+			return fmt.Errorf("we ran into an unknown issue when trying to collect the data")
+		}
+		if data, err = preparePage(data); err != nil {
+			return err
+		}
+		if dest.kind == destQuery {
+			if err := runQuery(data); err != nil {
 				return err
 			}
-
-			// Are we writing to the default folder or just printing to the console?
-			if len(Folder) != 0 {
-				switch args[0] {
-				case "leads":
-					var ld internal.Leads
-					var lc internal.Locations
-					epld, eplc, _, _ := data.GetData()
-					ld.Data = epld
-					lc.Data = eplc
-					errWrite := internal.WriteToFile(Folder, internal.CreateFileName("leads", flags), ld.GetAllData())
-					if errWrite != nil {
-						logger.Error("failed to write to file", zap.Error(errWrite))
-					}
-					errLoc := internal.WriteToFile(Folder, internal.CreateFileName("locations", flags), lc.GetAllData())
-					if errLoc != nil {
-						logger.Error("failed to write to file", zap.Error(errLoc))
-					}
-				case "visits":
-					var v internal.Visits
-					_, _, epv, _ := data.GetData()
-					v.Data = epv
-					errWrite := internal.WriteToFile(Folder, internal.CreateFileName("visits", flags), v.GetAllData())
-					if errWrite != nil {
-						logger.Error("failed to write to file", zap.Error(errWrite))
-					}
-				default:
-					logger.DPanic("We should never reach this statement")
+			if all {
+				lastPage, _ := data.GetLastPageNumber()
+				if err := internal.ForEachPage(args[0], pageNumber+1, lastPage, flags, filtered(runQuery)); err != nil {
+					return err
 				}
 			}
+			reportFiltered()
+			return nil
+		}
+		if dest.kind == destPrint {
+			if err := printData(data); err != nil {
+				return err
+			}
+			reportFiltered()
+			return nil
+		}
 
-			// If no folder is specified to write, then just print to the console
-			if data == nil {
-				return nil
+		lastPage := pageNumber
+		if all {
+			logger.Info("Getting All Data")
+			lastPage, _ = data.GetLastPageNumber()
+		} else {
+			logger.Debug("Retrieving ONLY one response, not looping to the last page")
+		}
+
+		sink, err := newPageSink(dest, args[0])
+		if err != nil {
+			return err
+		}
+		if sink != nil {
+			// Ends the compressed stream of a partial write, Close is a no-op after success
+			defer sink.Close()
+			if err := sink.write(data); err != nil {
+				return err
 			}
-			dataAsString, err := data.String()
-			if err != nil {
+			if err := internal.ForEachPage(args[0], pageNumber+1, lastPage, flags, filtered(sink.write)); err != nil {
 				return err
 			}
-			fmt.Println(dataAsString)
+			if err := sink.Close(); err != nil {
+				return err
+			}
+			reportFiltered()
+			logger.Info("Process took", zap.Duration("duration", time.Since(startTime)))
 			return nil
 		}
-		//-----------------------------//
-		// Parse the complete endpoint //
-		//-----------------------------//
 
-		// Parse all endpoint data
-		startTime := time.Now()
+		var ds internal.Dataset
+		collect := func(page internal.EndPoint) error {
+			leads, locations, visits, _ := page.GetData()
+			ds.Leads = append(ds.Leads, leads...)
+			ds.Locations = append(ds.Locations, locations...)
+			ds.Visits = append(ds.Visits, visits...)
+			return nil
+		}
+		collect(data)
+		if err := internal.ForEachPage(args[0], pageNumber+1, lastPage, flags, filtered(collect)); err != nil {
+			return err
+		}
+		logger.Debug("Finished looping through the pages", zap.Int("leads", len(ds.Leads)), zap.Int("locations", len(ds.Locations)), zap.Int("visits", len(ds.Visits)))
 
-		// Can be removed after implementation
-		logger.Info("Getting All Data")
+		if dest.kind == destFile {
+			err = writeFile(dest.path, args[0], ds)
+		} else {
+			err = writeDir(dest.path, args[0], ds)
+		}
+		if err != nil {
+			return err
+		}
+		reportFiltered()
+		logger.Info("Process complete")
+		logger.Info("Process took", zap.Duration("duration", time.Since(startTime)))
+		return nil
+	},
+	ValidArgs: []string{"leads", "custom-feeds", "visits"},
+}
 
-		baseData, err := internal.GetEndPointData(args[0], baseURL, token, accountID, internal.TodayOrDate(startDate), internal.TodayOrDate(endDate), pageSize, pageNumber)
+// outputDestination works out where to write from --print, --output,
+// --output-dir, --format and --get-all, including the deprecated --folder and
+// --out. printSet tells whether --print was given.
+func outputDestination(printSet bool) (destination, error) {
+	if !internal.IsValidOutput(printFormat) {
+		return destination{}, fmt.Errorf("invalid value %q for --print, use table, wide, json, ndjson or yaml", printFormat)
+	}
+	dir, out := outputDir, output
+	if dir == "" {
+		dir = Folder
+	}
+	if out == "" {
+		out = outFile
+	}
+	if queryExpr == "" && (queryOptions.Raw || queryOptions.Compact) {
+		return destination{}, errors.New("-r/--raw-output and -c/--compact-output format the results of --query")
+	}
+	switch {
+	case queryExpr != "":
+		if printSet || out != "" || dir != "" || format != internal.FormatJSON || len(fields) > 0 || len(columns) > 0 {
+			return destination{}, errors.New("--query prints its results to stdout and can't be combined with --print, --output, --output-dir, --format, --fields or --columns")
+		}
+		return destination{kind: destQuery}, nil
+	case printSet:
+		if all || out != "" || dir != "" || format != internal.FormatJSON {
+			return destination{}, fmt.Errorf("--print %s prints a single page, use --output or --output-dir with --get-all and --format", printFormat)
+		}
+		return destination{kind: destPrint}, nil
+	case out != "" && dir != "":
+		return destination{}, errors.New("use either --output or --output-dir")
+	case out == "-":
+		if format != internal.FormatJSON && format != internal.FormatCSV && format != internal.FormatTSV {
+			return destination{}, fmt.Errorf("--output - streams json, csv or tsv, --format %s has to be written to a file", format)
+		}
+		return destination{kind: destStdout}, nil
+	case out != "":
+		if format == internal.FormatParquet {
+			return destination{}, errors.New("--format parquet writes one file per resource, use --output-dir")
+		}
+		return destination{kind: destFile, path: out}, nil
+	case format == internal.FormatSQLite:
+		return destination{}, errors.New("--format sqlite requires --output <database file>")
+	case all || dir != "" || format == internal.FormatParquet || format == internal.FormatXLSX:
+		if dir == "" {
+			dir = "."
+		}
+		return destination{kind: destDir, path: dir}, nil
+	case format == internal.FormatJSON:
+		return destination{kind: destPrint}, nil
+	default:
+		return destination{kind: destStdout}, nil
+	}
+}
+
+// fileName names the file of endpoint (leads, locations or visits) with --name-template
+// and adds the suffix of --compress
+func fileName(endpoint string, chunk int) (string, error) {
+	name, err := internal.FileName(nameTemplate, internal.FileNameData{
+		Endpoint:  endpoint,
+		Account:   accountID,
+		StartDate: internal.TodayOrDate(startDate),
+		EndDate:   internal.TodayOrDate(endDate),
+		Chunk:     chunk,
+		Ext:       format,
+	})
+	return name + internal.CompressionSuffix(compression), err
+}
+
+// pageWriter streams pages to w as NDJSON, or as csv/tsv with one header row.
+// With separateLocations the locations of leads are left to another writer.
+type pageWriter struct {
+	w                 io.Writer
+	headerWritten     bool
+	separateLocations bool
+}
+
+func (p *pageWriter) write(page internal.EndPoint) error {
+	leads, locations, visits, _ := page.GetData()
+	return p.writeRecords(endpointOf(page), internal.Dataset{Leads: leads, Locations: locations, Visits: visits})
+}
+
+// writeRecords writes the leads or visits of endpoint, the locations are
+// needed for the location columns of leads
+func (p *pageWriter) writeRecords(endpoint string, ds internal.Dataset) error {
+	if projection != nil {
+		records, err := projectData(primaryData(endpoint, ds))
 		if err != nil {
 			return err
 		}
-		if baseData != nil {
-			lastpage, _ := baseData.GetLastPageNumber()
-			switch baseData.Type() {
-			case "LeadsResponse":
-				logger.Debug("Getting LeadData & LocationData")
-				leads, locations, _, _ := baseData.GetData()
-				logger.Debug("Starting to loop through LeadData & LocationData")
-				var allLeads internal.Leads
+		if format == internal.FormatJSON {
+			_, err = fmt.Fprint(p.w, internal.RecordsNDJSON(records))
+			return err
+		}
+		return p.writeTable(internal.RecordsTable(records, *projection))
+	}
+	if format == internal.FormatJSON {
+		locations := ""
+		if !p.separateLocations {
+			locations = internal.Locations{Data: ds.Locations}.GetAllData()
+		}
+		_, err := fmt.Fprint(p.w, internal.Leads{Data: ds.Leads}.GetAllData(), locations, internal.Visits{Data: ds.Visits}.GetAllData())
+		return err
+	}
+	if endpoint == "leads" {
+		return p.writeTable(internal.LeadsTable(ds.Leads, ds.Locations))
+	}
+	return p.writeTable(internal.VisitsTable(ds.Visits, visitRows))
+}
 
-				loopedLeads, loopedLocations, err := internal.LoopThroughLeadsData(leads, locations, pageNumber+1, lastpage, flags)
-				if err != nil {
-					return err
-				}
-				logger.Debug("Finished looping through LeadData & LocationData")
+// writeLocations writes locations on their own
+func (p *pageWriter) writeLocations(locations []internal.Location) error {
+	if format == internal.FormatJSON {
+		_, err := fmt.Fprint(p.w, internal.Locations{Data: locations}.GetAllData())
+		return err
+	}
+	return p.writeTable(internal.LocationsTable(locations))
+}
 
-				allLeads.Data = loopedLeads
+// writeTable writes the rows of t, with the header before the first page
+func (p *pageWriter) writeTable(t internal.Table) error {
+	if !p.headerWritten {
+		p.headerWritten = true
+		return t.WriteDelimited(p.w, format)
+	}
+	return t.WriteDelimitedRows(p.w, format)
+}
 
-				leadsFile := internal.CreateFileName("leads", flags)
-				logger.Info("Writing to file:", zap.String("file", leadsFile))
-				errLeads := internal.WriteToFile(Folder, leadsFile, allLeads.GetAllData())
-				if errLeads != nil {
-					return errLeads
-				}
-				logger.Info("File written", zap.String("file", leadsFile))
-
-				var allLocations internal.Locations
-				allLocations.Data = loopedLocations
-				locationsFile := internal.CreateFileName("locations", flags)
-				logger.Info("Writing to file", zap.String("file", locationsFile))
-				errLocations := internal.WriteToFile(Folder, locationsFile, allLocations.GetAllData())
-				if errLocations != nil {
-					return errLocations
-				}
-				logger.Info("File written", zap.String("file", locationsFile))
-
-			case "VisitsResponse":
-				logger.Debug("Getting VisitData")
-				_, _, data, _ := baseData.GetData()
-				var v internal.Visits
-				v.Data = data
-				logger.Debug("Starting to loop through VisitData")
-				loopedVisits, err := internal.LoopThroughVistsData(v, pageNumber+1, lastpage, flags)
-				if err != nil {
-					return err
-				}
-				logger.Debug("Finished looping through VisitData")
-				visitsFile := internal.CreateFileName("visits", flags)
-				logger.Info("Writing to file", zap.String("file", visitsFile))
-				errLocations := internal.WriteToFile(Folder, visitsFile, loopedVisits.GetAllData())
-				if errLocations != nil {
-					return errLocations
-				}
-				logger.Info("File written", zap.String("file", visitsFile))
+// pageSink receives the pages written to stdout or to json, csv and tsv files
+// as they arrive. Close ends the output and may be called more than once.
+type pageSink interface {
+	write(page internal.EndPoint) error
+	Close() error
+}
+
+// newPageSink returns where pages are streamed to for --output - and for
+// json, csv and tsv with --output or --output-dir, nil if the records have to
+// be collected first
+func newPageSink(dest destination, endpoint string) (pageSink, error) {
+	textFormat := format == internal.FormatJSON || format == internal.FormatCSV || format == internal.FormatTSV
+	switch {
+	case dest.kind == destStdout:
+		cw, err := internal.NewCompressWriter(os.Stdout, compression)
+		if err != nil {
+			return nil, err
+		}
+		return &streamSink{pageWriter: &pageWriter{w: cw}, closer: cw}, nil
+	case dest.kind == destFile && textFormat:
+		path := dest.path
+		if suffix := internal.CompressionSuffix(compression); !strings.HasSuffix(path, suffix) {
+			path += suffix
+		}
+		f, err := internal.CreateCompressedFile(filepath.Dir(path), filepath.Base(path), compression)
+		if err != nil {
+			return nil, err
+		}
+		return &streamSink{pageWriter: &pageWriter{w: f}, closer: f}, nil
+	case dest.kind == destDir && textFormat:
+		return newChunkWriter(dest.path, endpoint)
+	}
+	return nil, nil
+}
+
+// streamSink writes pages to a single stream
+type streamSink struct {
+	*pageWriter
+	closer io.Closer
+	closed bool
+}
+
+func (s *streamSink) Close() error {
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	return s.closer.Close()
+}
+
+// chunkWriter streams pages to one file per resource in dir, starting a new
+// file every --chunk-size records. The locations of leads get their own file
+// unless --fields adds them to the leads.
+type chunkWriter struct {
+	dir           string
+	endpoint      string
+	chunk         int
+	count         int
+	file          io.WriteCloser
+	records       *pageWriter
+	locationsFile io.WriteCloser
+	locations     *pageWriter
+	closed        bool
+}
 
-			default:
-				logger.DPanic("We should never end up here!")
+func newChunkWriter(dir string, endpoint string) (*chunkWriter, error) {
+	c := &chunkWriter{dir: dir, endpoint: endpoint}
+	if chunkSize > 0 {
+		c.chunk = 1
+	}
+	if err := c.open(); err != nil {
+		return nil, err
+	}
+	if endpoint == "leads" && projection == nil {
+		name, err := fileName("locations", 0)
+		if err != nil {
+			c.file.Close()
+			return nil, err
+		}
+		logger.Info("Writing to file", zap.String("file", name))
+		if c.locationsFile, err = internal.CreateCompressedFile(dir, name, compression); err != nil {
+			c.file.Close()
+			return nil, err
+		}
+		c.locations = &pageWriter{w: c.locationsFile}
+	}
+	return c, nil
+}
+
+// open starts the file of the current chunk
+func (c *chunkWriter) open() error {
+	name, err := fileName(c.endpoint, c.chunk)
+	if err != nil {
+		return err
+	}
+	logger.Info("Writing to file", zap.String("file", name))
+	f, err := internal.CreateCompressedFile(c.dir, name, compression)
+	if err != nil {
+		return err
+	}
+	c.file, c.records, c.count = f, &pageWriter{w: f, separateLocations: true}, 0
+	return nil
+}
+
+func (c *chunkWriter) write(page internal.EndPoint) error {
+	leads, locations, visits, _ := page.GetData()
+	if c.locations != nil {
+		if err := c.locations.writeLocations(locations); err != nil {
+			return err
+		}
+	}
+	for len(leads) > 0 || len(visits) > 0 {
+		if chunkSize > 0 && c.count == chunkSize {
+			if err := c.file.Close(); err != nil {
+				return err
+			}
+			c.chunk++
+			if err := c.open(); err != nil {
+				return err
 			}
+		}
+		n := len(leads) + len(visits)
+		if chunkSize > 0 && n > chunkSize-c.count {
+			n = chunkSize - c.count
+		}
+		part := internal.Dataset{Locations: locations}
+		if c.endpoint == "leads" {
+			part.Leads, leads = leads[:n], leads[n:]
+		} else {
+			part.Visits, visits = visits[:n], visits[n:]
+		}
+		if err := c.records.writeRecords(c.endpoint, part); err != nil {
+			return err
+		}
+		c.count += n
+	}
+	return nil
+}
 
-			logger.Info("Process complete")
-			logger.Info("Process took", zap.Duration("duration", time.Since(startTime)))
-			return nil
+func (c *chunkWriter) Close() error {
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	err := c.file.Close()
+	if c.locationsFile != nil {
+		if e := c.locationsFile.Close(); err == nil {
+			err = e
 		}
-		logger.Error("Data failed to process - unknown issue.")
-		return fmt.Errorf("we ran into an unknown issue when trying to collect all data")
-	},
-	ValidArgs: []string{"leads", "custom-feeds", "visits"},
+	}
+	return err
+}
+
+// filtered adds the content groups and applies --where to each page before passing it to fn
+func filtered(fn func(internal.EndPoint) error) func(internal.EndPoint) error {
+	return func(page internal.EndPoint) error {
+		page, err := preparePage(page)
+		if err != nil {
+			return err
+		}
+		return fn(page)
+	}
+}
+
+// endpointOf returns the endpoint a response came from
+func endpointOf(page internal.EndPoint) string {
+	if page.Type() == "LeadsResponse" {
+		return "leads"
+	}
+	return "visits"
+}
+
+// primaryData returns the records of the endpoint and the locations needed to flatten them
+func primaryData(endpoint string, ds internal.Dataset) (interface{}, internal.Locations) {
+	if endpoint == "leads" {
+		return internal.Leads{Data: ds.Leads}, internal.Locations{Data: ds.Locations}
+	}
+	return internal.Visits{Data: ds.Visits}, internal.Locations{}
+}
+
+// writeFile writes all records of the dataset to a single SQLite database or
+// workbook, text formats are streamed by newPageSink
+func writeFile(path string, endpoint string, ds internal.Dataset) error {
+	if format == internal.FormatSQLite {
+		return internal.WriteSQLite(path, ds)
+	}
+	return writeWorkbook(filepath.Dir(path), filepath.Base(path), endpoint, ds)
+}
+
+// writeDir writes one parquet file or workbook per resource to dir, split into
+// parts of --chunk-size records. Text formats are streamed by newPageSink.
+func writeDir(dir string, endpoint string, ds internal.Dataset) error {
+	_, locations := primaryData(endpoint, ds)
+	total := len(ds.Visits)
+	if endpoint == "leads" {
+		total = len(ds.Leads)
+	}
+	size, chunk := total, 0
+	if chunkSize > 0 {
+		size, chunk = chunkSize, 1
+	}
+	for start := 0; start < total || start == 0; start += size {
+		end := start + size
+		if end > total {
+			end = total
+		}
+		name, err := fileName(endpoint, chunk)
+		if err != nil {
+			return err
+		}
+		part := internal.Dataset{Locations: ds.Locations}
+		var data interface{}
+		if endpoint == "leads" {
+			part.Leads = ds.Leads[start:end]
+			data = internal.Leads{Data: part.Leads}
+		} else {
+			part.Visits = ds.Visits[start:end]
+			data = internal.Visits{Data: part.Visits}
+		}
+		logger.Info("Writing to file", zap.String("file", name))
+		if format == internal.FormatXLSX {
+			err = writeWorkbook(dir, name, endpoint, part)
+		} else {
+			err = writeFormatted(dir, name, data, locations)
+		}
+		if err != nil {
+			return err
+		}
+		logger.Info("File written", zap.String("file", name))
+		if chunk > 0 {
+			chunk++
+		}
+		if size == 0 {
+			break
+		}
+	}
+
+	// Projected leads contain the location fields they need
+	if endpoint != "leads" || format == internal.FormatXLSX || projection != nil {
+		return nil
+	}
+	name, err := fileName("locations", 0)
+	if err != nil {
+		return err
+	}
+	logger.Info("Writing to file", zap.String("file", name))
+	return writeFormatted(dir, name, locations, locations)
+}
+
+// printData prints a single page according to --print and --columns
+func printData(data internal.EndPoint) error {
+	leads, locations, visits, _ := data.GetData()
+	if projection != nil {
+		return printRecords(data)
+	}
+	switch printFormat {
+	case internal.OutputNDJSON:
+		if data.Type() == "LeadsResponse" {
+			fmt.Print(internal.Leads{Data: leads}.GetAllData())
+			fmt.Print(internal.Locations{Data: locations}.GetAllData())
+		} else {
+			fmt.Print(internal.Visits{Data: visits}.GetAllData())
+		}
+		return nil
+	case internal.OutputTable, internal.OutputWide:
+		var view internal.Table
+		var cols []string
+		if data.Type() == "LeadsResponse" {
+			view, cols = internal.LeadsView(leads, locations), internal.LeadTableColumns
+			if printFormat == internal.OutputWide {
+				cols = internal.LeadWideColumns
+			}
+		} else {
+			view, cols = internal.VisitsView(visits), internal.VisitTableColumns
+			if printFormat == internal.OutputWide {
+				cols = internal.VisitWideColumns
+			}
+		}
+		if len(columns) > 0 {
+			cols = columns
+		}
+		table, err := view.Select(cols...)
+		if err != nil {
+			return err
+		}
+		return table.WriteAligned(os.Stdout, terminalWidth())
+	}
+
+	dataAsString, err := data.String()
+	if err != nil {
+		return err
+	}
+	if printFormat == internal.OutputYAML {
+		out, err := internal.JSONToYAML(dataAsString)
+		if err != nil {
+			return err
+		}
+		fmt.Print(out)
+		return nil
+	}
+	fmt.Println(dataAsString)
+	return nil
+}
+
+// printRecords prints a single page projected with --fields
+func printRecords(data internal.EndPoint) error {
+	leads, locations, visits, _ := data.GetData()
+	records, err := projectData(primaryData(endpointOf(data), internal.Dataset{Leads: leads, Locations: locations, Visits: visits}))
+	if err != nil {
+		return err
+	}
+	switch printFormat {
+	case internal.OutputNDJSON:
+		fmt.Print(internal.RecordsNDJSON(records))
+		return nil
+	case internal.OutputTable, internal.OutputWide:
+		return internal.RecordsTable(records, *projection).WriteAligned(os.Stdout, terminalWidth())
+	}
+	out, err := json.Marshal(records)
+	if err != nil {
+		return err
+	}
+	if printFormat == internal.OutputYAML {
+		y, err := internal.JSONToYAML(string(out))
+		if err != nil {
+			return err
+		}
+		fmt.Print(y)
+		return nil
+	}
+	fmt.Println(string(out))
+	return nil
+}
+
+// terminalWidth returns the width of the terminal, 0 if stdout is not a terminal
+func terminalWidth() int {
+	fd := int(os.Stdout.Fd())
+	if !term.IsTerminal(fd) {
+		return 0
+	}
+	width, _, err := term.GetSize(fd)
+	if err != nil {
+		return 0
+	}
+	return width
+}
+
+// validateFormatFlags checks the values of --format and --visit-rows
+func validateFormatFlags(format string, visitRows string) error {
+	if !internal.IsValidFormat(format) {
+		return fmt.Errorf("invalid format %q, use json, csv, tsv, sqlite, parquet or xlsx", format)
+	}
+	if _, ok := internal.ParquetCompressions[parquetCompression]; !ok {
+		return fmt.Errorf("invalid value %q for --parquet-compression, use none, snappy, gzip or zstd", parquetCompression)
+	}
+	if parquetRowGroupMB < 1 {
+		return errors.New("--parquet-row-group-mb must be at least 1")
+	}
+	if visitRows != internal.VisitRowsVisit && visitRows != internal.VisitRowsStep {
+		return fmt.Errorf("invalid value %q for --visit-rows, use visit or step", visitRows)
+	}
+	return nil
+}
+
+// formatData encodes leads, locations or visits in the requested format.
+// locations are needed to add the location of each lead to the row in csv/tsv.
+func formatData(data interface{}, locations internal.Locations, format string, visitRows string) (string, error) {
+	if projection != nil {
+		records, err := projectData(data, locations)
+		if err != nil {
+			return "", err
+		}
+		if format == internal.FormatJSON {
+			return internal.RecordsNDJSON(records), nil
+		}
+		return internal.RecordsTable(records, *projection).Delimited(format)
+	}
+	switch d := data.(type) {
+	case internal.Leads:
+		if format == internal.FormatJSON {
+			return d.GetAllData(), nil
+		}
+		return internal.LeadsTable(d.Data, locations.Data).Delimited(format)
+	case internal.Locations:
+		if format == internal.FormatJSON {
+			return d.GetAllData(), nil
+		}
+		return internal.LocationsTable(d.Data).Delimited(format)
+	case internal.Visits:
+		if format == internal.FormatJSON {
+			return d.GetAllData(), nil
+		}
+		return internal.VisitsTable(d.Data, visitRows).Delimited(format)
+	default:
+		return "", fmt.Errorf("unable to format %T", data)
+	}
+}
+
+// writeFormatted encodes data with formatData and writes it to path/filename
+func writeFormatted(path string, filename string, data interface{}, locations internal.Locations) error {
+	if format == internal.FormatParquet {
+		return internal.WriteParquetFile(path, filename, data, parquetOptions())
+	}
+	out, err := formatData(data, locations, format, visitRows)
+	if err != nil {
+		return err
+	}
+	return internal.WriteCompressedFile(path, filename, out, compression)
+}
+
+// validateCompression checks --compress, binary formats can't be compressed
+func validateCompression(format string) error {
+	if !internal.IsValidCompression(compression) {
+		return fmt.Errorf("invalid value %q for --compress, use gzip or zstd", compression)
+	}
+	if compression != internal.CompressNone && format != internal.FormatJSON && format != internal.FormatCSV && format != internal.FormatTSV {
+		return fmt.Errorf("--compress applies to json, csv and tsv, --format %s can't be compressed", format)
+	}
+	return nil
+}
+
+// addCompressFlag adds --compress to cmd
+func addCompressFlag(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&compression, "compress", internal.CompressNone, "Compress json, csv and tsv output as it is written: gzip or zstd. Files get a .gz or .zst suffix")
+}
+
+// parquetOptions returns the options set with the --parquet-* flags
+func parquetOptions() internal.ParquetOptions {
+	return internal.ParquetOptions{Compression: parquetCompression, RowGroupSize: parquetRowGroupMB * 1024 * 1024}
+}
+
+// addParquetFlags adds the flags controlling --format parquet to cmd
+func addParquetFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&parquetCompression, "parquet-compression", internal.DefaultParquetOptions.Compression, "Compression of --format parquet: none, snappy, gzip or zstd")
+	cmd.Flags().Int64Var(&parquetRowGroupMB, "parquet-row-group-mb", internal.DefaultParquetOptions.RowGroupSize/(1024*1024), "Row group size of --format parquet in MB")
 }
 
 func init() {
@@ -215,11 +868,28 @@ func init() {
 
 	Cwd, _ = os.Getwd()
 
-	getCmd.Flags().StringVarP(&Folder, "folder", "f", "", "NEEDS IMPROVEMENT: Folder where data should be written")
-	getCmd.Flags().MarkHidden("folder") // Marking this as hidden, so that it's not used
+	getCmd.Flags().StringVarP(&Folder, "folder", "f", "", "Folder where data should be written")
+	getCmd.Flags().MarkDeprecated("folder", "use --output-dir instead")
 	getCmd.Flags().StringVarP(&startDate, "start-date", "s", "today", "Start of the time period to return data. Use YYYY-MM-DD or today")
 	getCmd.Flags().StringVarP(&endDate, "end-date", "e", "today", "End of the time period to return data. Use YYYY-MM-DD or today")
 	getCmd.Flags().IntVarP(&pageSize, "page-size", "z", 100, "Number of results to return per page, 1-100")
 	getCmd.Flags().IntVarP(&pageNumber, "page-number", "n", 1, "Page to retrieve")
 	getCmd.Flags().BoolVarP(&all, "get-all", "a", false, "Get all data for this endpoint - loop from start to last page")
+	getCmd.Flags().StringVar(&format, "format", internal.FormatJSON, "Output format: json, csv, tsv, sqlite, parquet or xlsx. csv/tsv flatten attributes and add the location to each lead, parquet and xlsx are always written to files")
+	getCmd.Flags().StringVar(&outFile, "out", "", "Database to write to with --format sqlite, existing records are updated")
+	getCmd.Flags().MarkDeprecated("out", "use --output instead")
+	getCmd.Flags().StringVar(&visitRows, "visit-rows", internal.VisitRowsVisit, "How csv/tsv flatten visits: 'visit' (one row per visit, route summarised) or 'step' (one row per route step)")
+	addParquetFlags(getCmd)
+	getCmd.Flags().StringVar(&printFormat, "print", internal.OutputJSON, "Print a single page as table, wide, json, ndjson or yaml")
+	getCmd.Flags().StringVarP(&output, "output", "o", "", "- to stream all records to stdout, or a file to write to (the database with --format sqlite)")
+	getCmd.Flags().StringVar(&outputDir, "output-dir", "", "Directory to write one file per resource to, the default with --get-all is the working directory")
+	getCmd.Flags().StringVar(&nameTemplate, "name-template", internal.DefaultFileNameTemplate, "Go template naming the files in --output-dir, fields: .Endpoint .Account .StartDate .EndDate .Chunk .Ext")
+	getCmd.Flags().IntVar(&chunkSize, "chunk-size", 0, "Split the files in --output-dir after this many records, 0 writes a single file per resource")
+	addCompressFlag(getCmd)
+	addFieldsFlags(getCmd)
+	addWhereFlag(getCmd)
+	addContentGroupsFlag(getCmd)
+	getCmd.Flags().StringVar(&queryExpr, "query", "", "jq expression run on the response of each page, e.g. '.data[].attributes.name'")
+	addQueryOutputFlags(getCmd)
+	getCmd.Flags().StringSliceVar(&columns, "columns", nil, "Columns printed by --print table or wide, e.g. name,quality,city. Any csv column, location or source_medium")
 }
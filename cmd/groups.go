@@ -0,0 +1,65 @@
+/*
+Copyright © 2021 willbenica
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/willbenica/lf-cli/internal"
+)
+
+var (
+	// contentGroupsFile is the rules file assigning pages to content groups
+	contentGroupsFile string
+	// contentGroups is read from contentGroupsFile, nil leaves visits as they are
+	contentGroups *internal.ContentGroups
+)
+
+// addContentGroupsFlag adds --content-groups to cmd
+func addContentGroupsFlag(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&contentGroupsFile, "content-groups", "", "YAML file of rules assigning pages to content groups, adds page_group to each route step and page_groups to each visit")
+}
+
+// loadContentGroups reads --content-groups
+func loadContentGroups() error {
+	contentGroups = nil
+	if contentGroupsFile == "" {
+		return nil
+	}
+	g, err := internal.LoadContentGroups(contentGroupsFile)
+	if err != nil {
+		return err
+	}
+	contentGroups = g
+	return nil
+}
+
+// preparePage adds the content groups to the visits of page and applies --where
+func preparePage(page internal.EndPoint) (internal.EndPoint, error) {
+	if contentGroups != nil {
+		_, _, visits, _ := page.GetData()
+		contentGroups.Apply(visits)
+	}
+	return filterPage(page)
+}
+
+// prepareDataset adds the content groups to the visits of ds and applies --where
+func prepareDataset(ds internal.Dataset) (internal.Dataset, error) {
+	if contentGroups != nil {
+		contentGroups.Apply(ds.Visits)
+	}
+	return filterDataset(ds)
+}
@@ -0,0 +1,113 @@
+/*
+Copyright © 2021 willbenica
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/willbenica/lf-cli/internal"
+	"golang.org/x/term"
+)
+
+var (
+	// heatmapMetric is what the cells add up: visits or visit_length
+	heatmapMetric string
+	heatmapFilter internal.HeatmapFilter
+)
+
+// heatmapCmd represents the heatmap command
+var heatmapCmd = &cobra.Command{
+	Use:   "heatmap [file...]",
+	Short: "Show when visitors browse, by weekday and hour",
+	Long: `Count the visits, or add up their length with --metric visit_length, by
+weekday and hour of the day in the time zone of the account. The table output
+is a grid of weekdays by hours, shaded from cold to hot if stdout is a terminal
+and NO_COLOR is not set. Narrow terminals get narrower cells, and the last hours
+are left out of the grid if it still doesn't fit. -o csv and -o json print the
+numbers for other tools.
+
+--campaign keeps the visits of one campaign, --industry the visits of leads in
+one industry, which needs the leads as well.
+
+The visits are read from files written by 'lf-cli get', or requested for
+--start-date to --end-date if no files are given:
+  lf-cli heatmap -s 2021-05-01 -e 2021-05-31
+  lf-cli heatmap visits_*.json --campaign spring --metric visit_length
+  lf-cli heatmap leads_*.json visits_*.json --industry Software -o csv`,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := validateReportFlags(); err != nil {
+			return err
+		}
+		if heatmapMetric != internal.HeatmapVisits && heatmapMetric != internal.HeatmapVisitLength {
+			return fmt.Errorf("invalid value %q for --metric, use visits or visit_length", heatmapMetric)
+		}
+		return setFilter("visits")
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		endpoints := []string{"visits"}
+		if heatmapFilter.Industry != "" {
+			endpoints = append(endpoints, "leads")
+		}
+		ds, err := loadDataset(args, endpoints...)
+		if err != nil {
+			return err
+		}
+		if heatmapFilter.Industry != "" && len(ds.Leads) == 0 {
+			return errors.New("--industry needs the leads, pass the leads files")
+		}
+		if ds, err = prepareDataset(ds); err != nil {
+			return err
+		}
+		heatmap, err := internal.VisitHeatmap(ds.Visits, ds.Leads, heatmapFilter, heatmapMetric)
+		if err != nil {
+			return err
+		}
+		if reportOutput == reportTable {
+			err = heatmap.WriteGrid(os.Stdout, useColor(), terminalWidth())
+		} else {
+			err = writeReport(heatmap.Table(), heatmap)
+		}
+		if err != nil {
+			return err
+		}
+		reportFiltered()
+		return nil
+	},
+}
+
+// useColor reports whether stdout is a terminal and NO_COLOR is not set
+func useColor() bool {
+	if _, ok := os.LookupEnv("NO_COLOR"); ok {
+		return false
+	}
+	return term.IsTerminal(int(os.Stdout.Fd()))
+}
+
+func init() {
+	rootCmd.AddCommand(heatmapCmd)
+	heatmapCmd.Flags().SortFlags = false
+
+	heatmapCmd.Flags().StringVar(&heatmapMetric, "metric", internal.HeatmapVisits, "What the cells add up: visits or visit_length")
+	heatmapCmd.Flags().StringVar(&heatmapFilter.Campaign, "campaign", "", "Count only the visits of this campaign")
+	heatmapCmd.Flags().StringVar(&heatmapFilter.Industry, "industry", "", "Count only the visits of leads in this industry")
+	addRangeFlags(heatmapCmd)
+	heatmapCmd.Flags().StringVarP(&reportOutput, "output", "o", reportTable, "How to print the heatmap: table, json or csv")
+	addWhereFlag(heatmapCmd)
+}
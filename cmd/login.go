@@ -0,0 +1,176 @@
+/*
+Copyright © 2021 willbenica
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/willbenica/lf-cli/internal"
+	"go.uber.org/zap"
+	"golang.org/x/term"
+)
+
+var (
+	// loginBackend selects where `login` stores the token
+	loginBackend string
+	// storeCommand receives the token on stdin when using the command backend
+	storeCommand string
+)
+
+// loginCmd represents the login command
+var loginCmd = &cobra.Command{
+	Use:   "login",
+	Short: "Store an API token without keeping it in plain text in the config file",
+	Long: `Store the API token of the active profile with one of the following backends:
+  file     an encrypted credential file unlocked with a passphrase (default)
+           The passphrase is prompted for or read from LF_CLI_PASSPHRASE.
+  command  pipe the token to an external helper given with --store-command,
+           e.g. --store-command "pass insert -m lf-cli". Afterwards add
+           token_command: "pass show lf-cli" to your config file or profile.
+
+The token is prompted for, or read from stdin when stdin is not a terminal.`,
+	Args: cobra.NoArgs,
+	// --profile may name the profile to create
+	Annotations: map[string]string{createsProfile: "true"},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		t, err := readToken()
+		if err != nil {
+			return err
+		}
+
+		switch loginBackend {
+		case "file":
+			return storeInCredentialFile(t)
+		case "command":
+			if storeCommand == "" {
+				return errors.New("--store-command is required for the command backend")
+			}
+			return internal.StoreWithCommand(storeCommand, t)
+		default:
+			return fmt.Errorf("unknown backend %q, use 'file' or 'command'", loginBackend)
+		}
+	},
+}
+
+// stdin is shared by all prompts, so that piped input is not lost to buffering
+var stdin = bufio.NewReader(os.Stdin)
+
+// promptLine asks for a value, returning def when the answer is empty
+func promptLine(prompt string, def string) (string, error) {
+	if def != "" {
+		prompt = fmt.Sprintf("%s [%s]", prompt, def)
+	}
+	fmt.Fprint(os.Stderr, prompt+": ")
+	line, err := stdin.ReadString('\n')
+	if err != nil && line == "" {
+		return "", fmt.Errorf("unable to read %q from stdin: %w", prompt, err)
+	}
+	if line = strings.TrimSpace(line); line != "" {
+		return line, nil
+	}
+	return def, nil
+}
+
+// readToken prompts for the token or reads it from a pipe
+func readToken() (string, error) {
+	var t string
+	if term.IsTerminal(int(os.Stdin.Fd())) {
+		b, err := promptSecret("API token: ")
+		if err != nil {
+			return "", err
+		}
+		t = string(b)
+	} else {
+		line, err := stdin.ReadString('\n')
+		if err != nil && line == "" {
+			return "", fmt.Errorf("unable to read the token from stdin: %w", err)
+		}
+		t = line
+	}
+	t = strings.TrimSpace(t)
+	if t == "" {
+		return "", errors.New("no token provided")
+	}
+	return t, nil
+}
+
+// storeInCredentialFile adds the token of the active profile to the encrypted credential file
+func storeInCredentialFile(t string) error {
+	path := activeConfig.CredentialFile.Value
+	creds := map[string]string{}
+	var pass []byte
+	var err error
+
+	if _, statErr := os.Stat(path); statErr == nil {
+		pass, err = readPassphrase()
+		if err != nil {
+			return err
+		}
+		creds, err = internal.ReadCredentialFile(path, pass)
+		if err != nil {
+			return err
+		}
+	} else {
+		pass, err = newPassphrase()
+		if err != nil {
+			return err
+		}
+	}
+
+	creds[activeConfig.CredentialKey()] = t
+	if err := internal.WriteCredentialFile(path, pass, creds); err != nil {
+		return err
+	}
+	logger.Info("Token stored", zap.String("file", path), zap.String("key", activeConfig.CredentialKey()))
+	return nil
+}
+
+// newPassphrase asks for the passphrase of a new credential file twice
+func newPassphrase() ([]byte, error) {
+	if p, ok := os.LookupEnv(internal.EnvPrefix + "PASSPHRASE"); ok {
+		return []byte(p), nil
+	}
+	first, err := promptSecret("New passphrase for " + activeConfig.CredentialFile.Value + ": ")
+	if err != nil {
+		return nil, err
+	}
+	if len(first) == 0 {
+		return nil, errors.New("the passphrase must not be empty")
+	}
+	second, err := promptSecret("Repeat passphrase: ")
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(first, second) {
+		return nil, errors.New("passphrases do not match")
+	}
+	return first, nil
+}
+
+func init() {
+	rootCmd.AddCommand(loginCmd)
+	loginCmd.Flags().SortFlags = false
+
+	loginCmd.Flags().StringVarP(&loginBackend, "backend", "b", "file", "Where to store the token: file or command")
+	loginCmd.Flags().StringVar(&storeCommand, "store-command", "", "Command that receives the token on stdin, used with --backend command")
+}
@@ -0,0 +1,159 @@
+/*
+Copyright © 2021 willbenica
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/willbenica/lf-cli/internal"
+)
+
+var (
+	// pathsView selects what paths prints: entries, exits, pages or transitions
+	pathsView string
+	// pathFilter selects the visits and steps paths follows
+	pathFilter internal.PathFilter
+	// pathsGraph writes the navigation graph as dot or sankey instead of a report
+	pathsGraph string
+	// pathsSections groups pages by their first path segments, 0 keeps the pages
+	pathsSections int
+)
+
+// pathsCmd represents the paths command
+var pathsCmd = &cobra.Command{
+	Use:   "paths [file...]",
+	Short: "Show how visitors move through the site: entry and exit pages and transitions",
+	Long: `Follow the route of each visit and print, with --show,
+  entries      the pages visits start on, with bounces and bounce rate
+  exits        the pages visits end on, with the exit rate
+  pages        every page with views, entrances, exits, avg time on page, bounce and exit rate
+  transitions  how often visitors went from one page to the next, (entrance)
+               and (exit) mark the start and end of a visit
+
+Pages are identified by their path, --hostname keeps only the steps on one
+host. --sections groups pages by their first path segments, e.g. /blog/seo
+is /blog/* with --sections 1. With --content-groups pages are identified by
+their content group, pages without a group by their path or section.
+
+--graph writes the navigation graph instead, as Graphviz DOT or as Sankey
+JSON (nodes and links as used by d3-sankey). --top keeps the busiest
+transitions, loops are left out of Sankey diagrams:
+  lf-cli paths visits_*.json --graph dot --top 30 --sections 1 | dot -Tsvg > paths.svg
+  lf-cli paths visits_*.json --graph sankey --top 50 > sankey.json
+
+The visits are read from files written by 'lf-cli get visits', or
+requested for --start-date to --end-date if no files are given:
+  lf-cli paths -s 2021-05-01 -e 2021-05-31 --top 10
+  lf-cli paths visits_from_2021-05-01.json --show transitions --campaign spring -o csv`,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := validateReportFlags(); err != nil {
+			return err
+		}
+		if !isPathView(pathsView) {
+			return fmt.Errorf("invalid value %q for --show, use %s", pathsView, strings.Join(internal.PathViews, ", "))
+		}
+		if pathsGraph != "" {
+			if !internal.IsValidGraphFormat(pathsGraph) {
+				return fmt.Errorf("invalid value %q for --graph, use dot or sankey", pathsGraph)
+			}
+			if cmd.Flags().Changed("output") || cmd.Flags().Changed("show") {
+				return errors.New("--graph can't be combined with --output or --show")
+			}
+		}
+		if pathsSections < 0 {
+			return errors.New("--sections must not be negative")
+		}
+		if err := setFilter("visits"); err != nil {
+			return err
+		}
+		return loadContentGroups()
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ds, err := loadDataset(args, "visits")
+		if err != nil {
+			return err
+		}
+		if ds, err = prepareDataset(ds); err != nil {
+			return err
+		}
+		report := internal.Paths(ds.Visits, pathFilter, pageGroup())
+		if pathsGraph != "" {
+			if err := internal.NavigationGraph(report, reportTop).WriteGraph(os.Stdout, pathsGraph); err != nil {
+				return err
+			}
+			reportFiltered()
+			return nil
+		}
+		table, rows, err := report.PathView(pathsView, reportTop)
+		if err != nil {
+			return err
+		}
+		if err := writeReport(table, rows); err != nil {
+			return err
+		}
+		reportFiltered()
+		return nil
+	},
+}
+
+// pageGroup returns how paths identifies pages: by content group, falling
+// back to the section or path for pages no rule matches
+func pageGroup() func(internal.VisitRoute) string {
+	if contentGroups == nil && pathsSections == 0 {
+		return nil
+	}
+	return func(step internal.VisitRoute) string {
+		if step.PageGroup != "" {
+			return step.PageGroup
+		}
+		return internal.Section(step.PagePath, pathsSections)
+	}
+}
+
+func isPathView(view string) bool {
+	for _, v := range internal.PathViews {
+		if v == view {
+			return true
+		}
+	}
+	return false
+}
+
+// addPathFilterFlags adds the flags of internal.PathFilter to cmd
+func addPathFilterFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&pathFilter.Hostname, "hostname", "", "Follow only the steps on this host, e.g. www.example.com")
+	cmd.Flags().StringVar(&pathFilter.LeadID, "lead", "", "Follow only the visits of this lead ID")
+	cmd.Flags().StringVar(&pathFilter.Campaign, "campaign", "", "Follow only the visits of this campaign")
+}
+
+func init() {
+	rootCmd.AddCommand(pathsCmd)
+	pathsCmd.Flags().SortFlags = false
+
+	pathsCmd.Flags().StringVar(&pathsView, "show", internal.PathsEntries, "What to print: entries, exits, pages or transitions")
+	pathsCmd.Flags().StringVar(&pathsGraph, "graph", "", "Write the navigation graph instead of a report: dot or sankey")
+	pathsCmd.Flags().IntVar(&pathsSections, "sections", 0, "Group pages by their first path segments, e.g. 1 groups /blog/seo into /blog/*")
+	addPathFilterFlags(pathsCmd)
+	addRangeFlags(pathsCmd)
+	addReportFlags(pathsCmd)
+	addWhereFlag(pathsCmd)
+	addContentGroupsFlag(pathsCmd)
+}
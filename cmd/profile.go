@@ -0,0 +1,138 @@
+/*
+Copyright © 2021 willbenica
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/willbenica/lf-cli/internal"
+)
+
+// profileCmd represents the profile command
+var profileCmd = &cobra.Command{
+	Use:   "profile",
+	Short: "Inspect the profiles stored in the config file",
+	Long: `Profiles allow several leadfeeder accounts to be stored in one config file:
+  profiles:
+    clientA:
+      account: "123456"
+      token:   "xxxxYYYYxxxxWWWWxxxxQQQ867512"
+      lf-url:  "https://api.leadfeeder.com"
+
+A profile is selected with --profile <name>, the LF_CLI_PROFILE environment
+variable or the default-profile key, in that order.
+
+Every value is resolved with the precedence flag > env > profile > default,
+where default is the top level of the config file followed by the built-in default.`,
+}
+
+// profileListCmd represents the profile list command
+var profileListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List all profiles in the config file, the active profile is marked with '*'",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "\tPROFILE\tACCOUNT\tLF-URL")
+		for _, name := range configFile.ProfileNames() {
+			p := configFile.Profiles[name]
+			active := ""
+			// viper lowercases the profile names read from the config file
+			if strings.EqualFold(name, activeConfig.Profile.Value) {
+				active = "*"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", active, name, p.Account, p.BaseURL)
+		}
+		return w.Flush()
+	},
+}
+
+// profileShowCmd represents the profile show command
+var profileShowCmd = &cobra.Command{
+	Use:   "show [profile name]",
+	Short: "Show the settings of a profile, defaults to the active profile",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) == 1 {
+			p, err := configFile.GetProfile(args[0])
+			if err != nil {
+				return err
+			}
+			printSettings(args[0], []setting{
+				{"account", internal.Setting{Value: p.Account, Source: internal.SourceProfile}},
+				{"lf-url", internal.Setting{Value: p.BaseURL, Source: internal.SourceProfile}},
+				{"token", internal.Setting{Value: internal.MaskToken(p.Token), Source: internal.SourceProfile}},
+				{"token_command", internal.Setting{Value: p.TokenCommand, Source: internal.SourceProfile}},
+				{"credential_file", internal.Setting{Value: p.CredentialFile, Source: internal.SourceProfile}},
+			})
+			return nil
+		}
+		name := activeConfig.Profile.Value
+		for _, n := range configFile.ProfileNames() {
+			if strings.EqualFold(n, name) {
+				name = n
+			}
+		}
+		if name == "" {
+			name = "(none)"
+		}
+		printSettings(name, []setting{
+			{"account", activeConfig.Account},
+			{"lf-url", activeConfig.BaseURL},
+			{"token", maskedToken(activeConfig.Token)},
+			{"credential_file", activeConfig.CredentialFile},
+		})
+		return nil
+	},
+}
+
+// maskedToken hides the token, a token_command is shown as is since it holds no secret
+func maskedToken(t internal.Setting) internal.Setting {
+	if t.Source == internal.SourceTokenCommand {
+		return t
+	}
+	return internal.Setting{Value: internal.MaskToken(t.Value), Source: t.Source}
+}
+
+// setting is a named configuration value, used for printing
+type setting struct {
+	name string
+	internal.Setting
+}
+
+// printSettings writes settings as an aligned table to stdout
+func printSettings(profileName string, settings []setting) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "profile:\t%s\n", profileName)
+	for _, s := range settings {
+		if s.Source == "" {
+			s.Source = "unset"
+		}
+		fmt.Fprintf(w, "%s:\t%s\t(%s)\n", s.name, s.Value, s.Source)
+	}
+	w.Flush()
+}
+
+func init() {
+	rootCmd.AddCommand(profileCmd)
+	profileCmd.AddCommand(profileListCmd)
+	profileCmd.AddCommand(profileShowCmd)
+}
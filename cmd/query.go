@@ -0,0 +1,130 @@
+/*
+Copyright © 2021 willbenica
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package cmd
+
+import (
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/willbenica/lf-cli/internal"
+)
+
+var (
+	// queryExpr is the jq expression run on each page by get --query
+	queryExpr string
+	// jqQuery is compiled from --query, nil prints the data as usual
+	jqQuery *internal.Query
+	// queryOptions are set with --raw-output and --compact-output
+	queryOptions internal.QueryOptions
+	// querySlurp reads all values of the files into one array before running the query
+	querySlurp bool
+)
+
+// queryCmd represents the query command
+var queryCmd = &cobra.Command{
+	Use:   "query <jq expression> [file...]",
+	Short: "Run a jq expression on saved leads, visits or API responses",
+	Long: `Run a jq expression on files written by 'lf-cli get', without jq installed.
+Each JSON value in the files, i.e. each line of a file written by get, is
+one input, like in jq. gzip and zstd compressed files are read transparently,
+without files or with - the input is read from stdin.
+
+  lf-cli query '.attributes | select(.quality >= 3) | .name' leads_from_2021-05-01.json -r
+  lf-cli query 'group_by(.attributes.campaign) | map({campaign: .[0].attributes.campaign, visits: length})' visits_*.json --slurp`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		q, err := internal.NewQuery(args[0])
+		if err != nil {
+			return err
+		}
+		files := args[1:]
+		if len(files) == 0 {
+			files = []string{"-"}
+		}
+
+		var slurped []interface{}
+		run := func(v interface{}) error {
+			if querySlurp {
+				slurped = append(slurped, v)
+				return nil
+			}
+			return q.Run(os.Stdout, v, queryOptions)
+		}
+		for _, file := range files {
+			if err := readJSONFile(file, run); err != nil {
+				return err
+			}
+		}
+		if querySlurp {
+			if slurped == nil {
+				slurped = []interface{}{}
+			}
+			return q.Run(os.Stdout, slurped, queryOptions)
+		}
+		return nil
+	},
+}
+
+// readJSONFile hands each JSON value of file, or of stdin for -, to fn
+func readJSONFile(file string, fn func(interface{}) error) error {
+	var r io.ReadCloser
+	var err error
+	if file == "-" {
+		r, err = internal.NewDecompressReader(os.Stdin)
+	} else {
+		r, err = internal.OpenFile(file)
+	}
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	return internal.ReadJSONValues(r, fn)
+}
+
+// setQuery compiles --query
+func setQuery() error {
+	jqQuery = nil
+	if queryExpr == "" {
+		return nil
+	}
+	q, err := internal.NewQuery(queryExpr)
+	if err != nil {
+		return err
+	}
+	jqQuery = q
+	return nil
+}
+
+// runQuery runs --query on a page of the API response
+func runQuery(page internal.EndPoint) error {
+	return jqQuery.RunValue(os.Stdout, page, queryOptions)
+}
+
+// addQueryOutputFlags adds the flags controlling how query results are printed to cmd
+func addQueryOutputFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolVarP(&queryOptions.Raw, "raw-output", "r", false, "Print strings returned by the query without quotes, like jq -r")
+	cmd.Flags().BoolVarP(&queryOptions.Compact, "compact-output", "c", false, "Print each result of the query on one line, like jq -c")
+}
+
+func init() {
+	rootCmd.AddCommand(queryCmd)
+	queryCmd.Flags().SortFlags = false
+
+	queryCmd.Flags().BoolVarP(&querySlurp, "slurp", "s", false, "Read all values into one array and run the query once, like jq -s")
+	addQueryOutputFlags(queryCmd)
+}
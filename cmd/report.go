@@ -0,0 +1,121 @@
+/*
+Copyright © 2021 willbenica
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/willbenica/lf-cli/internal"
+	"go.uber.org/zap"
+)
+
+// Ways to print a report
+const (
+	reportTable = "table"
+	reportJSON  = "json"
+	reportCSV   = "csv"
+)
+
+var (
+	// reportOutput is how reports are printed: table, json or csv
+	reportOutput string
+	// reportTop limits reports to their first rows, 0 prints all
+	reportTop int
+)
+
+// addRangeFlags adds --start-date and --end-date to commands which request a
+// range of data when no files are given
+func addRangeFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(&startDate, "start-date", "s", "today", "Start of the time period to request if no files are given. Use YYYY-MM-DD or today")
+	cmd.Flags().StringVarP(&endDate, "end-date", "e", "today", "End of the time period to request if no files are given. Use YYYY-MM-DD or today")
+}
+
+// addReportFlags adds --output and --top to report commands
+func addReportFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(&reportOutput, "output", "o", reportTable, "How to print the report: table, json or csv")
+	cmd.Flags().IntVar(&reportTop, "top", 0, "Print only the first rows of the report, 0 prints all")
+}
+
+// validateReportFlags checks --output and --top of report commands
+func validateReportFlags() error {
+	switch reportOutput {
+	case reportTable, reportJSON, reportCSV:
+	default:
+		return fmt.Errorf("invalid value %q for --output, use table, json or csv", reportOutput)
+	}
+	if reportTop < 0 {
+		return fmt.Errorf("--top must not be negative")
+	}
+	return nil
+}
+
+// loadDataset reads the records from files, or requests every page of the
+// endpoints from --start-date to --end-date if no files are given
+func loadDataset(files []string, endpoints ...string) (internal.Dataset, error) {
+	if len(files) > 0 {
+		return internal.ReadDataFiles(files...)
+	}
+	return fetchDataset(internal.TodayOrDate(startDate), internal.TodayOrDate(endDate), endpoints...)
+}
+
+// fetchDataset requests every page of the endpoints from start to end
+func fetchDataset(start string, end string, endpoints ...string) (internal.Dataset, error) {
+	if err := loadToken(); err != nil {
+		return internal.Dataset{}, err
+	}
+	// Reports are printed to stdout, only errors should be logged next to them
+	if !verbose {
+		logConfig.Level.SetLevel(zap.ErrorLevel)
+		internal.LogConfig.Level.SetLevel(zap.ErrorLevel)
+	}
+	flags := internal.Flags{
+		StartDate: start,
+		EndDate:   end,
+		PageSize:  100,
+		BaseURL:   baseURL,
+		Token:     token,
+		AccountID: accountID,
+	}
+	var ds internal.Dataset
+	for _, ep := range endpoints {
+		data, err := internal.FetchDataset(ep, flags)
+		if err != nil {
+			return internal.Dataset{}, err
+		}
+		ds.Leads = append(ds.Leads, data.Leads...)
+		ds.Locations = append(ds.Locations, data.Locations...)
+		ds.Visits = append(ds.Visits, data.Visits...)
+	}
+	return ds, nil
+}
+
+// writeReport prints a report as an aligned table or csv from t, or as JSON from v
+func writeReport(t internal.Table, v interface{}) error {
+	switch reportOutput {
+	case reportJSON:
+		e := json.NewEncoder(os.Stdout)
+		e.SetEscapeHTML(false)
+		e.SetIndent("", "  ")
+		return e.Encode(v)
+	case reportCSV:
+		return t.WriteDelimited(os.Stdout, internal.FormatCSV)
+	}
+	return t.WriteAligned(os.Stdout, terminalWidth())
+}
@@ -20,13 +20,17 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	"github.com/willbenica/lf-cli/internal"
 	"go.uber.org/zap"
 
 	homedir "github.com/mitchellh/go-homedir"
 	"github.com/spf13/viper"
+	"golang.org/x/term"
 )
 
 var (
@@ -41,6 +45,14 @@ var (
 	token string
 	// accountID let's leadfeeder know which account the user would like to access
 	accountID string
+	// profile selects a named set of connection settings from the config file
+	profile string
+	// configFile holds the decoded contents of the config file, if one was found
+	configFile internal.ConfigFile
+	// activeConfig is the effective configuration and where each value came from
+	activeConfig internal.Config
+	// envFlags records the flags which were set from LF_CLI_* environment variables
+	envFlags = map[string]bool{}
 
 	// Control variables
 
@@ -72,6 +84,13 @@ For ease of use create a config file under $HOME/.config/lf-cli/.lf-cli.yaml
 or under $HOME/.lf-cli.yaml with the following
   account: "myAccountID"
   token:   "myApiToken"
+
+Several accounts can be stored as named profiles and selected with --profile
+or LF_CLI_PROFILE
+  profiles:
+    clientA:
+      account: "myOtherAccountID"
+      token:   "myOtherApiToken"
 	`,
 	Version: "2021.01",
 	// Uncomment the following line if your bare application
@@ -100,13 +119,25 @@ func init() {
 	rootCmd.PersistentFlags().SortFlags = false
 
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "path to a config file (default is $HOME/.config/lf-cli/.lf-cli.yaml)")
-	rootCmd.PersistentFlags().StringVarP(&baseURL, "lf-url", "", "https://api.leadfeeder.com", "leadfeeder URL")
+	rootCmd.PersistentFlags().StringVarP(&profile, "profile", "p", "", "Named profile from the config file to use")
+	rootCmd.PersistentFlags().StringVarP(&baseURL, "lf-url", "", internal.DefaultBaseURL, "leadfeeder URL")
 	rootCmd.PersistentFlags().StringVarP(&accountID, "accountID", "", "", "Account for which data should be accessed")
 	rootCmd.PersistentFlags().StringVarP(&token, "token", "", "", "API token used to access lf")
 	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "Increases loglevel to DEBUG for trouble shooting.")
 	rootCmd.PersistentFlags().BoolVarP(&quiet, "quiet", "q", false, "Surpress log output - '-v > -q'")
 
 	cobra.OnInitialize(initConfig)
+	// Flags of the command that runs are set from LF_CLI_* once they are
+	// parsed, commands sharing variables can't overwrite each other's flags
+	rootCmd.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
+		bindFlagsToEnv(cmd.Flags())
+		if err := resolveConfig(cmd); err != nil {
+			// The flags are fine, the config file or profile is not
+			cmd.SilenceUsage = true
+			return err
+		}
+		return nil
+	}
 
 	// Initalize logging and apply loglevel, etc
 	logger, logConfig = internal.InitLogger()
@@ -114,8 +145,15 @@ func init() {
 	defer rootLogger()
 }
 
-// initConfig reads in config file and ENV variables if set.
+// connectionFlags are resolved by internal.ResolveConfig, which reads their
+// environment variables itself and also takes profiles into account
+var connectionFlags = map[string]bool{"profile": true, "lf-url": true, "accountID": true, "token": true}
+
+// initConfig reads in config file and ENV variables if set, the connection
+// settings are resolved by resolveConfig once the command is known.
 func initConfig() {
+	bindFlagsToEnv(rootCmd.PersistentFlags())
+
 	if cfgFile != "" {
 		// Use config file from the flag.
 		viper.SetConfigFile(cfgFile)
@@ -124,29 +162,131 @@ func initConfig() {
 		home, err := homedir.Dir()
 		cobra.CheckErr(err)
 		// Optionally use $HOME/.config/lf-cli instead of just the home folder
-		viper.AddConfigPath(home + "/.config/lf-cli")
+		viper.AddConfigPath(filepath.Dir(defaultConfigFile()))
 		viper.AddConfigPath(home)
 		viper.SetConfigName(".lf-cli")
 		viper.SetConfigType("yaml")
 	}
 
-	viper.AutomaticEnv() // read in environment variables that match
-
 	// If a config file is found, read it in.
 	if err := viper.ReadInConfig(); err == nil {
-		// fmt.Fprintln(os.Stderr, "Using config file:", viper.ConfigFileUsed())
-		if flagNotSet(baseURL) {
-			baseURL = viper.GetString("lf-url")
+		cobra.CheckErr(viper.Unmarshal(&configFile))
+	}
+}
+
+// createsProfile marks commands which may name a profile that is not in the
+// config file yet
+const createsProfile = "creates-profile"
+
+// resolveConfig determines the connection settings cmd runs with
+func resolveConfig(cmd *cobra.Command) error {
+	var err error
+	activeConfig, err = internal.ResolveConfig(internal.ConfigSources{
+		Flags: internal.Profile{
+			Account: changedFlag("accountID", accountID),
+			Token:   changedFlag("token", token),
+			BaseURL: changedFlag("lf-url", baseURL),
+		},
+		ProfileFlag:           profile,
+		Getenv:                os.Getenv,
+		File:                  configFile,
+		DefaultCredentialFile: defaultCredentialFile(),
+		NewProfile:            cmd.Annotations[createsProfile] != "",
+	})
+	if err != nil {
+		return err
+	}
+
+	baseURL = activeConfig.BaseURL.Value
+	accountID = activeConfig.Account.Value
+	// A token_command is only run once a command needs the token, see loadToken
+	token = ""
+	if activeConfig.Token.Source != internal.SourceTokenCommand {
+		token = activeConfig.Token.Value
+	}
+	return nil
+}
+
+// loadToken runs the token_command or unlocks the credential file if no other
+// source provided a token. Only commands that call the API need to do this.
+func loadToken() error {
+	if err := activeConfig.LoadToken(readPassphrase); err != nil {
+		return err
+	}
+	token = activeConfig.Token.Value
+	return nil
+}
+
+// defaultConfigFile is where `lf-cli config init` writes the config file by default
+func defaultConfigFile() string {
+	home, err := homedir.Dir()
+	cobra.CheckErr(err)
+	return filepath.Join(home, ".config", "lf-cli", ".lf-cli.yaml")
+}
+
+// defaultCredentialFile is where `lf-cli login` stores tokens by default
+func defaultCredentialFile() string {
+	home, err := homedir.Dir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".config", "lf-cli", "credentials")
+}
+
+// readPassphrase returns LF_CLI_PASSPHRASE or prompts for the passphrase of the credential file
+func readPassphrase() ([]byte, error) {
+	if p, ok := os.LookupEnv(internal.EnvPrefix + "PASSPHRASE"); ok {
+		return []byte(p), nil
+	}
+	return promptSecret("Passphrase for " + activeConfig.CredentialFile.Value + ": ")
+}
+
+// promptSecret reads a line from the terminal without echoing it
+func promptSecret(prompt string) ([]byte, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return nil, fmt.Errorf("unable to prompt for %q, stdin is not a terminal", strings.TrimSpace(prompt))
+	}
+	fmt.Fprint(os.Stderr, prompt)
+	secret, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	return secret, err
+}
+
+// bindFlagsToEnv sets every flag that was not given on the command line from
+// its LF_CLI_* environment variable, e.g. --start-date from LF_CLI_START_DATE
+func bindFlagsToEnv(fs *pflag.FlagSet) {
+	fs.VisitAll(func(f *pflag.Flag) {
+		if f.Changed || connectionFlags[f.Name] || f.Name == "help" {
+			return
 		}
-		if flagNotSet(token) {
-			token = viper.GetString("token")
+		v, ok := os.LookupEnv(internal.EnvName(f.Name))
+		if !ok {
+			return
 		}
-		if flagNotSet(accountID) {
-			accountID = viper.GetString("account")
+		if err := fs.Set(f.Name, v); err != nil {
+			cobra.CheckErr(fmt.Errorf("invalid value %q for %s: %w", v, internal.EnvName(f.Name), err))
 		}
+		envFlags[f.Name] = true
+	})
+}
+
+// flagSource reports where the value of a flag came from
+func flagSource(f *pflag.Flag) string {
+	switch {
+	case envFlags[f.Name]:
+		return internal.SourceEnv
+	case f.Changed:
+		return internal.SourceFlag
+	default:
+		return internal.SourceDefault
 	}
 }
 
-func flagNotSet(flag string) bool {
-	return flag == ""
+// changedFlag returns the value of a persistent flag only if it was set by the user
+func changedFlag(name string, value string) string {
+	if rootCmd.PersistentFlags().Changed(name) {
+		return value
+	}
+	return ""
 }
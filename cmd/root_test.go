@@ -0,0 +1,59 @@
+/*
+Copyright © 2021 willbenica
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package cmd
+
+import (
+	"testing"
+
+	"github.com/spf13/pflag"
+)
+
+func TestBindFlagsToEnv(t *testing.T) {
+	t.Setenv("LF_CLI_START_DATE", "2020-01-01")
+	defer func() { envFlags = map[string]bool{} }()
+
+	// Report commands and get share the date variables
+	var date string
+	newFlags := func() *pflag.FlagSet {
+		fs := pflag.NewFlagSet("test", pflag.ContinueOnError)
+		fs.StringVarP(&date, "start-date", "s", "today", "")
+		return fs
+	}
+
+	cases := []struct {
+		name     string
+		args     []string
+		expected string
+	}{
+		{"flag before env", []string{"-s", "2021-05-01"}, "2021-05-01"},
+		{"env before default", nil, "2020-01-01"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			running := newFlags()
+			// get's flags are registered as well but not parsed
+			newFlags()
+			if err := running.Parse(c.args); err != nil {
+				t.Fatal(err)
+			}
+			bindFlagsToEnv(running)
+			if date != c.expected {
+				t.Errorf("expected %s, got %s", c.expected, date)
+			}
+		})
+	}
+}
@@ -0,0 +1,145 @@
+/*
+Copyright © 2021 willbenica
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/willbenica/lf-cli/internal"
+)
+
+var (
+	// scoreRulesFile is the YAML file of lead scoring rules
+	scoreRulesFile string
+	// scoreAsOf is the date recency and the visit window are measured from
+	scoreAsOf string
+)
+
+// scoreCmd represents the score command
+var scoreCmd = &cobra.Command{
+	Use:   "score --rules <rules file> [file...]",
+	Short: "Rank leads by a score computed from YAML rules",
+	Long: `Score leads with the rules of a YAML file and rank them, the highest score
+first, with the points each rule gave. A rule gives its points if its one
+condition holds:
+  industry         one of the industries
+  employees        the employee count is within min and max
+  country          one of the countries, by name or code
+  visits           the number of visits in the window is within min and max
+  page_views       the number of views of pages matching path (a glob) or of
+                   a content group in the window is within min and max (min 1 by default)
+  last_visit_days  the days since the last visit are within min and max
+
+  window_days: 30
+  rules:
+    - name: Target industry
+      industry: [Software, IT]
+      points: 20
+    - name: Mid-size
+      employees: {min: 50, max: 500}
+      points: 15
+    - name: Pricing interest
+      page_views: {path: "/pricing*", min: 2}
+      points: 25
+    - name: Recent
+      last_visit_days: {max: 7}
+      points: 10
+
+The window covers window_days days up to --as-of, by default --end-date or,
+when reading files, the day of the newest visit. Leads, locations and visits
+are read from files written by 'lf-cli get', or requested for --start-date to
+--end-date if no files are given:
+  lf-cli score --rules scoring.yaml -s 2021-05-01 -e 2021-05-31 --as-of 2021-05-31 --top 20
+  lf-cli score --rules scoring.yaml leads_*.json locations_*.json visits_*.json -o json`,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := validateReportFlags(); err != nil {
+			return err
+		}
+		if scoreRulesFile == "" {
+			return errors.New("--rules <rules file> is required")
+		}
+		if _, err := scoreDate(); err != nil {
+			return err
+		}
+		if err := loadContentGroups(); err != nil {
+			return err
+		}
+		return setFilter("leads")
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		rules, err := internal.LoadScoreRules(scoreRulesFile)
+		if err != nil {
+			return err
+		}
+		if rules.NeedsContentGroups() && contentGroups == nil {
+			return errors.New("the rules count views of content groups, add --content-groups <rules file>")
+		}
+		ds, err := loadDataset(args, "leads", "visits")
+		if err != nil {
+			return err
+		}
+		if len(ds.Leads) == 0 {
+			return errors.New("no leads to score, pass the leads and locations files")
+		}
+		asOf, _ := scoreDate()
+		if scoreAsOf == "" && len(args) > 0 {
+			if last, ok := internal.LastVisitDate(ds.Leads, ds.Visits); ok {
+				asOf = last
+			}
+		}
+		if ds, err = prepareDataset(ds); err != nil {
+			return err
+		}
+		scores := rules.ScoreLeads(ds.Leads, ds.Locations, ds.Visits, asOf)
+		if reportTop > 0 && len(scores) > reportTop {
+			scores = scores[:reportTop]
+		}
+		if err := writeReport(internal.ScoreTable(scores, rules), scores); err != nil {
+			return err
+		}
+		reportFiltered()
+		return nil
+	},
+}
+
+// scoreDate parses --as-of, which defaults to --end-date
+func scoreDate() (time.Time, error) {
+	date, flag := scoreAsOf, "--as-of"
+	if date == "" {
+		date, flag = endDate, "--end-date"
+	}
+	t, err := time.Parse("2006-01-02", internal.TodayOrDate(date))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q for %s, use YYYY-MM-DD or today", date, flag)
+	}
+	return t, nil
+}
+
+func init() {
+	rootCmd.AddCommand(scoreCmd)
+	scoreCmd.Flags().SortFlags = false
+
+	scoreCmd.Flags().StringVar(&scoreRulesFile, "rules", "", "YAML file of lead scoring rules")
+	scoreCmd.Flags().StringVar(&scoreAsOf, "as-of", "", "Date the visit window and the days since the last visit are measured from, by default --end-date or the newest visit in the files. Use YYYY-MM-DD or today")
+	addRangeFlags(scoreCmd)
+	addReportFlags(scoreCmd)
+	addWhereFlag(scoreCmd)
+	addContentGroupsFlag(scoreCmd)
+}
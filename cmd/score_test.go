@@ -0,0 +1,49 @@
+/*
+Copyright © 2021 willbenica
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package cmd
+
+import "testing"
+
+func TestScoreDate(t *testing.T) {
+	defer func() { scoreAsOf, endDate = "", "today" }()
+
+	cases := []struct {
+		asOf     string
+		end      string
+		expected string
+	}{
+		{"2021-05-31", "2021-05-01", "2021-05-31"},
+		{"", "2021-05-01", "2021-05-01"},
+		{"", "05/01/2021", `invalid date "05/01/2021" for --end-date, use YYYY-MM-DD or today`},
+		{"31.05.2021", "2021-05-01", `invalid date "31.05.2021" for --as-of, use YYYY-MM-DD or today`},
+	}
+	for _, c := range cases {
+		t.Run(c.asOf+"/"+c.end, func(t *testing.T) {
+			scoreAsOf, endDate = c.asOf, c.end
+			got, err := scoreDate()
+			if err != nil {
+				if err.Error() != c.expected {
+					t.Errorf("expected %s, got %s", c.expected, err)
+				}
+				return
+			}
+			if got.Format("2006-01-02") != c.expected {
+				t.Errorf("expected %s, got %s", c.expected, got.Format("2006-01-02"))
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+/*
+Copyright © 2021 willbenica
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/willbenica/lf-cli/internal"
+)
+
+// statsCmd represents the stats command
+var statsCmd = &cobra.Command{
+	Use:   "stats <campaigns|sources|mediums|keywords|domains|groups> [file...]",
+	Short: "Summarise visits by campaign, source, medium, keyword, referring domain or content group",
+	Long: `Group visits by campaign, source, medium, keyword, referring domain or
+content group (from --content-groups or the page_groups already in the files,
+a visit counts for each of its groups) and print the number of visits, unique
+leads, total and average visit length and the average page depth of each
+group, the busiest first.
+
+The visits are read from files written by 'lf-cli get visits', or requested
+for --start-date to --end-date if no files are given:
+  lf-cli stats campaigns -s 2021-05-01 -e 2021-05-31
+  lf-cli stats domains visits_from_2021-05-01.json -o csv
+  lf-cli stats sources visits_*.json --where 'page_depth > 1' --top 10
+  lf-cli stats groups visits_*.json --content-groups groups.yaml`,
+	ValidArgs: internal.Dimensions,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(args) == 0 {
+			return errors.New("a dimension is required: " + strings.Join(internal.Dimensions, ", "))
+		}
+		if !internal.IsValidDimension(args[0]) {
+			return fmt.Errorf("invalid dimension %q, use %s", args[0], strings.Join(internal.Dimensions, ", "))
+		}
+		return nil
+	},
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := validateReportFlags(); err != nil {
+			return err
+		}
+		if err := setFilter("visits"); err != nil {
+			return err
+		}
+		return loadContentGroups()
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ds, err := loadDataset(args[1:], "visits")
+		if err != nil {
+			return err
+		}
+		if args[0] == internal.DimensionGroups && contentGroups == nil && !hasPageGroups(ds.Visits) {
+			return errors.New("the visits have no page_groups, stats groups requires --content-groups <rules file>")
+		}
+		if ds, err = prepareDataset(ds); err != nil {
+			return err
+		}
+		stats, err := internal.VisitStats(ds.Visits, args[0])
+		if err != nil {
+			return err
+		}
+		if reportTop > 0 && len(stats) > reportTop {
+			stats = stats[:reportTop]
+		}
+		if err := writeReport(internal.StatsTable(stats, args[0]), stats); err != nil {
+			return err
+		}
+		reportFiltered()
+		return nil
+	},
+}
+
+// hasPageGroups tells whether any visit was assigned content groups when it was written
+func hasPageGroups(visits []internal.VisitData) bool {
+	for _, v := range visits {
+		if len(v.Attributes.PageGroups) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func init() {
+	rootCmd.AddCommand(statsCmd)
+	statsCmd.Flags().SortFlags = false
+
+	addRangeFlags(statsCmd)
+	addReportFlags(statsCmd)
+	addWhereFlag(statsCmd)
+	addContentGroupsFlag(statsCmd)
+}
@@ -0,0 +1,151 @@
+/*
+Copyright © 2021 willbenica
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/willbenica/lf-cli/internal"
+)
+
+// timelineLead limits the timeline to one lead ID
+var timelineLead string
+
+// timelineCmd represents the timeline command
+var timelineCmd = &cobra.Command{
+	Use:   "timeline [file...]",
+	Short: "Show the visits of each company in the order they happened",
+	Long: `Join visits to their leads and show one timeline per company: the lead,
+its location, the total time on site, pages viewed and sources, and every
+visit with its route in the order the visits started. The companies visited
+most recently come first.
+
+Leads, locations and visits are read from files written by 'lf-cli get', or
+requested for --start-date to --end-date if no files are given. -o json
+prints one document per company and line (NDJSON):
+  lf-cli timeline -s 2021-05-01 -e 2021-05-31 --top 10
+  lf-cli timeline leads_*.json locations_*.json visits_*.json --lead 184211
+  lf-cli timeline -s 2021-05-01 -o json --where 'quality >= 3'`,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := validateReportFlags(); err != nil {
+			return err
+		}
+		if reportOutput == reportCSV {
+			return errors.New("a timeline can't be printed as csv, use table or json")
+		}
+		if err := loadContentGroups(); err != nil {
+			return err
+		}
+		return setFilter("leads")
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ds, err := loadDataset(args, "leads", "visits")
+		if err != nil {
+			return err
+		}
+		if len(ds.Leads) == 0 {
+			return errors.New("no leads to join the visits to, pass the leads and locations files")
+		}
+		loaded := ds.Leads
+		if ds, err = prepareDataset(ds); err != nil {
+			return err
+		}
+		ds.Visits = dropFilteredVisits(ds.Visits, loaded, ds.Leads)
+		timelines, unmatched := internal.Timelines(ds.Leads, ds.Locations, ds.Visits)
+		if timelineLead != "" {
+			timelines = selectTimeline(timelines, timelineLead)
+			if len(timelines) == 0 {
+				return fmt.Errorf("no lead with ID %q", timelineLead)
+			}
+		}
+		if reportTop > 0 && len(timelines) > reportTop {
+			timelines = timelines[:reportTop]
+		}
+		if reportOutput == reportJSON {
+			err = writeTimelinesNDJSON(timelines)
+		} else {
+			err = internal.WriteTimelines(os.Stdout, timelines, terminalWidth())
+		}
+		if err != nil {
+			return err
+		}
+		if unmatched > 0 {
+			fmt.Fprintf(os.Stderr, "%d visits belong to leads which are not in the data\n", unmatched)
+		}
+		reportFiltered()
+		return nil
+	},
+}
+
+// writeTimelinesNDJSON prints one JSON document per company and line, so
+// large timelines can be streamed into jq
+func writeTimelinesNDJSON(timelines []internal.Timeline) error {
+	e := json.NewEncoder(os.Stdout)
+	e.SetEscapeHTML(false)
+	for _, t := range timelines {
+		if err := e.Encode(t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dropFilteredVisits removes the visits of loaded leads --where filtered out,
+// so only visits of leads which were never loaded count as unmatched
+func dropFilteredVisits(visits []internal.VisitData, loaded []internal.LeadData, kept []internal.LeadData) []internal.VisitData {
+	if len(loaded) == len(kept) {
+		return visits
+	}
+	dropped := map[string]bool{}
+	for _, l := range loaded {
+		dropped[l.ID] = true
+	}
+	for _, l := range kept {
+		delete(dropped, l.ID)
+	}
+	var result []internal.VisitData
+	for _, v := range visits {
+		if !dropped[v.Attributes.LeadID] {
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+func selectTimeline(timelines []internal.Timeline, id string) []internal.Timeline {
+	for _, t := range timelines {
+		if t.ID == id {
+			return []internal.Timeline{t}
+		}
+	}
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(timelineCmd)
+	timelineCmd.Flags().SortFlags = false
+
+	timelineCmd.Flags().StringVar(&timelineLead, "lead", "", "Show only the timeline of this lead ID")
+	addRangeFlags(timelineCmd)
+	addReportFlags(timelineCmd)
+	addWhereFlag(timelineCmd)
+	addContentGroupsFlag(timelineCmd)
+}
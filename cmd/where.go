@@ -0,0 +1,105 @@
+/*
+Copyright © 2021 willbenica
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/willbenica/lf-cli/internal"
+)
+
+var (
+	// where is the expression selecting the records to keep
+	where string
+	// filter is compiled from --where, nil keeps every record
+	filter *internal.Filter
+	// filterTotal and filterKept count the records seen and kept by filter
+	filterTotal, filterKept int
+)
+
+// addWhereFlag adds --where to cmd
+func addWhereFlag(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&where, "where", "", `Keep only records the expression is true for, e.g. 'quality >= 3 && status == "new"', 'industry in ["Software","IT"]' or 'any(visit_route, .page_path ~ "/pricing")'`)
+}
+
+// setFilter compiles --where for endpoint
+func setFilter(endpoint string) error {
+	filter, filterTotal, filterKept = nil, 0, 0
+	if where == "" {
+		return nil
+	}
+	f, err := internal.NewFilter(endpoint, where)
+	if err != nil {
+		return err
+	}
+	filter = f
+	return nil
+}
+
+// filterPage applies --where to the records of page and counts them
+func filterPage(page internal.EndPoint) (internal.EndPoint, error) {
+	if filter == nil {
+		return page, nil
+	}
+	filtered, err := filter.FilterResponse(page)
+	if err != nil {
+		return nil, err
+	}
+	filterTotal += recordCount(page)
+	filterKept += recordCount(filtered)
+	return filtered, nil
+}
+
+// filterDataset applies --where to the leads or visits of ds, whichever the
+// expression was compiled for, and counts them. Only the locations of the
+// kept leads remain.
+func filterDataset(ds internal.Dataset) (internal.Dataset, error) {
+	if filter == nil {
+		return ds, nil
+	}
+	var err error
+	if filter.Endpoint() == "leads" {
+		filterTotal += len(ds.Leads)
+		if ds.Leads, err = filter.FilterLeads(ds.Leads, ds.Locations); err != nil {
+			return ds, err
+		}
+		ds.Locations = internal.LeadLocations(ds.Leads, ds.Locations)
+		filterKept += len(ds.Leads)
+		return ds, nil
+	}
+	filterTotal += len(ds.Visits)
+	ds.Visits, err = filter.FilterVisits(ds.Visits)
+	filterKept += len(ds.Visits)
+	return ds, err
+}
+
+// recordCount returns the number of leads or visits in page
+func recordCount(page internal.EndPoint) int {
+	leads, _, visits, _ := page.GetData()
+	return len(leads) + len(visits)
+}
+
+// reportFiltered tells on stderr how many records --where filtered out,
+// stdout is left to the data
+func reportFiltered() {
+	if filter == nil {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "--where filtered out %d of %d records\n", filterTotal-filterKept, filterTotal)
+}
@@ -0,0 +1,237 @@
+/*
+Copyright © 2021 willbenica
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package internal
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// AttributionDimensions are the channels visits can be credited to
+var AttributionDimensions = []string{DimensionCampaigns, DimensionSources, DimensionMediums}
+
+// DefaultHalfLifeDays is the half-life of the time decay model
+const DefaultHalfLifeDays = 7.0
+
+// Views of an attribution report
+const (
+	AttributionByChannel = "channel"
+	AttributionByLead    = "lead"
+)
+
+// ChannelCredit is the share of a lead each model credits a channel with.
+// Per lead the credits of a model add up to 1.
+type ChannelCredit struct {
+	Channel    string  `json:"channel"`
+	Visits     int     `json:"visits"`
+	FirstTouch float64 `json:"first_touch"`
+	LastTouch  float64 `json:"last_touch"`
+	Linear     float64 `json:"linear"`
+	TimeDecay  float64 `json:"time_decay"`
+}
+
+// LeadAttribution credits the channels of one lead's visits
+type LeadAttribution struct {
+	LeadID   string          `json:"lead_id"`
+	Name     string          `json:"name"`
+	Channels []ChannelCredit `json:"channels"`
+}
+
+// ChannelAttribution is the credit of a channel summed over all leads, i.e.
+// how many leads each model attributes to it
+type ChannelAttribution struct {
+	ChannelCredit
+	Leads int `json:"leads"`
+}
+
+// IsValidAttributionDimension returns `true` if visits can be credited to dimension
+func IsValidAttributionDimension(dimension string) bool {
+	for _, d := range AttributionDimensions {
+		if d == dimension {
+			return true
+		}
+	}
+	return false
+}
+
+// Attribute credits the channels of each lead's visits, ordered by when they
+// started, with four models:
+//
+//	first_touch  all credit to the first visit
+//	last_touch   all credit to the last visit
+//	linear       equal credit to every visit
+//	time_decay   credit halving with every halfLife days a visit is older than the last one
+//
+// Visits without a lead ID are ignored, names are taken from leads if given.
+// Leads are sorted by name, their channels by linear credit.
+func Attribute(leads []LeadData, visits []VisitData, dimension string, halfLife float64) ([]LeadAttribution, error) {
+	if !IsValidAttributionDimension(dimension) {
+		return nil, fmt.Errorf("unknown dimension %q, use %s", dimension, strings.Join(AttributionDimensions, ", "))
+	}
+	if halfLife <= 0 {
+		return nil, fmt.Errorf("the half-life must be positive, got %v", halfLife)
+	}
+	names := map[string]string{}
+	for _, l := range leads {
+		names[l.ID] = l.Attributes.Name
+	}
+	byLead := map[string][]VisitData{}
+	var ids []string
+	for _, v := range visits {
+		id := v.Attributes.LeadID
+		if id == "" {
+			continue
+		}
+		if _, ok := byLead[id]; !ok {
+			ids = append(ids, id)
+		}
+		byLead[id] = append(byLead[id], v)
+	}
+
+	attributions := make([]LeadAttribution, 0, len(ids))
+	for _, id := range ids {
+		touches := byLead[id]
+		sort.SliceStable(touches, func(i, j int) bool {
+			return touches[i].Attributes.StartedAt.Before(touches[j].Attributes.StartedAt)
+		})
+		last := touches[len(touches)-1].Attributes.StartedAt
+		weights := make([]float64, len(touches))
+		var total float64
+		for i, v := range touches {
+			days := last.Sub(v.Attributes.StartedAt).Hours() / 24
+			weights[i] = math.Pow(2, -days/halfLife)
+			total += weights[i]
+		}
+
+		a := LeadAttribution{LeadID: id, Name: names[id]}
+		index := map[string]int{}
+		for i, v := range touches {
+			channel := DimensionValues(v, dimension)[0]
+			j, ok := index[channel]
+			if !ok {
+				j = len(a.Channels)
+				index[channel] = j
+				a.Channels = append(a.Channels, ChannelCredit{Channel: channel})
+			}
+			c := &a.Channels[j]
+			c.Visits++
+			if i == 0 {
+				c.FirstTouch = 1
+			}
+			if i == len(touches)-1 {
+				c.LastTouch = 1
+			}
+			c.Linear += 1 / float64(len(touches))
+			c.TimeDecay += weights[i] / total
+		}
+		sortCredits(a.Channels)
+		attributions = append(attributions, a)
+	}
+	sort.SliceStable(attributions, func(i, j int) bool {
+		if attributions[i].Name != attributions[j].Name {
+			return attributions[i].Name < attributions[j].Name
+		}
+		return attributions[i].LeadID < attributions[j].LeadID
+	})
+	return attributions, nil
+}
+
+// ByChannel sums the credits of all leads per channel
+func ByChannel(attributions []LeadAttribution) []ChannelAttribution {
+	index := map[string]int{}
+	var channels []ChannelAttribution
+	for _, a := range attributions {
+		for _, c := range a.Channels {
+			i, ok := index[c.Channel]
+			if !ok {
+				i = len(channels)
+				index[c.Channel] = i
+				channels = append(channels, ChannelAttribution{ChannelCredit: ChannelCredit{Channel: c.Channel}})
+			}
+			s := &channels[i]
+			s.Leads++
+			s.Visits += c.Visits
+			s.FirstTouch += c.FirstTouch
+			s.LastTouch += c.LastTouch
+			s.Linear += c.Linear
+			s.TimeDecay += c.TimeDecay
+		}
+	}
+	for i := range channels {
+		channels[i].ChannelCredit = channels[i].ChannelCredit.rounded()
+	}
+	sort.SliceStable(channels, func(i, j int) bool {
+		if channels[i].Linear != channels[j].Linear {
+			return channels[i].Linear > channels[j].Linear
+		}
+		return channels[i].Channel < channels[j].Channel
+	})
+	return channels
+}
+
+// RoundCredits rounds the credits of each lead to two decimals for reports
+func RoundCredits(attributions []LeadAttribution) {
+	for _, a := range attributions {
+		for i, c := range a.Channels {
+			a.Channels[i] = c.rounded()
+		}
+	}
+}
+
+func (c ChannelCredit) rounded() ChannelCredit {
+	c.FirstTouch, c.LastTouch = round2(c.FirstTouch), round2(c.LastTouch)
+	c.Linear, c.TimeDecay = round2(c.Linear), round2(c.TimeDecay)
+	return c
+}
+
+func sortCredits(credits []ChannelCredit) {
+	sort.SliceStable(credits, func(i, j int) bool {
+		if credits[i].Linear != credits[j].Linear {
+			return credits[i].Linear > credits[j].Linear
+		}
+		return credits[i].Channel < credits[j].Channel
+	})
+}
+
+// ChannelAttributionTable flattens the credits per channel, the first column
+// is named after the dimension, e.g. campaign
+func ChannelAttributionTable(channels []ChannelAttribution, dimension string) Table {
+	t := Table{Columns: []string{strings.TrimSuffix(dimension, "s"), "leads", "visits", "first_touch", "last_touch", "linear", "time_decay"}}
+	for _, c := range channels {
+		t.Rows = append(t.Rows, append([]string{c.Channel, strconv.Itoa(c.Leads)}, c.ChannelCredit.cells()...))
+	}
+	return t
+}
+
+// LeadAttributionTable flattens the credits with a row per lead and channel
+func LeadAttributionTable(attributions []LeadAttribution, dimension string) Table {
+	t := Table{Columns: []string{"lead_id", "name", strings.TrimSuffix(dimension, "s"), "visits", "first_touch", "last_touch", "linear", "time_decay"}}
+	for _, a := range attributions {
+		for _, c := range a.Channels {
+			t.Rows = append(t.Rows, append([]string{a.LeadID, a.Name, c.Channel}, c.cells()...))
+		}
+	}
+	return t
+}
+
+func (c ChannelCredit) cells() []string {
+	return []string{strconv.Itoa(c.Visits), formatFloat(c.FirstTouch), formatFloat(c.LastTouch), formatFloat(c.Linear), formatFloat(c.TimeDecay)}
+}
@@ -0,0 +1,100 @@
+/*
+Copyright © 2021 willbenica
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package internal
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func attributionTestVisits() []VisitData {
+	at := func(day int) time.Time { return time.Date(2021, 5, day, 12, 0, 0, 0, time.UTC) }
+	return []VisitData{
+		{Attributes: VisitAttributes{LeadID: "a", StartedAt: at(15), Source: "linkedin"}},
+		{Attributes: VisitAttributes{LeadID: "a", StartedAt: at(1), Source: "google"}},
+		{Attributes: VisitAttributes{LeadID: "a", StartedAt: at(8), Source: "google"}},
+		{Attributes: VisitAttributes{LeadID: "b", StartedAt: at(3)}},
+		{Attributes: VisitAttributes{StartedAt: at(3), Source: "google"}},
+	}
+}
+
+func TestAttribute(t *testing.T) {
+	leads := []LeadData{{ID: "a", Attributes: LeadAttributes{Name: "Acme"}}}
+	attributions, err := Attribute(leads, attributionTestVisits(), DimensionSources, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	RoundCredits(attributions)
+	// The visits of a are 14 and 7 days older than its last one, weighing 1/4, 1/2 and 1
+	expected := []LeadAttribution{
+		{LeadID: "b", Channels: []ChannelCredit{{Channel: NoValue, Visits: 1, FirstTouch: 1, LastTouch: 1, Linear: 1, TimeDecay: 1}}},
+		{LeadID: "a", Name: "Acme", Channels: []ChannelCredit{
+			{Channel: "google", Visits: 2, FirstTouch: 1, Linear: 0.67, TimeDecay: 0.43},
+			{Channel: "linkedin", Visits: 1, LastTouch: 1, Linear: 0.33, TimeDecay: 0.57},
+		}},
+	}
+	if !reflect.DeepEqual(attributions, expected) {
+		t.Errorf("expected\n%+v\ngot\n%+v", expected, attributions)
+	}
+
+	if _, err := Attribute(nil, nil, DimensionKeywords, 7); err == nil {
+		t.Error("expected an error for a dimension which can't be attributed")
+	}
+	if _, err := Attribute(nil, nil, DimensionSources, 0); err == nil {
+		t.Error("expected an error for a half-life of 0")
+	}
+}
+
+func TestByChannel(t *testing.T) {
+	attributions, err := Attribute(nil, attributionTestVisits(), DimensionSources, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	channels := ByChannel(attributions)
+	expected := []ChannelAttribution{
+		{ChannelCredit: ChannelCredit{Channel: NoValue, Visits: 1, FirstTouch: 1, LastTouch: 1, Linear: 1, TimeDecay: 1}, Leads: 1},
+		{ChannelCredit: ChannelCredit{Channel: "google", Visits: 2, FirstTouch: 1, Linear: 0.67, TimeDecay: 0.43}, Leads: 1},
+		{ChannelCredit: ChannelCredit{Channel: "linkedin", Visits: 1, LastTouch: 1, Linear: 0.33, TimeDecay: 0.57}, Leads: 1},
+	}
+	if !reflect.DeepEqual(channels, expected) {
+		t.Errorf("expected\n%+v\ngot\n%+v", expected, channels)
+	}
+
+	table := ChannelAttributionTable(channels[1:2], DimensionSources)
+	expectedTable := Table{
+		Columns: []string{"source", "leads", "visits", "first_touch", "last_touch", "linear", "time_decay"},
+		Rows:    [][]string{{"google", "1", "2", "1", "0", "0.67", "0.43"}},
+	}
+	if !reflect.DeepEqual(table, expectedTable) {
+		t.Errorf("expected %v, got %v", expectedTable, table)
+	}
+}
+
+func TestLeadAttributionTable(t *testing.T) {
+	attributions := []LeadAttribution{{LeadID: "a", Name: "Acme", Channels: []ChannelCredit{
+		{Channel: "spring", Visits: 2, FirstTouch: 1, LastTouch: 1, Linear: 1, TimeDecay: 1},
+	}}}
+	expected := Table{
+		Columns: []string{"lead_id", "name", "campaign", "visits", "first_touch", "last_touch", "linear", "time_decay"},
+		Rows:    [][]string{{"a", "Acme", "spring", "2", "1", "1", "1", "1"}},
+	}
+	if table := LeadAttributionTable(attributions, DimensionCampaigns); !reflect.DeepEqual(table, expected) {
+		t.Errorf("expected %v, got %v", expected, table)
+	}
+}
@@ -0,0 +1,239 @@
+/*
+Copyright © 2021 willbenica
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package internal
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Periods of a comparison
+const (
+	PeriodWeek  = "week"
+	PeriodMonth = "month"
+)
+
+// Window is a range of days, both ends included, as YYYY-MM-DD
+type Window struct {
+	Start string `json:"start"`
+	End   string `json:"end"`
+}
+
+// Change compares a value in the previous and the current window. Percent is
+// the change relative to the previous value, nil if that was 0.
+type Change struct {
+	Name     string   `json:"name"`
+	Previous float64  `json:"previous"`
+	Current  float64  `json:"current"`
+	Delta    float64  `json:"delta"`
+	Percent  *float64 `json:"percent"`
+}
+
+// Comparison compares two aligned windows
+type Comparison struct {
+	Period     string   `json:"period"`
+	Previous   Window   `json:"previous"`
+	Current    Window   `json:"current"`
+	Metrics    []Change `json:"metrics"`
+	TopSources []Change `json:"top_sources"`
+	TopPages   []Change `json:"top_pages"`
+}
+
+// CompareWindows returns the window from the start of the week (starting on
+// Monday) or month up to asOf, and the same days of the week or month before.
+// In a shorter month the previous window ends on its last day.
+func CompareWindows(period string, asOf time.Time) (Window, Window, error) {
+	day := time.Date(asOf.Year(), asOf.Month(), asOf.Day(), 0, 0, 0, 0, time.UTC)
+	var start, prevStart, prevEnd time.Time
+	switch period {
+	case PeriodWeek:
+		start = day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+		prevStart, prevEnd = start.AddDate(0, 0, -7), day.AddDate(0, 0, -7)
+	case PeriodMonth:
+		start = day.AddDate(0, 0, 1-day.Day())
+		prevStart = start.AddDate(0, -1, 0)
+		prevEnd = prevStart.AddDate(0, 0, day.Day()-1)
+		if last := start.AddDate(0, 0, -1); prevEnd.After(last) {
+			prevEnd = last
+		}
+	default:
+		return Window{}, Window{}, fmt.Errorf("unknown period %q, use %s or %s", period, PeriodWeek, PeriodMonth)
+	}
+	const layout = "2006-01-02"
+	return Window{prevStart.Format(layout), prevEnd.Format(layout)}, Window{start.Format(layout), day.Format(layout)}, nil
+}
+
+// Contains reports whether the day YYYY-MM-DD is in the window
+func (w Window) Contains(date string) bool {
+	return date >= w.Start && date <= w.End
+}
+
+// Select returns the records of the window: the visits on its days and the
+// leads visiting between their first and last visit date during it
+func (w Window) Select(ds Dataset) Dataset {
+	var out Dataset
+	locations := map[string]bool{}
+	for _, l := range ds.Leads {
+		a := l.Attributes
+		if (a.FirstVisitDate == "" || a.FirstVisitDate <= w.End) && (a.LastVisitDate == "" || a.LastVisitDate >= w.Start) {
+			out.Leads = append(out.Leads, l)
+			locations[l.Relationships.Location.Data.ID] = true
+		}
+	}
+	for _, loc := range ds.Locations {
+		if locations[loc.ID] {
+			out.Locations = append(out.Locations, loc)
+		}
+	}
+	for _, v := range ds.Visits {
+		if w.Contains(visitDate(v.Attributes)) {
+			out.Visits = append(out.Visits, v)
+		}
+	}
+	return out
+}
+
+// visitDate returns the date of a visit in the time zone of the account,
+// falling back to when it started
+func visitDate(a VisitAttributes) string {
+	if a.Date != "" {
+		return a.Date
+	}
+	return a.StartedAt.Format("2006-01-02")
+}
+
+// Compare computes the changes between the windows: new leads (first visit in
+// the window), visits, unique companies (leads with visits), average lead
+// quality, and the visits of the top sources and views of the top pages
+func Compare(period string, previousWindow Window, currentWindow Window, previous Dataset, current Dataset, top int) Comparison {
+	c := Comparison{Period: period, Previous: previousWindow, Current: currentWindow}
+	p, n := summarize(previousWindow, previous), summarize(currentWindow, current)
+	c.Metrics = []Change{
+		newChange("new_leads", float64(p.newLeads), float64(n.newLeads)),
+		newChange("visits", float64(p.visits), float64(n.visits)),
+		newChange("unique_companies", float64(p.companies), float64(n.companies)),
+		newChange("avg_quality", p.avgQuality, n.avgQuality),
+	}
+	c.TopSources = topChanges(p.sources, n.sources, top)
+	c.TopPages = topChanges(p.pages, n.pages, top)
+	return c
+}
+
+// windowSummary are the numbers of one window
+type windowSummary struct {
+	newLeads   int
+	visits     int
+	companies  int
+	avgQuality float64
+	sources    map[string]int
+	pages      map[string]int
+}
+
+func summarize(w Window, ds Dataset) windowSummary {
+	s := windowSummary{visits: len(ds.Visits), sources: map[string]int{}, pages: map[string]int{}}
+	quality := 0
+	for _, l := range ds.Leads {
+		if w.Contains(l.Attributes.FirstVisitDate) {
+			s.newLeads++
+		}
+		quality += l.Attributes.Quality
+	}
+	if len(ds.Leads) > 0 {
+		s.avgQuality = round2(float64(quality) / float64(len(ds.Leads)))
+	}
+	companies := map[string]bool{}
+	for _, v := range ds.Visits {
+		if v.Attributes.LeadID != "" {
+			companies[v.Attributes.LeadID] = true
+		}
+		s.sources[DimensionValues(v, DimensionSources)[0]]++
+		for _, step := range v.Attributes.VisitRoute {
+			s.pages[step.PagePath]++
+		}
+	}
+	s.companies = len(companies)
+	return s
+}
+
+func newChange(name string, previous float64, current float64) Change {
+	c := Change{Name: name, Previous: previous, Current: current, Delta: round2(current - previous)}
+	if previous != 0 {
+		percent := round2((current - previous) * 100 / previous)
+		c.Percent = &percent
+	}
+	return c
+}
+
+// topChanges compares the top names of the current window, then of the
+// previous one, all of them if top is 0
+func topChanges(previous map[string]int, current map[string]int, top int) []Change {
+	var names []string
+	for name := range current {
+		names = append(names, name)
+	}
+	for name := range previous {
+		if _, ok := current[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Slice(names, func(i, j int) bool {
+		a, b := names[i], names[j]
+		if current[a] != current[b] {
+			return current[a] > current[b]
+		}
+		if previous[a] != previous[b] {
+			return previous[a] > previous[b]
+		}
+		return a < b
+	})
+	if top > 0 && len(names) > top {
+		names = names[:top]
+	}
+	changes := []Change{}
+	for _, name := range names {
+		changes = append(changes, newChange(name, float64(previous[name]), float64(current[name])))
+	}
+	return changes
+}
+
+// Table flattens the comparison with a row per metric, source and page
+func (c Comparison) Table() Table {
+	t := Table{Columns: []string{"metric", "previous", "current", "change", "change_pct"}}
+	add := func(prefix string, changes []Change) {
+		for _, ch := range changes {
+			percent := "n/a"
+			if ch.Percent != nil {
+				percent = formatSigned(*ch.Percent) + "%"
+			}
+			t.Rows = append(t.Rows, []string{prefix + ch.Name, formatFloat(ch.Previous), formatFloat(ch.Current), formatSigned(ch.Delta), percent})
+		}
+	}
+	add("", c.Metrics)
+	add("source: ", c.TopSources)
+	add("page: ", c.TopPages)
+	return t
+}
+
+// formatSigned formats a change with its sign, e.g. +3 or -1.5
+func formatSigned(f float64) string {
+	if f > 0 {
+		return "+" + formatFloat(f)
+	}
+	return formatFloat(f)
+}
@@ -0,0 +1,137 @@
+/*
+Copyright © 2021 willbenica
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package internal
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCompareWindows(t *testing.T) {
+	cases := []struct {
+		period             string
+		asOf               string
+		previous, expected Window
+	}{
+		// 2021-05-26 is a Wednesday
+		{PeriodWeek, "2021-05-26", Window{"2021-05-17", "2021-05-19"}, Window{"2021-05-24", "2021-05-26"}},
+		{PeriodWeek, "2021-05-24", Window{"2021-05-17", "2021-05-17"}, Window{"2021-05-24", "2021-05-24"}},
+		{PeriodWeek, "2021-05-30", Window{"2021-05-17", "2021-05-23"}, Window{"2021-05-24", "2021-05-30"}},
+		{PeriodMonth, "2021-05-12", Window{"2021-04-01", "2021-04-12"}, Window{"2021-05-01", "2021-05-12"}},
+		{PeriodMonth, "2021-05-31", Window{"2021-04-01", "2021-04-30"}, Window{"2021-05-01", "2021-05-31"}},
+		{PeriodMonth, "2021-03-30", Window{"2021-02-01", "2021-02-28"}, Window{"2021-03-01", "2021-03-30"}},
+		{PeriodMonth, "2021-01-15", Window{"2020-12-01", "2020-12-15"}, Window{"2021-01-01", "2021-01-15"}},
+	}
+	for _, c := range cases {
+		asOf, _ := time.Parse("2006-01-02", c.asOf)
+		previous, current, err := CompareWindows(c.period, asOf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if previous != c.previous || current != c.expected {
+			t.Errorf("%s %s: expected %v and %v, got %v and %v", c.period, c.asOf, c.previous, c.expected, previous, current)
+		}
+	}
+	if _, _, err := CompareWindows("year", time.Now()); err == nil {
+		t.Error("expected an error for an unknown period")
+	}
+}
+
+func compareTestData() Dataset {
+	lead := func(id string, first string, last string, quality int) LeadData {
+		return LeadData{ID: id, Attributes: LeadAttributes{FirstVisitDate: first, LastVisitDate: last, Quality: quality}}
+	}
+	visit := func(lead string, date string, source string, pages ...string) VisitData {
+		v := VisitData{Attributes: VisitAttributes{LeadID: lead, Date: date, Source: source}}
+		for _, p := range pages {
+			v.Attributes.VisitRoute = append(v.Attributes.VisitRoute, VisitRoute{PagePath: p})
+		}
+		return v
+	}
+	return Dataset{
+		Leads: []LeadData{
+			lead("a", "2021-05-01", "2021-05-25", 4),
+			lead("b", "2021-05-18", "2021-05-18", 2),
+			lead("c", "2021-05-24", "2021-05-26", 3),
+		},
+		Visits: []VisitData{
+			visit("a", "2021-05-17", "google", "/", "/pricing"),
+			visit("b", "2021-05-18", "google", "/"),
+			visit("a", "2021-05-25", "linkedin", "/pricing"),
+			visit("c", "2021-05-24", "", "/", "/blog"),
+			visit("c", "2021-05-26", "google", "/blog"),
+			visit("c", "2021-05-27", "google", "/blog"),
+		},
+	}
+}
+
+func TestWindowSelect(t *testing.T) {
+	ds := Window{"2021-05-24", "2021-05-26"}.Select(compareTestData())
+	var leads []string
+	for _, l := range ds.Leads {
+		leads = append(leads, l.ID)
+	}
+	if expected := []string{"a", "c"}; !reflect.DeepEqual(leads, expected) {
+		t.Errorf("expected leads %v, got %v", expected, leads)
+	}
+	if len(ds.Visits) != 3 {
+		t.Errorf("expected 3 visits, got %d", len(ds.Visits))
+	}
+}
+
+func TestCompare(t *testing.T) {
+	ds := compareTestData()
+	previousWindow, currentWindow := Window{"2021-05-17", "2021-05-19"}, Window{"2021-05-24", "2021-05-26"}
+	c := Compare(PeriodWeek, previousWindow, currentWindow, previousWindow.Select(ds), currentWindow.Select(ds), 2)
+
+	percent := func(f float64) *float64 { return &f }
+	expected := []Change{
+		{Name: "new_leads", Previous: 1, Current: 1, Delta: 0, Percent: percent(0)},
+		{Name: "visits", Previous: 2, Current: 3, Delta: 1, Percent: percent(50)},
+		{Name: "unique_companies", Previous: 2, Current: 2, Delta: 0, Percent: percent(0)},
+		{Name: "avg_quality", Previous: 3, Current: 3.5, Delta: 0.5, Percent: percent(16.67)},
+	}
+	if !reflect.DeepEqual(c.Metrics, expected) {
+		t.Errorf("expected\n%+v\ngot\n%+v", expected, c.Metrics)
+	}
+	expectedSources := []Change{
+		{Name: "google", Previous: 2, Current: 1, Delta: -1, Percent: percent(-50)},
+		{Name: NoValue, Previous: 0, Current: 1, Delta: 1},
+	}
+	if !reflect.DeepEqual(c.TopSources, expectedSources) {
+		t.Errorf("expected\n%+v\ngot\n%+v", expectedSources, c.TopSources)
+	}
+
+	table := c.Table()
+	if expected := []string{"metric", "previous", "current", "change", "change_pct"}; !reflect.DeepEqual(table.Columns, expected) {
+		t.Errorf("expected columns %v, got %v", expected, table.Columns)
+	}
+	expectedRows := [][]string{
+		{"visits", "2", "3", "+1", "+50%"},
+		{"avg_quality", "3", "3.5", "+0.5", "+16.67%"},
+		{"source: google", "2", "1", "-1", "-50%"},
+		{"source: (none)", "0", "1", "+1", "n/a"},
+		{"page: /blog", "0", "2", "+2", "n/a"},
+		{"page: /", "2", "1", "-1", "-50%"},
+	}
+	rows := [][]string{table.Rows[1], table.Rows[3], table.Rows[4], table.Rows[5], table.Rows[6], table.Rows[7]}
+	if len(table.Rows) != 8 || !reflect.DeepEqual(rows, expectedRows) {
+		t.Errorf("expected rows %v, got %v", expectedRows, table.Rows)
+	}
+}
@@ -0,0 +1,189 @@
+/*
+Copyright © 2021 willbenica
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package internal
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Compressions of output streams, CompressNone writes plain text
+const (
+	CompressNone = ""
+	CompressGzip = "gzip"
+	CompressZstd = "zstd"
+)
+
+// Magic numbers at the start of compressed files
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// IsValidCompression returns `true` if compression is supported
+func IsValidCompression(compression string) bool {
+	switch compression {
+	case CompressNone, CompressGzip, CompressZstd:
+		return true
+	}
+	return false
+}
+
+// CompressionSuffix returns the file name suffix of compression, e.g. ".gz"
+func CompressionSuffix(compression string) string {
+	switch compression {
+	case CompressGzip:
+		return ".gz"
+	case CompressZstd:
+		return ".zst"
+	}
+	return ""
+}
+
+// nopWriteCloser turns a writer into an io.WriteCloser whose Close does nothing
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+// NewCompressWriter compresses everything written to it into w. Close
+// flushes the compressed stream, it does not close w.
+func NewCompressWriter(w io.Writer, compression string) (io.WriteCloser, error) {
+	switch compression {
+	case CompressNone:
+		return nopWriteCloser{w}, nil
+	case CompressGzip:
+		return gzip.NewWriter(w), nil
+	case CompressZstd:
+		return zstd.NewWriter(w)
+	}
+	return nil, fmt.Errorf("unknown compression %q, use gzip or zstd", compression)
+}
+
+// decompressReader closes the decompressor and the underlying file
+type decompressReader struct {
+	io.Reader
+	closers []func() error
+}
+
+func (d decompressReader) Close() error {
+	var err error
+	for _, c := range d.closers {
+		if e := c(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// NewDecompressReader returns a reader of the decompressed content of r.
+// The compression (gzip, zstd or none) is detected from the first bytes.
+func NewDecompressReader(r io.Reader) (io.ReadCloser, error) {
+	br := bufio.NewReader(r)
+	magic, _ := br.Peek(len(zstdMagic))
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		gr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		return decompressReader{gr, []func() error{gr.Close}}, nil
+	case bytes.HasPrefix(magic, zstdMagic):
+		zr, err := zstd.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		return decompressReader{zr, []func() error{func() error { zr.Close(); return nil }}}, nil
+	}
+	return decompressReader{Reader: br}, nil
+}
+
+// OpenFile opens a file written by lf-cli, decompressing it if needed
+func OpenFile(path string) (io.ReadCloser, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	r, err := NewDecompressReader(f)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	d := r.(decompressReader)
+	d.closers = append(d.closers, f.Close)
+	return d, nil
+}
+
+// compressedFile compresses what is written to it into a file
+type compressedFile struct {
+	io.WriteCloser
+	file   *os.File
+	closed bool
+}
+
+// Close ends the compressed stream and closes the file. Calling it again does nothing.
+func (c *compressedFile) Close() error {
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	err := c.WriteCloser.Close()
+	if err == nil {
+		err = c.file.Sync()
+	}
+	if e := c.file.Close(); err == nil {
+		err = e
+	}
+	return err
+}
+
+// CreateCompressedFile creates filename under path and returns a writer
+// compressing with compression as data is written to it. Close has to be
+// called to end the compressed stream.
+func CreateCompressedFile(path string, filename string, compression string) (io.WriteCloser, error) {
+	file, err := CreateFile(path, filename)
+	if err != nil {
+		return nil, err
+	}
+	w, err := NewCompressWriter(file, compression)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &compressedFile{WriteCloser: w, file: file}, nil
+}
+
+// WriteCompressedFile writes data to filename under path, compressed with compression
+func WriteCompressedFile(path string, filename string, data string, compression string) error {
+	w, err := CreateCompressedFile(path, filename, compression)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	if _, err := io.WriteString(w, data); err != nil {
+		return err
+	}
+	return w.Close()
+}
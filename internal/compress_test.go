@@ -0,0 +1,75 @@
+/*
+Copyright © 2021 willbenica
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package internal
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCompressedFilesAreReadBack(t *testing.T) {
+	ds, err := ReadDataFiles(TEST_FOLDER + V1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := Visits{Data: ds.Visits}.GetAllData()
+	dir := t.TempDir()
+
+	for _, compression := range []string{CompressNone, CompressGzip, CompressZstd} {
+		t.Run(compression, func(t *testing.T) {
+			name := "visits.json" + CompressionSuffix(compression)
+			if err := WriteCompressedFile(dir, name, data, compression); err != nil {
+				t.Fatal(err)
+			}
+			raw, err := ioutil.ReadFile(filepath.Join(dir, name))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if compressed := string(raw) != data; compressed != (compression != CompressNone) {
+				t.Errorf("expected the file to be compressed: %v", compression != CompressNone)
+			}
+
+			f, err := OpenFile(filepath.Join(dir, name))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			got, err := ioutil.ReadAll(f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != data {
+				t.Errorf("the decompressed data differs from the written data")
+			}
+
+			read, err := ReadDataFiles(filepath.Join(dir, name))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(read.Visits) != len(ds.Visits) {
+				t.Errorf("expected %d visits, got %d", len(ds.Visits), len(read.Visits))
+			}
+		})
+	}
+
+	if _, err := OpenFile(filepath.Join(dir, "missing.json.gz")); !os.IsNotExist(err) {
+		t.Errorf("expected a not exist error, got %v", err)
+	}
+}
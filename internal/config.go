@@ -0,0 +1,199 @@
+/*
+Copyright © 2021 willbenica
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package internal
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// EnvPrefix is prepended to every environment variable read by lf-cli
+	EnvPrefix = "LF_CLI_"
+	// DefaultBaseURL is used when no lf-url is configured anywhere
+	DefaultBaseURL = "https://api.leadfeeder.com"
+)
+
+// Sources a setting can be resolved from, from highest to lowest precedence
+const (
+	SourceFlag    = "flag"
+	SourceEnv     = "env"
+	SourceProfile = "profile"
+	SourceConfig  = "config"
+	SourceDefault = "default"
+)
+
+// Profile holds the connection settings for a single leadfeeder account
+type Profile struct {
+	Account string `mapstructure:"account"`
+	Token   string `mapstructure:"token"`
+	BaseURL string `mapstructure:"lf-url"`
+	// TokenCommand is run with the shell to print the token, e.g. `pass show lf-cli`
+	TokenCommand string `mapstructure:"token_command"`
+	// CredentialFile is the encrypted file written by `lf-cli login`
+	CredentialFile string `mapstructure:"credential_file"`
+}
+
+// ConfigFile maps the contents of .lf-cli.yaml. The top level keys act as the
+// default profile, named profiles live under `profiles:`
+type ConfigFile struct {
+	Profile        `mapstructure:",squash"`
+	DefaultProfile string             `mapstructure:"default-profile"`
+	Profiles       map[string]Profile `mapstructure:"profiles"`
+}
+
+// ProfileNames returns the names of all profiles in the config file, sorted
+func (c ConfigFile) ProfileNames() []string {
+	names := make([]string, 0, len(c.Profiles))
+	for name := range c.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// GetProfile returns the profile called name. Profile names are case-insensitive
+// because viper lowercases all keys when reading the config file.
+func (c ConfigFile) GetProfile(name string) (Profile, error) {
+	p, ok := c.Profiles[strings.ToLower(name)]
+	if !ok {
+		return Profile{}, fmt.Errorf("profile %q not found in config file", name)
+	}
+	return p, nil
+}
+
+// Setting is a resolved configuration value together with where it came from
+type Setting struct {
+	Value  string
+	Source string
+}
+
+// Resolve returns the first candidate with a non-empty value, candidates
+// should be passed in order of precedence
+func Resolve(candidates ...Setting) Setting {
+	for _, c := range candidates {
+		if c.Value != "" {
+			return c
+		}
+	}
+	return Setting{}
+}
+
+// Config is the effective connection configuration used for API requests
+type Config struct {
+	Profile Setting
+	Account Setting
+	// Token holds the command to run instead of the token when its Source is
+	// SourceTokenCommand, see LoadToken
+	Token          Setting
+	BaseURL        Setting
+	CredentialFile Setting
+}
+
+// ConfigSources bundles every place a connection setting can be read from
+type ConfigSources struct {
+	// Flags holds values passed on the command line, empty when a flag is unset
+	Flags Profile
+	// ProfileFlag is the value of --profile
+	ProfileFlag string
+	// Getenv is used to look up environment variables, e.g. os.Getenv
+	Getenv func(string) string
+	File   ConfigFile
+	// DefaultCredentialFile is used when no credential_file is configured
+	DefaultCredentialFile string
+	// NewProfile accepts a profile which is not in the config file yet, for
+	// commands that create it
+	NewProfile bool
+}
+
+// ResolveConfig determines the effective configuration.
+//
+// The profile is selected by --profile > LF_CLI_PROFILE > default-profile.
+// Each value is then taken from flag > env > profile > default, where default
+// is the top level of the config file followed by the built-in default. Within
+// the profile and the top level a plain token beats a token_command. If no
+// token is found at all, LoadToken falls back to the credential file.
+func ResolveConfig(s ConfigSources) (Config, error) {
+	Init()
+	getenv := s.Getenv
+	if getenv == nil {
+		getenv = func(string) string { return "" }
+	}
+
+	var c Config
+	c.Profile = Resolve(
+		Setting{s.ProfileFlag, SourceFlag},
+		Setting{getenv(EnvName("profile")), SourceEnv},
+		Setting{s.File.DefaultProfile, SourceConfig},
+	)
+
+	var p Profile
+	if c.Profile.Value != "" {
+		var err error
+		p, err = s.File.GetProfile(c.Profile.Value)
+		if err != nil && !s.NewProfile {
+			return Config{}, err
+		}
+		logger.Debug("Using profile", zap.String("profile", c.Profile.Value), zap.String("source", c.Profile.Source))
+	}
+
+	c.Account = Resolve(
+		Setting{s.Flags.Account, SourceFlag},
+		Setting{getenv(EnvName("account")), SourceEnv},
+		Setting{p.Account, SourceProfile},
+		Setting{s.File.Account, SourceConfig},
+	)
+	c.Token = Resolve(
+		Setting{s.Flags.Token, SourceFlag},
+		Setting{getenv(EnvName("token")), SourceEnv},
+		Setting{p.Token, SourceProfile},
+		Setting{p.TokenCommand, SourceTokenCommand},
+		Setting{s.File.Token, SourceConfig},
+		Setting{s.File.TokenCommand, SourceTokenCommand},
+	)
+	c.BaseURL = Resolve(
+		Setting{s.Flags.BaseURL, SourceFlag},
+		Setting{getenv(EnvName("lf-url")), SourceEnv},
+		Setting{p.BaseURL, SourceProfile},
+		Setting{s.File.BaseURL, SourceConfig},
+		Setting{DefaultBaseURL, SourceDefault},
+	)
+	c.CredentialFile = Resolve(
+		Setting{p.CredentialFile, SourceProfile},
+		Setting{s.File.CredentialFile, SourceConfig},
+		Setting{s.DefaultCredentialFile, SourceDefault},
+	)
+	return c, nil
+}
+
+// EnvName returns the environment variable bound to a flag or config key,
+// e.g. start-date becomes LF_CLI_START_DATE
+func EnvName(key string) string {
+	return EnvPrefix + strings.ToUpper(strings.ReplaceAll(key, "-", "_"))
+}
+
+// MaskToken hides all but the last four characters of a token
+func MaskToken(token string) string {
+	if len(token) <= 4 {
+		return strings.Repeat("*", len(token))
+	}
+	return strings.Repeat("*", len(token)-4) + token[len(token)-4:]
+}
@@ -0,0 +1,198 @@
+/*
+Copyright © 2021 willbenica
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package internal
+
+import (
+	"testing"
+)
+
+func TestResolveConfigPrecedence(t *testing.T) {
+	file := ConfigFile{
+		Profile: Profile{Account: "topAccount", Token: "topToken"},
+		Profiles: map[string]Profile{
+			"clienta": {Account: "profileAccount", Token: "profileToken", BaseURL: "api.leadfeeder.me"},
+			"clientb": {Account: "otherAccount"},
+		},
+	}
+
+	cases := []struct {
+		name        string
+		flags       Profile
+		profileFlag string
+		env         map[string]string
+		file        ConfigFile
+		wantProfile Setting
+		wantAccount Setting
+		wantToken   Setting
+		wantURL     Setting
+	}{
+		{
+			name:        "nothing set uses the built-in default",
+			wantAccount: Setting{},
+			wantURL:     Setting{DefaultBaseURL, SourceDefault},
+		},
+		{
+			name:        "top level of the config file",
+			file:        file,
+			wantAccount: Setting{"topAccount", SourceConfig},
+			wantToken:   Setting{"topToken", SourceConfig},
+			wantURL:     Setting{DefaultBaseURL, SourceDefault},
+		},
+		{
+			name:        "profile beats the config file",
+			profileFlag: "clientA",
+			file:        file,
+			wantProfile: Setting{"clientA", SourceFlag},
+			wantAccount: Setting{"profileAccount", SourceProfile},
+			wantToken:   Setting{"profileToken", SourceProfile},
+			wantURL:     Setting{"api.leadfeeder.me", SourceProfile},
+		},
+		{
+			name:        "profile falls back to the config file for missing values",
+			profileFlag: "clientB",
+			file:        file,
+			wantProfile: Setting{"clientB", SourceFlag},
+			wantAccount: Setting{"otherAccount", SourceProfile},
+			wantToken:   Setting{"topToken", SourceConfig},
+			wantURL:     Setting{DefaultBaseURL, SourceDefault},
+		},
+		{
+			name:        "env beats profile",
+			profileFlag: "clientA",
+			env:         map[string]string{"LF_CLI_ACCOUNT": "envAccount", "LF_CLI_LF_URL": "envURL"},
+			file:        file,
+			wantProfile: Setting{"clientA", SourceFlag},
+			wantAccount: Setting{"envAccount", SourceEnv},
+			wantToken:   Setting{"profileToken", SourceProfile},
+			wantURL:     Setting{"envURL", SourceEnv},
+		},
+		{
+			name:        "flag beats env",
+			flags:       Profile{Account: "flagAccount", Token: "flagToken"},
+			profileFlag: "clientA",
+			env:         map[string]string{"LF_CLI_ACCOUNT": "envAccount", "LF_CLI_TOKEN": "envToken"},
+			file:        file,
+			wantProfile: Setting{"clientA", SourceFlag},
+			wantAccount: Setting{"flagAccount", SourceFlag},
+			wantToken:   Setting{"flagToken", SourceFlag},
+			wantURL:     Setting{"api.leadfeeder.me", SourceProfile},
+		},
+		{
+			name:        "profile selected from env",
+			env:         map[string]string{"LF_CLI_PROFILE": "clientb"},
+			file:        file,
+			wantProfile: Setting{"clientb", SourceEnv},
+			wantAccount: Setting{"otherAccount", SourceProfile},
+			wantToken:   Setting{"topToken", SourceConfig},
+			wantURL:     Setting{DefaultBaseURL, SourceDefault},
+		},
+		{
+			name:        "profile flag beats profile env",
+			profileFlag: "clientb",
+			env:         map[string]string{"LF_CLI_PROFILE": "clienta"},
+			file:        file,
+			wantProfile: Setting{"clientb", SourceFlag},
+			wantAccount: Setting{"otherAccount", SourceProfile},
+			wantToken:   Setting{"topToken", SourceConfig},
+			wantURL:     Setting{DefaultBaseURL, SourceDefault},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := ResolveConfig(ConfigSources{
+				Flags:       c.flags,
+				ProfileFlag: c.profileFlag,
+				Getenv:      func(key string) string { return c.env[key] },
+				File:        c.file,
+			})
+			if err != nil {
+				t.Fatalf("got an unexpected error: %q", err)
+			}
+			if got.Profile != c.wantProfile {
+				t.Errorf("profile: got %v, wanted %v", got.Profile, c.wantProfile)
+			}
+			if got.Account != c.wantAccount {
+				t.Errorf("account: got %v, wanted %v", got.Account, c.wantAccount)
+			}
+			if got.Token != c.wantToken {
+				t.Errorf("token: got %v, wanted %v", got.Token, c.wantToken)
+			}
+			if got.BaseURL != c.wantURL {
+				t.Errorf("lf-url: got %v, wanted %v", got.BaseURL, c.wantURL)
+			}
+		})
+	}
+}
+
+func TestResolveConfigUnknownProfile(t *testing.T) {
+	_, err := ResolveConfig(ConfigSources{ProfileFlag: "missing"})
+	if err == nil {
+		t.Errorf("expected an error for an unknown profile")
+	}
+
+	// Commands creating the profile fall back to the top level
+	file := ConfigFile{Profile: Profile{Account: "top"}}
+	c, err := ResolveConfig(ConfigSources{ProfileFlag: "missing", File: file, NewProfile: true})
+	if err != nil {
+		t.Fatalf("expected a new profile to be accepted, got %q", err)
+	}
+	if c.Profile.Value != "missing" || c.Account.Value != "top" {
+		t.Errorf("expected profile missing with account top, got %+v", c)
+	}
+}
+
+func TestMaskToken(t *testing.T) {
+	cases := []struct {
+		token    string
+		expected string
+	}{
+		{"", ""},
+		{"abc", "***"},
+		{"xxxxYYYYxxxxWWWWxxxxQQQ867512", "*************************7512"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.token, func(t *testing.T) {
+			got := MaskToken(c.token)
+			if got != c.expected {
+				t.Errorf("got %q, wanted %q", got, c.expected)
+			}
+		})
+	}
+}
+
+func TestEnvName(t *testing.T) {
+	cases := []struct {
+		key      string
+		expected string
+	}{
+		{"token", "LF_CLI_TOKEN"},
+		{"lf-url", "LF_CLI_LF_URL"},
+		{"start-date", "LF_CLI_START_DATE"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.key, func(t *testing.T) {
+			got := EnvName(c.key)
+			if got != c.expected {
+				t.Errorf("got %q, wanted %q", got, c.expected)
+			}
+		})
+	}
+}
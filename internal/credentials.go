@@ -0,0 +1,221 @@
+/*
+Copyright © 2021 willbenica
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package internal
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"go.uber.org/zap"
+	"golang.org/x/crypto/scrypt"
+)
+
+// Sources a token can be loaded from in addition to the ones in config.go
+const (
+	SourceTokenCommand   = "token_command"
+	SourceCredentialFile = "credential_file"
+)
+
+// DefaultCredentialKey is used in the credential file when no profile is active
+const DefaultCredentialKey = "default"
+
+// credentialFileVersion is bumped whenever the on-disk format changes
+const credentialFileVersion = 1
+
+// ErrWrongPassphrase is returned when a credential file cannot be decrypted
+var ErrWrongPassphrase = errors.New("unable to decrypt credential file, wrong passphrase?")
+
+// PassphraseFunc is called to obtain the passphrase for the credential file
+type PassphraseFunc func() ([]byte, error)
+
+// credentialFile is the on-disk format of the encrypted credential file
+type credentialFile struct {
+	Version int    `json:"version"`
+	Salt    []byte `json:"salt"`
+	Nonce   []byte `json:"nonce"`
+	Data    []byte `json:"data"`
+}
+
+// LoadToken replaces a token_command with the token it prints, or reads the
+// token from the credential file if no other source provided one.
+func (c *Config) LoadToken(passphrase PassphraseFunc) error {
+	Init()
+	switch c.Token.Source {
+	case SourceTokenCommand:
+		t, err := RunTokenCommand(c.Token.Value)
+		if err != nil {
+			return err
+		}
+		c.Token.Value = t
+	case "":
+		path := c.CredentialFile.Value
+		if _, err := os.Stat(path); err != nil {
+			logger.Debug("No token configured and no credential file found", zap.String("file", path))
+			return nil
+		}
+		pass, err := passphrase()
+		if err != nil {
+			return err
+		}
+		creds, err := ReadCredentialFile(path, pass)
+		if err != nil {
+			return err
+		}
+		t, ok := creds[c.CredentialKey()]
+		if !ok {
+			return fmt.Errorf("no token stored for %q in %s, run 'lf-cli login'", c.CredentialKey(), path)
+		}
+		c.Token = Setting{t, SourceCredentialFile}
+	}
+	return nil
+}
+
+// CredentialKey is the name the token of the active profile is stored under
+func (c Config) CredentialKey() string {
+	if c.Profile.Value == "" {
+		return DefaultCredentialKey
+	}
+	return strings.ToLower(c.Profile.Value)
+}
+
+// RunTokenCommand runs command with the shell and returns its trimmed output
+func RunTokenCommand(command string) (string, error) {
+	Init()
+	// Only the command is logged, never its output
+	logger.Debug("Running token_command", zap.String("command", command))
+	var stderr bytes.Buffer
+	c := exec.Command("sh", "-c", command)
+	c.Stderr = &stderr
+	out, err := c.Output()
+	if err != nil {
+		return "", fmt.Errorf("token_command %q failed: %w: %s", command, err, strings.TrimSpace(stderr.String()))
+	}
+	t := strings.TrimSpace(string(out))
+	if t == "" {
+		return "", fmt.Errorf("token_command %q did not print a token", command)
+	}
+	return t, nil
+}
+
+// StoreWithCommand pipes token to the standard input of command, e.g. `pass insert -m lf-cli`
+func StoreWithCommand(command string, token string) error {
+	Init()
+	logger.Debug("Storing token with command", zap.String("command", command))
+	c := exec.Command("sh", "-c", command)
+	c.Stdin = strings.NewReader(token + "\n")
+	c.Stdout = os.Stderr
+	c.Stderr = os.Stderr
+	if err := c.Run(); err != nil {
+		return fmt.Errorf("storing the token with %q failed: %w", command, err)
+	}
+	return nil
+}
+
+// ReadCredentialFile decrypts the credential file at path and returns the
+// tokens it holds, keyed by profile name
+func ReadCredentialFile(path string, passphrase []byte) (map[string]string, error) {
+	Init()
+	logger.Debug("Reading credential file", zap.String("file", path))
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cf credentialFile
+	if err := json.Unmarshal(raw, &cf); err != nil {
+		return nil, fmt.Errorf("credential file %s is corrupt: %w", path, err)
+	}
+	if cf.Version != credentialFileVersion {
+		return nil, fmt.Errorf("credential file %s has unsupported version %d", path, cf.Version)
+	}
+	gcm, err := newCredentialCipher(passphrase, cf.Salt)
+	if err != nil {
+		return nil, err
+	}
+	plain, err := gcm.Open(nil, cf.Nonce, cf.Data, nil)
+	if err != nil {
+		return nil, ErrWrongPassphrase
+	}
+	creds := map[string]string{}
+	if err := json.Unmarshal(plain, &creds); err != nil {
+		return nil, fmt.Errorf("credential file %s is corrupt: %w", path, err)
+	}
+	return creds, nil
+}
+
+// WriteCredentialFile encrypts creds with passphrase and writes them to path
+// with 0600 permissions
+func WriteCredentialFile(path string, passphrase []byte, creds map[string]string) error {
+	Init()
+	logger.Debug("Writing credential file", zap.String("file", path))
+	plain, err := json.Marshal(creds)
+	if err != nil {
+		return err
+	}
+	cf := credentialFile{
+		Version: credentialFileVersion,
+		Salt:    make([]byte, 16),
+	}
+	if _, err := rand.Read(cf.Salt); err != nil {
+		return err
+	}
+	gcm, err := newCredentialCipher(passphrase, cf.Salt)
+	if err != nil {
+		return err
+	}
+	cf.Nonce = make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(cf.Nonce); err != nil {
+		return err
+	}
+	cf.Data = gcm.Seal(nil, cf.Nonce, plain, nil)
+
+	raw, err := json.Marshal(cf)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(path, raw, 0600); err != nil {
+		return err
+	}
+	// WriteFile does not change the permissions of an existing file
+	return os.Chmod(path, 0600)
+}
+
+// newCredentialCipher derives an AES-256-GCM cipher from passphrase and salt
+func newCredentialCipher(passphrase []byte, salt []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key(passphrase, salt, 1<<15, 8, 1, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
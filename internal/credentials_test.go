@@ -0,0 +1,142 @@
+/*
+Copyright © 2021 willbenica
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package internal
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jarcoal/httpmock"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestCredentialFileRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "credentials")
+	creds := map[string]string{"default": "tokenOne", "clienta": "tokenTwo"}
+
+	if err := WriteCredentialFile(path, []byte("secret"), creds); err != nil {
+		t.Fatalf("writing the credential file failed: %q", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("got permissions %v, wanted 0600", info.Mode().Perm())
+	}
+	raw, _ := os.ReadFile(path)
+	if strings.Contains(string(raw), "tokenOne") {
+		t.Errorf("the credential file contains the token in plain text")
+	}
+
+	got, err := ReadCredentialFile(path, []byte("secret"))
+	if err != nil {
+		t.Fatalf("reading the credential file failed: %q", err)
+	}
+	for k, v := range creds {
+		if got[k] != v {
+			t.Errorf("%s: got %q, wanted %q", k, got[k], v)
+		}
+	}
+
+	if _, err := ReadCredentialFile(path, []byte("wrong")); err != ErrWrongPassphrase {
+		t.Errorf("got %v, wanted %v", err, ErrWrongPassphrase)
+	}
+}
+
+func TestLoadToken(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "credentials")
+	if err := WriteCredentialFile(path, []byte("secret"), map[string]string{"default": "fileToken", "clienta": "profileFileToken"}); err != nil {
+		t.Fatal(err)
+	}
+	passphrase := func() ([]byte, error) { return []byte("secret"), nil }
+
+	cases := []struct {
+		name     string
+		config   Config
+		expected Setting
+	}{
+		{name: "plain token is kept", config: Config{Token: Setting{"plain", SourceEnv}}, expected: Setting{"plain", SourceEnv}},
+		{name: "token_command is run", config: Config{Token: Setting{"echo ' cmdToken '", SourceTokenCommand}}, expected: Setting{"cmdToken", SourceTokenCommand}},
+		{name: "credential file for the default profile", config: Config{CredentialFile: Setting{path, SourceDefault}}, expected: Setting{"fileToken", SourceCredentialFile}},
+		{name: "credential file for a named profile", config: Config{Profile: Setting{"clientA", SourceFlag}, CredentialFile: Setting{path, SourceDefault}}, expected: Setting{"profileFileToken", SourceCredentialFile}},
+		{name: "missing credential file", config: Config{CredentialFile: Setting{path + ".missing", SourceDefault}}, expected: Setting{}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := c.config.LoadToken(passphrase)
+			if err != nil {
+				t.Fatalf("got an unexpected error: %q", err)
+			}
+			if c.config.Token != c.expected {
+				t.Errorf("got %v, wanted %v", c.config.Token, c.expected)
+			}
+		})
+	}
+}
+
+func TestTokenCommandFails(t *testing.T) {
+	if _, err := RunTokenCommand("exit 3"); err == nil {
+		t.Errorf("expected an error for a failing token_command")
+	}
+	if _, err := RunTokenCommand("true"); err == nil {
+		t.Errorf("expected an error for a token_command without output")
+	}
+}
+
+func TestTokenIsNeverLogged(t *testing.T) {
+	Init()
+	core, logs := observer.New(zapcore.DebugLevel)
+	original := logger
+	logger = zap.New(core)
+	defer func() { logger = original }()
+
+	secret := "xxxxYYYYxxxxWWWWxxxxQQQ867512"
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	httpmock.RegisterResponder("GET", URL+"/"+ACCOUNT_ID+"/"+ENDPOINT, httpmock.NewStringResponder(200, MOCK_RESPONSE))
+
+	if _, err := GetEndPointData("leads", URL, secret, ACCOUNT_ID, "2021-01-01", "2021-01-02", PAGE_SIZE, PAGE_NUMBER); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := RunTokenCommand("echo " + secret); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "credentials")
+	if err := WriteCredentialFile(path, []byte("secret"), map[string]string{"default": secret}); err != nil {
+		t.Fatal(err)
+	}
+	c := Config{CredentialFile: Setting{path, SourceDefault}}
+	if err := c.LoadToken(func() ([]byte, error) { return []byte("secret"), nil }); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, entry := range logs.All() {
+		line := fmt.Sprint(entry.Message, entry.ContextMap())
+		// The token_command itself may be logged, but only as part of the command
+		if strings.Contains(line, secret) && !strings.Contains(line, "echo "+secret) {
+			t.Errorf("token found in log entry: %s", line)
+		}
+	}
+}
@@ -0,0 +1,283 @@
+/*
+Copyright © 2021 willbenica
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package internal
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Status of a doctor check
+const (
+	CheckPass = "pass"
+	CheckWarn = "warn"
+	CheckFail = "fail"
+	CheckSkip = "skip"
+)
+
+// maxClockSkew is the difference to the server clock at which the clock check fails
+const maxClockSkew = 5 * time.Minute
+
+// lookupHost resolves a host name, replaced in tests
+var lookupHost = net.DefaultResolver.LookupHost
+
+// CheckResult is the outcome of a single doctor check
+type CheckResult struct {
+	Name       string `json:"name"`
+	Status     string `json:"status"`
+	Detail     string `json:"detail"`
+	DurationMs int64  `json:"duration_ms"`
+}
+
+// DoctorReport holds the results of all doctor checks
+type DoctorReport struct {
+	Time      time.Time     `json:"time"`
+	BaseURL   string        `json:"lf_url"`
+	AccountID string        `json:"account_id"`
+	OK        bool          `json:"ok"`
+	Checks    []CheckResult `json:"checks"`
+}
+
+// add appends a check result and updates the overall state of the report
+func (r *DoctorReport) add(name string, start time.Time, status string, detail string) {
+	r.Checks = append(r.Checks, CheckResult{name, status, detail, time.Since(start).Milliseconds()})
+	if status == CheckFail {
+		r.OK = false
+	}
+}
+
+// failed reports whether the check called name failed or was skipped
+func (r DoctorReport) failed(name string) bool {
+	for _, c := range r.Checks {
+		if c.Name == name {
+			return c.Status == CheckFail || c.Status == CheckSkip
+		}
+	}
+	return true
+}
+
+// RunDoctor checks credentials, configuration, proxy, DNS, TLS, authentication,
+// account access, clock skew and rate-limit headroom. tokenErr is the error of
+// loading the token, e.g. from the credential file. Checks depending on a failed
+// check are skipped. HTTPClient is used so the results match GetEndPointData.
+func RunDoctor(baseURL string, token string, accountID string, tokenErr error) DoctorReport {
+	Init()
+	host := baseURLBuilder(baseURL)
+	r := DoctorReport{Time: time.Now(), BaseURL: host, AccountID: accountID, OK: true}
+
+	start := time.Now()
+	switch {
+	case tokenErr != nil:
+		r.add("credentials", start, CheckFail, tokenErr.Error())
+	case token == "":
+		r.add("credentials", start, CheckPass, "no token to load")
+	default:
+		r.add("credentials", start, CheckPass, "the token could be loaded")
+	}
+
+	start = time.Now()
+	switch {
+	case token == "" && tokenErr != nil:
+		r.add("config", start, CheckFail, "no token, it could not be loaded")
+	case token == "":
+		r.add("config", start, CheckFail, "no token configured")
+	case accountID == "":
+		r.add("config", start, CheckFail, "no account configured")
+	default:
+		r.add("config", start, CheckPass, fmt.Sprintf("account %s, token %s", accountID, MaskToken(token)))
+	}
+
+	start = time.Now()
+	proxy, err := proxyFor(host)
+	switch {
+	case err != nil:
+		r.add("proxy", start, CheckFail, err.Error())
+	case proxy != "":
+		r.add("proxy", start, CheckPass, "using proxy "+proxy)
+	default:
+		r.add("proxy", start, CheckPass, "no proxy configured")
+	}
+
+	start = time.Now()
+	hostname := host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		hostname = h
+	}
+	var addrs []string
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	addrs, err = lookupHost(ctx, hostname)
+	cancel()
+	switch {
+	case err != nil && proxy != "":
+		r.add("dns", start, CheckWarn, fmt.Sprintf("%s, the proxy resolves the host instead", err))
+	case err != nil:
+		r.add("dns", start, CheckFail, err.Error())
+	default:
+		r.add("dns", start, CheckPass, fmt.Sprintf("%s resolves to %s", hostname, strings.Join(addrs, ", ")))
+	}
+
+	start = time.Now()
+	if r.failed("dns") && proxy == "" {
+		r.add("tls", start, CheckSkip, "host could not be resolved")
+	} else if response, err := doctorRequest("https://"+host+"/", ""); err != nil {
+		r.add("tls", start, CheckFail, err.Error())
+	} else if response.TLS == nil {
+		r.add("tls", start, CheckFail, "the connection is not encrypted")
+	} else {
+		r.add("tls", start, CheckPass, describeTLS(response.TLS))
+	}
+
+	start = time.Now()
+	var accountResponse *http.Response
+	if r.failed("tls") || r.failed("config") {
+		r.add("auth", start, CheckSkip, "requires a TLS connection and a token")
+	} else if response, err := doctorRequest("https://"+host+"/accounts", token); err != nil {
+		r.add("auth", start, CheckFail, err.Error())
+	} else if response.StatusCode == http.StatusUnauthorized {
+		r.add("auth", start, CheckFail, "the token was rejected: "+response.Status)
+	} else if response.StatusCode != http.StatusOK {
+		r.add("auth", start, CheckFail, "unexpected response: "+response.Status)
+	} else {
+		r.add("auth", start, CheckPass, "the token is valid")
+	}
+
+	start = time.Now()
+	if r.failed("auth") {
+		r.add("account", start, CheckSkip, "requires a valid token")
+	} else if response, err := doctorRequest("https://"+host+"/accounts/"+accountID, token); err != nil {
+		r.add("account", start, CheckFail, err.Error())
+	} else if response.StatusCode != http.StatusOK {
+		r.add("account", start, CheckFail, fmt.Sprintf("no access to account %s: %s", accountID, response.Status))
+	} else {
+		accountResponse = response
+		r.add("account", start, CheckPass, "access to account "+accountID)
+	}
+
+	start = time.Now()
+	if accountResponse == nil {
+		r.add("clock", start, CheckSkip, "requires a response from the API")
+		r.add("rate-limit", start, CheckSkip, "requires a response from the API")
+		return r
+	}
+	status, detail := clockStatus(accountResponse)
+	r.add("clock", start, status, detail)
+	status, detail = rateLimitStatus(accountResponse)
+	r.add("rate-limit", start, status, detail)
+	return r
+}
+
+// proxyFor returns the proxy HTTPClient uses to connect to host, if any
+func proxyFor(host string) (string, error) {
+	transport := HTTPClient.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	t, ok := transport.(*http.Transport)
+	if !ok || t.Proxy == nil {
+		return "", nil
+	}
+	request, err := http.NewRequest("GET", "https://"+host+"/", nil)
+	if err != nil {
+		return "", err
+	}
+	u, err := t.Proxy(request)
+	if err != nil || u == nil {
+		return "", err
+	}
+	return u.Host, nil
+}
+
+// doctorRequest sends a GET request with HTTPClient, the body is discarded
+func doctorRequest(url string, token string) (*http.Response, error) {
+	logger.Debug("Doctor request", zap.String("URL", url))
+	request, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if token != "" {
+		request.Header.Set("Authorization", "Bearer "+token)
+	}
+	request.Header.Add("User-Agent", "lf-cli")
+	request.Header.Add("Accept", "*/*")
+	client := *HTTPClient
+	client.Timeout = 30 * time.Second
+	response, err := client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	ioutil.ReadAll(response.Body)
+	return response, nil
+}
+
+// describeTLS summarises the negotiated TLS version and the server certificate
+func describeTLS(state *tls.ConnectionState) string {
+	versions := map[uint16]string{
+		tls.VersionTLS10: "TLS 1.0",
+		tls.VersionTLS11: "TLS 1.1",
+		tls.VersionTLS12: "TLS 1.2",
+		tls.VersionTLS13: "TLS 1.3",
+	}
+	detail := versions[state.Version]
+	if len(state.PeerCertificates) > 0 {
+		cert := state.PeerCertificates[0]
+		detail += fmt.Sprintf(", certificate for %s valid until %s", cert.Subject.CommonName, cert.NotAfter.Format("2006-01-02"))
+	}
+	return detail
+}
+
+// clockStatus compares the Date header of the server with the local clock
+func clockStatus(response *http.Response) (string, string) {
+	date, err := http.ParseTime(response.Header.Get("Date"))
+	if err != nil {
+		return CheckWarn, "the server did not send a Date header"
+	}
+	skew := time.Since(date).Round(time.Second)
+	detail := fmt.Sprintf("local clock differs by %s from the server", skew)
+	if skew > maxClockSkew || skew < -maxClockSkew {
+		return CheckFail, detail
+	}
+	return CheckPass, detail
+}
+
+// rateLimitStatus reports the remaining requests from the rate-limit headers
+func rateLimitStatus(response *http.Response) (string, string) {
+	limit := response.Header.Get("X-RateLimit-Limit")
+	remaining := response.Header.Get("X-RateLimit-Remaining")
+	if remaining == "" {
+		return CheckPass, "the server did not send rate-limit headers"
+	}
+	n, err := strconv.Atoi(remaining)
+	if err != nil {
+		return CheckWarn, "unable to read X-RateLimit-Remaining: " + remaining
+	}
+	detail := fmt.Sprintf("%d of %s requests remaining", n, limit)
+	if n == 0 {
+		return CheckFail, detail
+	}
+	return CheckPass, detail
+}
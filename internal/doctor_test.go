@@ -0,0 +1,132 @@
+/*
+Copyright © 2021 willbenica
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package internal
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRunDoctor(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer "+TOKEN {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Path == "/accounts/"+ACCOUNT_ID {
+			w.Header().Set("X-RateLimit-Limit", "100")
+			w.Header().Set("X-RateLimit-Remaining", "42")
+		}
+		if r.URL.Path != "/accounts" && r.URL.Path != "/accounts/"+ACCOUNT_ID {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Write([]byte(`{"data":[]}`))
+	}))
+	defer server.Close()
+
+	original := HTTPClient
+	HTTPClient = server.Client()
+	defer func() { HTTPClient = original }()
+
+	cases := []struct {
+		name      string
+		token     string
+		accountID string
+		tokenErr  error
+		expected  map[string]string
+	}{
+		{
+			name:      "everything passes",
+			token:     TOKEN,
+			accountID: ACCOUNT_ID,
+			expected:  map[string]string{"credentials": CheckPass, "config": CheckPass, "dns": CheckPass, "tls": CheckPass, "auth": CheckPass, "account": CheckPass, "clock": CheckPass, "rate-limit": CheckPass},
+		},
+		{
+			name:      "wrong token",
+			token:     "wrong",
+			accountID: ACCOUNT_ID,
+			expected:  map[string]string{"tls": CheckPass, "auth": CheckFail, "account": CheckSkip, "clock": CheckSkip},
+		},
+		{
+			name:      "no access to the account",
+			token:     TOKEN,
+			accountID: "654321",
+			expected:  map[string]string{"auth": CheckPass, "account": CheckFail, "rate-limit": CheckSkip},
+		},
+		{
+			name:     "missing account",
+			token:    TOKEN,
+			expected: map[string]string{"config": CheckFail, "auth": CheckSkip},
+		},
+		{
+			name:      "unreadable credential file",
+			accountID: ACCOUNT_ID,
+			tokenErr:  errors.New("wrong passphrase"),
+			expected:  map[string]string{"credentials": CheckFail, "config": CheckFail, "dns": CheckPass, "tls": CheckPass, "auth": CheckSkip},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			report := RunDoctor(server.URL, c.token, c.accountID, c.tokenErr)
+			got := map[string]string{}
+			for _, check := range report.Checks {
+				got[check.Name] = check.Status
+			}
+			for name, status := range c.expected {
+				if got[name] != status {
+					t.Errorf("%s: got %q, wanted %q (%v)", name, got[name], status, report.Checks)
+				}
+			}
+			allPass := c.expected["account"] == CheckPass
+			if report.OK != allPass {
+				t.Errorf("got OK %v, wanted %v", report.OK, allPass)
+			}
+		})
+	}
+}
+
+func TestClockStatus(t *testing.T) {
+	cases := []struct {
+		name     string
+		date     time.Time
+		expected string
+	}{
+		{name: "clocks agree", date: time.Now(), expected: CheckPass},
+		{name: "local clock is late", date: time.Now().Add(10 * time.Minute), expected: CheckFail},
+		{name: "local clock is early", date: time.Now().Add(-10 * time.Minute), expected: CheckFail},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			response := &http.Response{Header: http.Header{"Date": []string{c.date.UTC().Format(http.TimeFormat)}}}
+			got, _ := clockStatus(response)
+			if got != c.expected {
+				t.Errorf("got %q, wanted %q", got, c.expected)
+			}
+		})
+	}
+}
@@ -115,20 +115,60 @@ func (l Leads) GetAllData() string {
 
 func LoopThroughLeadsData(d []LeadData, l []Location, start int, end int, f Flags) ([]LeadData, []Location, error) {
 	logger.Debug("Looping through LeadsData")
-	for i := start; i <= end; i++ {
-		logger.Info("Starting loops", zap.Int("current", i), zap.Int("end", end))
-		ep_data, err := GetEndPointData("leads", f.BaseURL, f.Token, f.AccountID, TodayOrDate(f.StartDate), TodayOrDate(f.EndDate), f.PageSize, i)
-		if err != nil {
-			return nil, nil, err
-		}
+	err := ForEachPage("leads", start, end, f, func(ep_data EndPoint) error {
 		leads, locations, _, _ := ep_data.GetData()
-
 		d = append(d, leads...)
 		l = append(l, locations...)
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
 	}
 	return d, l, nil
 }
 
+// ForEachPage requests the pages start to end of the endpoint and hands each
+// response to fn as soon as it arrives, so callers can stream the data
+func ForEachPage(ep string, start int, end int, f Flags, fn func(EndPoint) error) error {
+	for i := start; i <= end; i++ {
+		logger.Info("Starting loops", zap.Int("current", i), zap.Int("end", end))
+		ep_data, err := GetEndPointData(ep, f.BaseURL, f.Token, f.AccountID, TodayOrDate(f.StartDate), TodayOrDate(f.EndDate), f.PageSize, i)
+		if err != nil {
+			return err
+		}
+		if err := fn(ep_data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FetchDataset requests every page of the endpoint from f.StartDate to
+// f.EndDate and collects the records
+func FetchDataset(ep string, f Flags) (Dataset, error) {
+	var ds Dataset
+	collect := func(ep_data EndPoint) error {
+		leads, locations, visits, _ := ep_data.GetData()
+		ds.Leads = append(ds.Leads, leads...)
+		ds.Locations = append(ds.Locations, locations...)
+		ds.Visits = append(ds.Visits, visits...)
+		return nil
+	}
+	first, err := GetEndPointData(ep, f.BaseURL, f.Token, f.AccountID, TodayOrDate(f.StartDate), TodayOrDate(f.EndDate), f.PageSize, 1)
+	if err != nil {
+		return Dataset{}, err
+	}
+	collect(first)
+	lastPage, err := first.GetLastPageNumber()
+	if err != nil {
+		return Dataset{}, err
+	}
+	if err := ForEachPage(ep, 2, lastPage, f, collect); err != nil {
+		return Dataset{}, err
+	}
+	return ds, nil
+}
+
 type LeadAttributes struct {
 	FacebookURL       string   `json:"facebook_url"`
 	Status            string   `json:"status"`
@@ -273,15 +313,13 @@ func (v Visits) GetAllData() string {
 
 func LoopThroughVistsData(d Visits, start int, end int, f Flags) (Visits, error) {
 	logger.Debug("Looping through LeadsData")
-	for i := start; i <= end; i++ {
-		logger.Info("Starting loops", zap.Int("current", i), zap.Int("end", end))
-		ep_data, err := GetEndPointData("visits", f.BaseURL, f.Token, f.AccountID, TodayOrDate(f.StartDate), TodayOrDate(f.EndDate), f.PageSize, i)
-		if err != nil {
-			return Visits{}, err
-		}
+	err := ForEachPage("visits", start, end, f, func(ep_data EndPoint) error {
 		_, _, data, _ := ep_data.GetData()
-
 		d.Data = append(d.Data, data...)
+		return nil
+	})
+	if err != nil {
+		return Visits{}, err
 	}
 	return d, nil
 }
@@ -302,6 +340,8 @@ type VisitAttributes struct {
 	LfClientID   string       `json:"lf_client_id"`
 	GaClientIDs  []string     `json:"ga_client_ids"`
 	LeadID       string       `json:"lead_id"`
+	// PageGroups is the set of content groups of the route, see ContentGroups
+	PageGroups []string `json:"page_groups,omitempty"`
 }
 
 type VisitRoute struct {
@@ -312,6 +352,8 @@ type VisitRoute struct {
 	PageTitle        string `json:"page_title"`
 	PageURL          string `json:"page_url"`
 	DisplayPageName  string `json:"display_page_name"`
+	// PageGroup is the content group of the page, see ContentGroups
+	PageGroup string `json:"page_group,omitempty"`
 }
 
 // --------------------------------------
@@ -0,0 +1,306 @@
+/*
+Copyright © 2021 willbenica
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package internal
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Output formats
+const (
+	FormatJSON = "json"
+	FormatCSV  = "csv"
+	FormatTSV  = "tsv"
+)
+
+// Ways to flatten a visit's VisitRoute into rows
+const (
+	// VisitRowsVisit writes one row per visit with the route summarised
+	VisitRowsVisit = "visit"
+	// VisitRowsStep writes one row per step of the route
+	VisitRowsStep = "step"
+)
+
+// tagSeparator joins the values of list attributes like Tags into a single cell
+const tagSeparator = ";"
+
+// IsValidFormat returns `true` if format is a supported output format
+func IsValidFormat(format string) bool {
+	switch format {
+	case FormatJSON, FormatCSV, FormatTSV, FormatSQLite, FormatParquet, FormatXLSX:
+		return true
+	}
+	return false
+}
+
+// Dataset holds records of every resource type, e.g. read back from files
+type Dataset struct {
+	Leads     []LeadData
+	Locations []Location
+	Visits    []VisitData
+}
+
+// ReadDataFiles reads leads, locations and visits from files written by lf-cli
+// (one record per line) or from raw API responses (with "data" and "included").
+// gzip and zstd compressed files are decompressed.
+func ReadDataFiles(paths ...string) (Dataset, error) {
+	Init()
+	var ds Dataset
+	for _, path := range paths {
+		logger.Debug("Reading data file", zap.String("file", path))
+		f, err := OpenFile(path)
+		if err != nil {
+			return Dataset{}, err
+		}
+		err = ds.read(f)
+		f.Close()
+		if err != nil {
+			return Dataset{}, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	return ds, nil
+}
+
+// read decodes a stream of JSON values into the dataset
+func (ds *Dataset) read(r io.Reader) error {
+	d := json.NewDecoder(r)
+	for {
+		var raw json.RawMessage
+		if err := d.Decode(&raw); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		var doc struct {
+			Type     string            `json:"type"`
+			Data     []json.RawMessage `json:"data"`
+			Included []json.RawMessage `json:"included"`
+		}
+		if err := json.Unmarshal(raw, &doc); err != nil {
+			return err
+		}
+		if doc.Data == nil && doc.Type != "" {
+			if err := ds.addRecord(doc.Type, raw); err != nil {
+				return err
+			}
+			continue
+		}
+		for _, record := range append(doc.Data, doc.Included...) {
+			var r struct {
+				Type string `json:"type"`
+			}
+			if err := json.Unmarshal(record, &r); err != nil {
+				return err
+			}
+			if err := ds.addRecord(r.Type, record); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// addRecord decodes a single record according to its type
+func (ds *Dataset) addRecord(recordType string, raw json.RawMessage) error {
+	switch recordType {
+	case "leads":
+		var l LeadData
+		if err := json.Unmarshal(raw, &l); err != nil {
+			return err
+		}
+		ds.Leads = append(ds.Leads, l)
+	case "locations":
+		var l Location
+		if err := json.Unmarshal(raw, &l); err != nil {
+			return err
+		}
+		ds.Locations = append(ds.Locations, l)
+	case "visits":
+		var v VisitData
+		if err := json.Unmarshal(raw, &v); err != nil {
+			return err
+		}
+		ds.Visits = append(ds.Visits, v)
+	default:
+		return fmt.Errorf("unknown record type %q", recordType)
+	}
+	return nil
+}
+
+// LocationIndex maps location IDs to locations
+func LocationIndex(locations []Location) map[string]Location {
+	index := make(map[string]Location, len(locations))
+	for _, l := range locations {
+		index[l.ID] = l
+	}
+	return index
+}
+
+// Table is a flattened view of records, used by the tabular writers
+type Table struct {
+	Columns []string
+	Rows    [][]string
+}
+
+var leadColumns = []string{
+	"id", "name", "status", "industry", "quality", "visits", "employee_count",
+	"first_visit_date", "last_visit_date", "website_url", "linkedin_url", "facebook_url",
+	"twitter_handle", "business_id", "revenue", "assignee", "emailed_to", "phone",
+	"crm_lead_id", "crm_organization_id", "logo_url", "view_in_leadfeeder", "tags",
+	"location_id", "country", "country_code", "region", "region_code", "city", "state_code",
+}
+
+// LeadsTable flattens leads, each lead's location is looked up through
+// Relationships.Location.Data.ID and added to the same row
+func LeadsTable(leads []LeadData, locations []Location) Table {
+	index := LocationIndex(locations)
+	t := Table{Columns: leadColumns}
+	for _, l := range leads {
+		a := l.Attributes
+		loc := index[l.Relationships.Location.Data.ID].Attributes
+		t.Rows = append(t.Rows, []string{
+			l.ID, a.Name, a.Status, a.Industry, strconv.Itoa(a.Quality), strconv.Itoa(a.Visits), strconv.Itoa(a.EmployeeCount),
+			a.FirstVisitDate, a.LastVisitDate, a.WebsiteURL, a.LinkedinURL, a.FacebookURL,
+			a.TwitterHandle, a.BusinessID, a.Revenue, a.Assignee, a.EmailedTo, a.Phone,
+			a.CrmLeadID, a.CrmOrganizationID, a.LogoURL, a.ViewInLeadfeeder, strings.Join(a.Tags, tagSeparator),
+			l.Relationships.Location.Data.ID, loc.Country, loc.CountryCode, loc.Region, loc.RegionCode, loc.City, loc.StateCode,
+		})
+	}
+	return t
+}
+
+// LocationsTable flattens locations
+func LocationsTable(locations []Location) Table {
+	t := Table{Columns: []string{"id", "country", "country_code", "region", "region_code", "city", "state_code"}}
+	for _, l := range locations {
+		a := l.Attributes
+		t.Rows = append(t.Rows, []string{l.ID, a.Country, a.CountryCode, a.Region, a.RegionCode, a.City, a.StateCode})
+	}
+	return t
+}
+
+var visitColumns = []string{
+	"id", "lead_id", "started_at", "date", "hour", "source", "medium", "campaign",
+	"keyword", "query_term", "referring_url", "page_depth", "visit_length",
+	"lf_client_id", "ga_client_ids", "page_groups",
+}
+
+// visitRow holds the columns shared by both ways of flattening visits
+func visitRow(v VisitData) []string {
+	a := v.Attributes
+	return []string{
+		v.ID, a.LeadID, a.StartedAt.Format(time.RFC3339), a.Date, strconv.Itoa(a.Hour), a.Source, a.Medium, a.Campaign,
+		a.Keyword, a.QueryTerm, a.ReferringURL, strconv.Itoa(a.PageDepth), strconv.Itoa(a.VisitLength),
+		a.LfClientID, strings.Join(a.GaClientIDs, tagSeparator), strings.Join(a.PageGroups, tagSeparator),
+	}
+}
+
+// VisitsTable flattens visits. With VisitRowsVisit the route is summarised
+// as entry page, exit page and the path through the site, with VisitRowsStep
+// every step of the route becomes a row of its own. Visits without a route
+// get one row with empty step columns.
+func VisitsTable(visits []VisitData, rows string) Table {
+	var t Table
+	if rows == VisitRowsStep {
+		t.Columns = append(append([]string{}, visitColumns...),
+			"step", "hostname", "page_path", "previous_page_path", "time_on_page", "page_title", "page_url", "display_page_name", "page_group")
+		for _, v := range visits {
+			if len(v.Attributes.VisitRoute) == 0 {
+				// A visit without a route keeps its row, with empty step columns
+				t.Rows = append(t.Rows, append(visitRow(v), make([]string, len(t.Columns)-len(visitColumns))...))
+				continue
+			}
+			for i, r := range v.Attributes.VisitRoute {
+				t.Rows = append(t.Rows, append(visitRow(v),
+					strconv.Itoa(i+1), r.Hostname, r.PagePath, r.PreviousPagePath, strconv.Itoa(r.TimeOnPage), r.PageTitle, r.PageURL, r.DisplayPageName, r.PageGroup))
+			}
+		}
+		return t
+	}
+
+	t.Columns = append(append([]string{}, visitColumns...), "route_steps", "entry_page", "exit_page", "route")
+	for _, v := range visits {
+		route := v.Attributes.VisitRoute
+		paths := make([]string, len(route))
+		for i, r := range route {
+			paths[i] = r.PagePath
+		}
+		entry, exit := "", ""
+		if len(paths) > 0 {
+			entry, exit = paths[0], paths[len(paths)-1]
+		}
+		t.Rows = append(t.Rows, append(visitRow(v), strconv.Itoa(len(route)), entry, exit, strings.Join(paths, " > ")))
+	}
+	return t
+}
+
+// VisitRoutesTable flattens the routes of visits to one row per step, keyed
+// by visit_id and step
+func VisitRoutesTable(visits []VisitData) Table {
+	t := Table{Columns: []string{"visit_id", "step", "hostname", "page_path", "previous_page_path", "time_on_page", "page_title", "page_url", "display_page_name", "page_group"}}
+	for _, v := range visits {
+		for i, r := range v.Attributes.VisitRoute {
+			t.Rows = append(t.Rows, []string{v.ID, strconv.Itoa(i + 1), r.Hostname, r.PagePath, r.PreviousPagePath, strconv.Itoa(r.TimeOnPage), r.PageTitle, r.PageURL, r.DisplayPageName, r.PageGroup})
+		}
+	}
+	return t
+}
+
+// WriteDelimited writes the table as CSV or TSV including a header row
+func (t Table) WriteDelimited(w io.Writer, format string) error {
+	return t.writeDelimited(w, format, true)
+}
+
+// WriteDelimitedRows writes the rows of the table as CSV or TSV without a
+// header, e.g. to append a page to a stream
+func (t Table) WriteDelimitedRows(w io.Writer, format string) error {
+	return t.writeDelimited(w, format, false)
+}
+
+func (t Table) writeDelimited(w io.Writer, format string, header bool) error {
+	cw := csv.NewWriter(w)
+	if format == FormatTSV {
+		cw.Comma = '\t'
+	}
+	if header {
+		if err := cw.Write(t.Columns); err != nil {
+			return err
+		}
+	}
+	if err := cw.WriteAll(t.Rows); err != nil {
+		return err
+	}
+	return cw.Error()
+}
+
+// Delimited returns the table as CSV or TSV
+func (t Table) Delimited(format string) (string, error) {
+	var buf bytes.Buffer
+	if err := t.WriteDelimited(&buf, format); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
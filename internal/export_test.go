@@ -0,0 +1,133 @@
+/*
+Copyright © 2021 willbenica
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadDataFiles(t *testing.T) {
+	// Raw API responses
+	ds, err := ReadDataFiles(TEST_FOLDER+L1, TEST_FOLDER+L2, TEST_FOLDER+V1)
+	if err != nil {
+		t.Fatalf("got an unexpected error: %q", err)
+	}
+	if len(ds.Leads) != 4 || len(ds.Locations) != 4 || len(ds.Visits) != 2 {
+		t.Errorf("got %d leads, %d locations, %d visits, wanted 4, 4, 2", len(ds.Leads), len(ds.Locations), len(ds.Visits))
+	}
+
+	// Files written by lf-cli, one record per line
+	path := filepath.Join(t.TempDir(), "leads.json")
+	if err := os.WriteFile(path, []byte(Leads{Data: ds.Leads}.GetAllData()+Locations{Data: ds.Locations}.GetAllData()), 0644); err != nil {
+		t.Fatal(err)
+	}
+	ndjson, err := ReadDataFiles(path)
+	if err != nil {
+		t.Fatalf("got an unexpected error: %q", err)
+	}
+	if len(ndjson.Leads) != 4 || len(ndjson.Locations) != 4 {
+		t.Errorf("got %d leads, %d locations, wanted 4, 4", len(ndjson.Leads), len(ndjson.Locations))
+	}
+}
+
+func TestLeadsTable(t *testing.T) {
+	leads := []LeadData{
+		{ID: "l1", Attributes: LeadAttributes{Name: "myCompany", Quality: 7, Tags: []string{"hot", "ICP"}}, Relationships: Relationships{Location: RelatedLocaction{Data: LocData{ID: "loc1"}}}},
+		{ID: "l2", Attributes: LeadAttributes{Name: "noLocation"}},
+	}
+	locations := []Location{{ID: "loc1", Attributes: LocationAttributes{Country: "Germany", City: "Dresden"}}}
+
+	table := LeadsTable(leads, locations)
+	got, err := table.Delimited(FormatCSV)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(got), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("got %d lines, wanted 3", len(lines))
+	}
+	want := "l1,myCompany,,,7,0,0,,,,,,,,,,,,,,,,hot;ICP,loc1,Germany,,,,Dresden,"
+	if lines[1] != want {
+		t.Errorf("got %q,\nwanted %q", lines[1], want)
+	}
+	if !strings.HasSuffix(lines[2], ",,,,,,,") {
+		t.Errorf("a lead without location should have empty location columns, got %q", lines[2])
+	}
+}
+
+func TestVisitsTable(t *testing.T) {
+	ds, err := ReadDataFiles(TEST_FOLDER + V1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name      string
+		rows      string
+		wantRows  int
+		column    string
+		wantValue string
+	}{
+		{name: "one row per visit", rows: VisitRowsVisit, wantRows: 2, column: "route", wantValue: "/myCompany/ > /myCompany/myCompany/ > /coolSolutions/ > /product/"},
+		{name: "one row per route step", rows: VisitRowsStep, wantRows: 5, column: "page_path", wantValue: "/myCompany/"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			table := VisitsTable(ds.Visits, c.rows)
+			if len(table.Rows) != c.wantRows {
+				t.Errorf("got %d rows, wanted %d", len(table.Rows), c.wantRows)
+			}
+			col := -1
+			for i, name := range table.Columns {
+				if name == c.column {
+					col = i
+				}
+			}
+			if col < 0 {
+				t.Fatalf("column %q is missing", c.column)
+			}
+			if table.Rows[0][col] != c.wantValue {
+				t.Errorf("got %q, wanted %q", table.Rows[0][col], c.wantValue)
+			}
+		})
+	}
+}
+
+func TestVisitsTableStepsWithoutRoute(t *testing.T) {
+	visits := []VisitData{{ID: "v1", Attributes: VisitAttributes{LeadID: "l1"}}}
+	table := VisitsTable(visits, VisitRowsStep)
+	if len(table.Rows) != 1 {
+		t.Fatalf("got %d rows, wanted 1", len(table.Rows))
+	}
+	row := table.Rows[0]
+	if len(row) != len(table.Columns) {
+		t.Fatalf("got %d values for %d columns", len(row), len(table.Columns))
+	}
+	if row[0] != "v1" {
+		t.Errorf("got id %q, wanted v1", row[0])
+	}
+	for i, v := range row[len(visitColumns):] {
+		if v != "" {
+			t.Errorf("expected column %s to be empty, got %q", table.Columns[len(visitColumns)+i], v)
+		}
+	}
+}
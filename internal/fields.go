@@ -0,0 +1,304 @@
+/*
+Copyright © 2021 willbenica
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Projection selects fields of leads or visits by dotted paths, e.g.
+// attributes.name, and names the selected fields in the output
+type Projection struct {
+	Paths []string
+	Names []string
+}
+
+// Field is a selected value and its name in the output
+type Field struct {
+	Name  string
+	Value interface{}
+}
+
+// Record is a projected lead or visit, the fields keep the order of the projection
+type Record []Field
+
+// MarshalJSON encodes the record as an object with the fields in order
+func (r Record) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, f := range r {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		name, err := json.Marshal(f.Name)
+		if err != nil {
+			return nil, err
+		}
+		value, err := marshalNoEscape(f.Value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(name)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// NewProjection checks the paths against the fields of a lead (with its
+// location) or a visit, depending on endpoint. rename maps paths to the
+// names used in the output, paths without a new name keep the path.
+func NewProjection(endpoint string, paths []string, rename map[string]string) (Projection, error) {
+	schema, err := schemaDocument(endpoint)
+	if err != nil {
+		return Projection{}, err
+	}
+
+	p := Projection{}
+	seen := map[string]bool{}
+	for _, path := range paths {
+		path = strings.TrimSpace(path)
+		if !pathExists(schema, path) {
+			return Projection{}, fmt.Errorf("unknown field %q for %s, use dotted paths like %s", path, endpoint, exampleFields(endpoint))
+		}
+		name := path
+		if n, ok := rename[path]; ok {
+			name = n
+		}
+		if seen[name] {
+			return Projection{}, fmt.Errorf("the field name %q is used twice", name)
+		}
+		seen[name] = true
+		p.Paths = append(p.Paths, path)
+		p.Names = append(p.Names, name)
+	}
+	for path := range rename {
+		if !contains(p.Paths, path) {
+			return Projection{}, fmt.Errorf("--rename %s: the field is not selected with --fields", path)
+		}
+	}
+	return p, nil
+}
+
+func exampleFields(endpoint string) string {
+	if endpoint == "leads" {
+		return "id,attributes.name,attributes.quality,location.city"
+	}
+	return "id,attributes.started_at,attributes.source,attributes.visit_route.0.page_path"
+}
+
+// ProjectLeads selects the fields of the projection from each lead. The
+// lead's location is available as location, e.g. location.city.
+func ProjectLeads(leads []LeadData, locations []Location, p Projection) ([]Record, error) {
+	index := LocationIndex(locations)
+	records := make([]Record, 0, len(leads))
+	for _, l := range leads {
+		var location *Location
+		if loc, ok := index[l.Relationships.Location.Data.ID]; ok {
+			location = &loc
+		}
+		doc, err := leadDocument(l, location)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, p.project(doc))
+	}
+	return records, nil
+}
+
+// ProjectVisits selects the fields of the projection from each visit
+func ProjectVisits(visits []VisitData, p Projection) ([]Record, error) {
+	records := make([]Record, 0, len(visits))
+	for _, v := range visits {
+		doc, err := toDocument(v)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, p.project(doc))
+	}
+	return records, nil
+}
+
+func (p Projection) project(doc map[string]interface{}) Record {
+	r := make(Record, len(p.Paths))
+	for i, path := range p.Paths {
+		r[i] = Field{p.Names[i], lookup(doc, path)}
+	}
+	return r
+}
+
+// RecordsNDJSON encodes records one per line
+func RecordsNDJSON(records []Record) string {
+	var buf bytes.Buffer
+	e := json.NewEncoder(&buf)
+	e.SetEscapeHTML(false)
+	for _, r := range records {
+		e.Encode(r)
+	}
+	return buf.String()
+}
+
+// RecordsTable flattens records for the tabular writers. Lists of plain
+// values are joined like Tags, other lists and objects are written as JSON.
+func RecordsTable(records []Record, p Projection) Table {
+	t := Table{Columns: p.Names}
+	for _, r := range records {
+		row := make([]string, len(r))
+		for i, f := range r {
+			row[i] = cellValue(f.Value)
+		}
+		t.Rows = append(t.Rows, row)
+	}
+	return t
+}
+
+func cellValue(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case []interface{}:
+		values := make([]string, len(v))
+		for i, item := range v {
+			switch item.(type) {
+			case map[string]interface{}, []interface{}:
+				b, _ := marshalNoEscape(v)
+				return string(b)
+			}
+			values[i] = cellValue(item)
+		}
+		return strings.Join(values, tagSeparator)
+	}
+	b, _ := marshalNoEscape(v)
+	return string(b)
+}
+
+// schemaDocument is a zero lead or visit with one element in every list and
+// the optional fields set, to check names and paths against
+func schemaDocument(endpoint string) (map[string]interface{}, error) {
+	switch endpoint {
+	case "leads":
+		return leadDocument(LeadData{Attributes: LeadAttributes{Tags: []string{""}}}, &Location{})
+	case "visits":
+		return toDocument(VisitData{Attributes: VisitAttributes{
+			VisitRoute:  []VisitRoute{{PageGroup: "-"}},
+			GaClientIDs: []string{""},
+			PageGroups:  []string{""},
+		}})
+	}
+	return nil, fmt.Errorf("%s have no fields to select, use leads or visits", endpoint)
+}
+
+// leadDocument is the lead as JSON object with its location added as location
+func leadDocument(l LeadData, location *Location) (map[string]interface{}, error) {
+	doc, err := toDocument(l)
+	if err != nil || location == nil {
+		return doc, err
+	}
+	loc, err := toDocument(location)
+	if err != nil {
+		return nil, err
+	}
+	attributes, _ := loc["attributes"].(map[string]interface{})
+	attributes["id"] = loc["id"]
+	doc["location"] = attributes
+	return doc, nil
+}
+
+// toDocument converts a record to the generic form of its JSON encoding
+func toDocument(v interface{}) (map[string]interface{}, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var doc map[string]interface{}
+	err = json.Unmarshal(b, &doc)
+	return doc, err
+}
+
+// lookup follows a dotted path through objects and lists (by index), nil if
+// any part is missing
+func lookup(doc interface{}, path string) interface{} {
+	current := doc
+	for _, key := range strings.Split(path, ".") {
+		switch c := current.(type) {
+		case map[string]interface{}:
+			current = c[key]
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(c) {
+				return nil
+			}
+			current = c[i]
+		default:
+			return nil
+		}
+	}
+	return current
+}
+
+// pathExists checks path against the zero value of a record. Below a list
+// only the index is checked, because the elements of empty lists are unknown.
+func pathExists(schema map[string]interface{}, path string) bool {
+	var current interface{} = schema
+	for _, key := range strings.Split(path, ".") {
+		switch c := current.(type) {
+		case map[string]interface{}:
+			next, ok := c[key]
+			if !ok {
+				return false
+			}
+			current = next
+		case []interface{}, nil:
+			// lists are null in the zero value
+			return true
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func marshalNoEscape(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	e := json.NewEncoder(&buf)
+	e.SetEscapeHTML(false)
+	if err := e.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimRight(buf.Bytes(), "\n"), nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,101 @@
+/*
+Copyright © 2021 willbenica
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package internal
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestProjectLeads(t *testing.T) {
+	ds, err := ReadDataFiles(TEST_FOLDER + L1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ds.Leads[0].Attributes.Tags = []string{"hot", "ICP"}
+
+	p, err := NewProjection("leads", []string{"id", "attributes.name", "attributes.quality", "attributes.tags", "location.city"},
+		map[string]string{"attributes.name": "company"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	records, err := ProjectLeads(ds.Leads, ds.Locations, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lead := ds.Leads[0]
+	city := LocationIndex(ds.Locations)[lead.Relationships.Location.Data.ID].Attributes.City
+	expectedJSON := `{"id":"` + lead.ID + `","company":"` + lead.Attributes.Name + `","attributes.quality":1,"attributes.tags":["hot","ICP"],"location.city":"` + city + `"}` + "\n"
+	if got := strings.SplitAfter(RecordsNDJSON(records), "\n")[0]; got != expectedJSON {
+		t.Errorf("expected\n%s\ngot\n%s", expectedJSON, got)
+	}
+
+	table := RecordsTable(records, p)
+	expectedRow := []string{lead.ID, lead.Attributes.Name, "1", "hot;ICP", city}
+	if !reflect.DeepEqual(table.Columns, []string{"id", "company", "attributes.quality", "attributes.tags", "location.city"}) {
+		t.Errorf("unexpected columns %v", table.Columns)
+	}
+	if !reflect.DeepEqual(table.Rows[0], expectedRow) {
+		t.Errorf("expected row %v, got %v", expectedRow, table.Rows[0])
+	}
+}
+
+func TestProjectVisitRoute(t *testing.T) {
+	ds, err := ReadDataFiles(TEST_FOLDER + V1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := NewProjection("visits", []string{"id", "attributes.visit_route.0.page_path", "attributes.visit_route.99.page_path"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	records, err := ProjectVisits(ds.Visits, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := records[0][1].Value; got != ds.Visits[0].Attributes.VisitRoute[0].PagePath {
+		t.Errorf("expected the entry page, got %v", got)
+	}
+	if got := records[0][2].Value; got != nil {
+		t.Errorf("expected nil for a missing step, got %v", got)
+	}
+}
+
+func TestNewProjectionErrors(t *testing.T) {
+	cases := []struct {
+		name     string
+		endpoint string
+		paths    []string
+		rename   map[string]string
+	}{
+		{"unknown attribute", "leads", []string{"attributes.nme"}, nil},
+		{"location of a visit", "visits", []string{"location.city"}, nil},
+		{"below a plain value", "leads", []string{"attributes.name.first"}, nil},
+		{"rename of an unselected field", "leads", []string{"id"}, map[string]string{"attributes.name": "company"}},
+		{"duplicate names", "leads", []string{"id", "attributes.name"}, map[string]string{"attributes.name": "id"}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if _, err := NewProjection(c.endpoint, c.paths, c.rename); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
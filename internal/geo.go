@@ -0,0 +1,245 @@
+/*
+Copyright © 2021 willbenica
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package internal
+
+import (
+	// centroids.csv is embedded
+	_ "embed"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Levels of the geographic breakdown
+const (
+	GeoCountries = "countries"
+	GeoRegions   = "regions"
+	GeoCities    = "cities"
+)
+
+// GeoLevels lists the levels of the geographic breakdown
+var GeoLevels = []string{GeoCountries, GeoRegions, GeoCities}
+
+// centroidsCSV is the approximate centre of every country and of the regions
+// of some countries, so that GeoJSON can be written offline
+//
+//go:embed centroids.csv
+var centroidsCSV string
+
+// Centroid is a point in degrees
+type Centroid struct {
+	Latitude  float64
+	Longitude float64
+}
+
+// centroids are keyed by country code, and by country code and region code or
+// lower case region name separated by "/"
+var centroids map[string]Centroid
+
+// GeoStats are the leads and visits of a country, region or city. Region and
+// City are only set for the levels that have them.
+type GeoStats struct {
+	CountryCode      string `json:"country_code"`
+	Country          string `json:"country"`
+	Region           string `json:"region,omitempty"`
+	City             string `json:"city,omitempty"`
+	Leads            int    `json:"leads"`
+	Visits           int    `json:"visits"`
+	TotalVisitLength int    `json:"total_visit_length"`
+	regionCode       string
+}
+
+// GeoJSON is a FeatureCollection of points
+type GeoJSON struct {
+	Type     string           `json:"type"`
+	Features []GeoJSONFeature `json:"features"`
+}
+
+// GeoJSONFeature is a point with the GeoStats of the place as properties
+type GeoJSONFeature struct {
+	Type       string       `json:"type"`
+	Geometry   GeoJSONPoint `json:"geometry"`
+	Properties GeoStats     `json:"properties"`
+}
+
+// GeoJSONPoint has its coordinates as longitude, latitude like all GeoJSON
+type GeoJSONPoint struct {
+	Type        string     `json:"type"`
+	Coordinates [2]float64 `json:"coordinates"`
+}
+
+// IsValidGeoLevel returns `true` if leads can be grouped by level
+func IsValidGeoLevel(level string) bool {
+	for _, l := range GeoLevels {
+		if l == level {
+			return true
+		}
+	}
+	return false
+}
+
+// LookupCentroid returns the centre of a region by its code or name, or of
+// the country if the region is unknown
+func LookupCentroid(countryCode string, regionCode string, region string) (Centroid, bool) {
+	if centroids == nil {
+		var err error
+		if centroids, err = parseCentroids(strings.NewReader(centroidsCSV)); err != nil {
+			panic("invalid centroids.csv: " + err.Error())
+		}
+	}
+	countryCode = strings.ToUpper(countryCode)
+	for _, key := range []string{strings.ToUpper(regionCode), strings.ToLower(region)} {
+		if key == "" {
+			continue
+		}
+		if c, ok := centroids[countryCode+"/"+key]; ok {
+			return c, true
+		}
+	}
+	c, ok := centroids[countryCode]
+	return c, ok
+}
+
+func parseCentroids(r io.Reader) (map[string]Centroid, error) {
+	records, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	table := map[string]Centroid{}
+	for i, record := range records[1:] {
+		var c Centroid
+		if c.Latitude, err = strconv.ParseFloat(record[3], 64); err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+2, err)
+		}
+		if c.Longitude, err = strconv.ParseFloat(record[4], 64); err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+2, err)
+		}
+		country := record[0]
+		if record[1] == "" && record[2] == "" {
+			table[country] = c
+			continue
+		}
+		table[country+"/"+record[1]] = c
+		table[country+"/"+strings.ToLower(record[2])] = c
+	}
+	return table, nil
+}
+
+// GeoBreakdown groups leads by the country, region or city of their location
+// and counts their visits. Leads without a location are in the NoValue
+// country. The places with the most leads come first.
+func GeoBreakdown(leads []LeadData, locations []Location, visits []VisitData, level string) ([]GeoStats, error) {
+	if !IsValidGeoLevel(level) {
+		return nil, fmt.Errorf("unknown level %q, use %s", level, strings.Join(GeoLevels, ", "))
+	}
+	index := LocationIndex(locations)
+	place := map[string]string{}
+	byPlace := map[string]int{}
+	var stats []GeoStats
+	for _, l := range leads {
+		s := GeoStats{CountryCode: NoValue, Country: NoValue}
+		if loc, ok := index[l.Relationships.Location.Data.ID]; ok {
+			a := loc.Attributes
+			s = GeoStats{CountryCode: a.CountryCode, Country: a.Country}
+			if level != GeoCountries {
+				s.Region, s.regionCode = a.Region, a.RegionCode
+			}
+			if level == GeoCities {
+				s.City = a.City
+			}
+		}
+		key := strings.Join([]string{s.CountryCode, s.Country, s.Region, s.City}, "/")
+		i, ok := byPlace[key]
+		if !ok {
+			i = len(stats)
+			byPlace[key] = i
+			stats = append(stats, s)
+		}
+		stats[i].Leads++
+		place[l.ID] = key
+	}
+	for _, v := range visits {
+		if key, ok := place[v.Attributes.LeadID]; ok {
+			s := &stats[byPlace[key]]
+			s.Visits++
+			s.TotalVisitLength += v.Attributes.VisitLength
+		}
+	}
+	sort.SliceStable(stats, func(i, j int) bool {
+		a, b := stats[i], stats[j]
+		if a.Leads != b.Leads {
+			return a.Leads > b.Leads
+		}
+		if a.Visits != b.Visits {
+			return a.Visits > b.Visits
+		}
+		return a.name() < b.name()
+	})
+	return stats, nil
+}
+
+func (s GeoStats) name() string {
+	return joinNonEmpty(", ", s.City, s.Region, s.Country)
+}
+
+// GeoTable flattens stats with the columns of the level
+func GeoTable(stats []GeoStats, level string) Table {
+	t := Table{Columns: []string{"country_code", "country"}}
+	if level != GeoCountries {
+		t.Columns = append(t.Columns, "region")
+	}
+	if level == GeoCities {
+		t.Columns = append(t.Columns, "city")
+	}
+	t.Columns = append(t.Columns, "leads", "visits", "total_visit_length")
+	for _, s := range stats {
+		row := []string{s.CountryCode, s.Country}
+		if level != GeoCountries {
+			row = append(row, s.Region)
+		}
+		if level == GeoCities {
+			row = append(row, s.City)
+		}
+		t.Rows = append(t.Rows, append(row, strconv.Itoa(s.Leads), strconv.Itoa(s.Visits), strconv.Itoa(s.TotalVisitLength)))
+	}
+	return t
+}
+
+// GeoFeatures places each country, region or city at the centroid of its
+// region or country. Cities are placed at their region. It also returns how
+// many places have no known centroid and were left out.
+func GeoFeatures(stats []GeoStats) (GeoJSON, int) {
+	g := GeoJSON{Type: "FeatureCollection", Features: []GeoJSONFeature{}}
+	missing := 0
+	for _, s := range stats {
+		c, ok := LookupCentroid(s.CountryCode, s.regionCode, s.Region)
+		if !ok {
+			missing++
+			continue
+		}
+		g.Features = append(g.Features, GeoJSONFeature{
+			Type:       "Feature",
+			Geometry:   GeoJSONPoint{Type: "Point", Coordinates: [2]float64{c.Longitude, c.Latitude}},
+			Properties: s,
+		})
+	}
+	return g, missing
+}
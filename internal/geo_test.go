@@ -0,0 +1,150 @@
+/*
+Copyright © 2021 willbenica
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package internal
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLookupCentroid(t *testing.T) {
+	cases := []struct {
+		country, regionCode, region string
+		expected                    Centroid
+		ok                          bool
+	}{
+		{"DE", "", "", Centroid{51.17, 10.45}, true},
+		{"de", "", "Saxony", Centroid{51.1, 13.2}, true},
+		{"US", "CA", "", Centroid{36.12, -119.68}, true},
+		{"US", "", "california", Centroid{36.12, -119.68}, true},
+		// An unknown region falls back to the country
+		{"FR", "IDF", "Île-de-France", Centroid{46.23, 2.21}, true},
+		{"ZZ", "", "", Centroid{}, false},
+		{NoValue, "", "", Centroid{}, false},
+	}
+	for _, c := range cases {
+		got, ok := LookupCentroid(c.country, c.regionCode, c.region)
+		if ok != c.ok || got != c.expected {
+			t.Errorf("%s %s %s: expected %v %t, got %v %t", c.country, c.regionCode, c.region, c.expected, c.ok, got, ok)
+		}
+	}
+}
+
+func TestCentroidsTable(t *testing.T) {
+	table, err := parseCentroids(strings.NewReader(centroidsCSV))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, c := range table {
+		if c.Latitude < -90 || c.Latitude > 90 || c.Longitude < -180 || c.Longitude > 180 {
+			t.Errorf("%s: %v is not a valid point", key, c)
+		}
+	}
+}
+
+func geoTestData() ([]LeadData, []Location, []VisitData) {
+	lead := func(id string, location string) LeadData {
+		return LeadData{ID: id, Relationships: Relationships{Location: RelatedLocaction{Data: LocData{ID: location}}}}
+	}
+	leads := []LeadData{lead("a", "dresden"), lead("b", "leipzig"), lead("c", "austin"), lead("d", "")}
+	locations := []Location{
+		{ID: "dresden", Attributes: LocationAttributes{Country: "Germany", CountryCode: "DE", Region: "Saxony", City: "Dresden"}},
+		{ID: "leipzig", Attributes: LocationAttributes{Country: "Germany", CountryCode: "DE", Region: "Saxony", City: "Leipzig"}},
+		{ID: "austin", Attributes: LocationAttributes{Country: "United States", CountryCode: "US", Region: "Texas", RegionCode: "TX", City: "Austin"}},
+	}
+	visits := []VisitData{
+		{Attributes: VisitAttributes{LeadID: "a", VisitLength: 10}},
+		{Attributes: VisitAttributes{LeadID: "a", VisitLength: 20}},
+		{Attributes: VisitAttributes{LeadID: "c", VisitLength: 5}},
+		{Attributes: VisitAttributes{LeadID: "x", VisitLength: 99}},
+	}
+	return leads, locations, visits
+}
+
+func TestGeoBreakdown(t *testing.T) {
+	leads, locations, visits := geoTestData()
+	cases := []struct {
+		level    string
+		expected []GeoStats
+	}{
+		{GeoCountries, []GeoStats{
+			{CountryCode: "DE", Country: "Germany", Leads: 2, Visits: 2, TotalVisitLength: 30},
+			{CountryCode: "US", Country: "United States", Leads: 1, Visits: 1, TotalVisitLength: 5},
+			{CountryCode: NoValue, Country: NoValue, Leads: 1},
+		}},
+		{GeoCities, []GeoStats{
+			{CountryCode: "DE", Country: "Germany", Region: "Saxony", City: "Dresden", Leads: 1, Visits: 2, TotalVisitLength: 30},
+			{CountryCode: "US", Country: "United States", Region: "Texas", City: "Austin", Leads: 1, Visits: 1, TotalVisitLength: 5, regionCode: "TX"},
+			{CountryCode: NoValue, Country: NoValue, Leads: 1},
+			{CountryCode: "DE", Country: "Germany", Region: "Saxony", City: "Leipzig", Leads: 1},
+		}},
+	}
+	for _, c := range cases {
+		t.Run(c.level, func(t *testing.T) {
+			stats, err := GeoBreakdown(leads, locations, visits, c.level)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(stats, c.expected) {
+				t.Errorf("expected\n%+v\ngot\n%+v", c.expected, stats)
+			}
+		})
+	}
+
+	if _, err := GeoBreakdown(leads, locations, visits, "continents"); err == nil {
+		t.Error("expected an error for an unknown level")
+	}
+}
+
+func TestGeoTable(t *testing.T) {
+	leads, locations, visits := geoTestData()
+	stats, err := GeoBreakdown(leads[:1], locations, visits, GeoRegions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := Table{
+		Columns: []string{"country_code", "country", "region", "leads", "visits", "total_visit_length"},
+		Rows:    [][]string{{"DE", "Germany", "Saxony", "1", "2", "30"}},
+	}
+	if table := GeoTable(stats, GeoRegions); !reflect.DeepEqual(table, expected) {
+		t.Errorf("expected %v, got %v", expected, table)
+	}
+}
+
+func TestGeoFeatures(t *testing.T) {
+	leads, locations, visits := geoTestData()
+	stats, err := GeoBreakdown(leads, locations, visits, GeoCities)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g, missing := GeoFeatures(stats)
+	if missing != 1 || len(g.Features) != 3 {
+		t.Fatalf("expected 3 features and 1 place left out, got %d and %d", len(g.Features), missing)
+	}
+	expected := []GeoJSONPoint{
+		{Type: "Point", Coordinates: [2]float64{13.2, 51.1}},
+		{Type: "Point", Coordinates: [2]float64{-97.56, 31.05}},
+		{Type: "Point", Coordinates: [2]float64{13.2, 51.1}},
+	}
+	for i, f := range g.Features {
+		if f.Type != "Feature" || f.Geometry != expected[i] {
+			t.Errorf("feature %d: expected %v, got %v", i, expected[i], f.Geometry)
+		}
+	}
+}
@@ -0,0 +1,213 @@
+/*
+Copyright © 2021 willbenica
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Formats of the navigation graph
+const (
+	GraphDOT    = "dot"
+	GraphSankey = "sankey"
+)
+
+// maxPenWidth is the width of the busiest edge in DOT, the others are scaled down to 1
+const maxPenWidth = 8.0
+
+// GraphNode is a page, section or an end of a visit with its number of views
+type GraphNode struct {
+	Name  string `json:"name"`
+	Views int    `json:"views"`
+}
+
+// Graph is the navigation graph: pages as nodes, transitions as edges
+type Graph struct {
+	Nodes []GraphNode
+	Edges []Transition
+}
+
+// SankeyLink is an edge of a Sankey diagram between the nodes at Source and Target
+type SankeyLink struct {
+	Source int `json:"source"`
+	Target int `json:"target"`
+	Value  int `json:"value"`
+}
+
+// Sankey is the input of d3-sankey and similar libraries
+type Sankey struct {
+	Nodes []GraphNode  `json:"nodes"`
+	Links []SankeyLink `json:"links"`
+}
+
+// IsValidGraphFormat returns `true` if the graph can be written in format
+func IsValidGraphFormat(format string) bool {
+	return format == GraphDOT || format == GraphSankey
+}
+
+// Section groups a page path by its first depth segments, e.g. /blog/seo/tips
+// is /blog/* with depth 1. Query strings are dropped, depth 0 keeps the path.
+func Section(path string, depth int) string {
+	if depth <= 0 {
+		return path
+	}
+	if i := strings.IndexAny(path, "?#"); i >= 0 {
+		path = path[:i]
+	}
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	if len(segments) <= depth {
+		return path
+	}
+	return "/" + strings.Join(segments[:depth], "/") + "/*"
+}
+
+// NavigationGraph builds the graph of a path report from its top busiest
+// transitions, all of them if top is 0. Loops from a page to itself, e.g.
+// within a section, are left out.
+func NavigationGraph(r PathReport, top int) Graph {
+	views := map[string]int{Entrance: r.Visits, Exit: r.Visits}
+	for _, p := range r.Pages {
+		views[p.Page] = p.Views
+	}
+
+	var g Graph
+	seen := map[string]bool{}
+	addNode := func(name string) {
+		if !seen[name] {
+			seen[name] = true
+			g.Nodes = append(g.Nodes, GraphNode{Name: name, Views: views[name]})
+		}
+	}
+	for _, t := range r.Transitions {
+		if t.From == t.To {
+			continue
+		}
+		if top > 0 && len(g.Edges) == top {
+			break
+		}
+		g.Edges = append(g.Edges, t)
+		addNode(t.From)
+		addNode(t.To)
+	}
+	return g
+}
+
+// WriteDOT writes the graph in the Graphviz DOT language, the busiest edges
+// are drawn thickest
+func (g Graph) WriteDOT(w io.Writer) error {
+	max := 1
+	for _, e := range g.Edges {
+		if e.Count > max {
+			max = e.Count
+		}
+	}
+	var b strings.Builder
+	b.WriteString("digraph navigation {\n")
+	b.WriteString("  rankdir=LR;\n")
+	b.WriteString("  node [shape=box, fontname=\"Helvetica\"];\n")
+	for _, n := range g.Nodes {
+		shape := ""
+		if n.Name == Entrance || n.Name == Exit {
+			shape = ", shape=oval"
+		}
+		fmt.Fprintf(&b, "  %s [label=%s%s];\n", dotID(n.Name), dotID(n.Name+"\n"+strconv.Itoa(n.Views)+" views"), shape)
+	}
+	for _, e := range g.Edges {
+		width := 1 + (maxPenWidth-1)*float64(e.Count)/float64(max)
+		fmt.Fprintf(&b, "  %s -> %s [label=\"%d\", penwidth=%s];\n", dotID(e.From), dotID(e.To), e.Count, strconv.FormatFloat(round2(width), 'f', -1, 64))
+	}
+	b.WriteString("}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// dotID quotes s as a DOT identifier
+func dotID(s string) string {
+	s = strings.ReplaceAll(s, "\\", "\\\\")
+	s = strings.ReplaceAll(s, "\"", "\\\"")
+	s = strings.ReplaceAll(s, "\n", "\\n")
+	return "\"" + s + "\""
+}
+
+// Sankey converts the graph for a Sankey diagram. Sankey diagrams can't
+// show loops, so an edge back to a page the flow already came from is
+// left out, starting with the least busy ones.
+func (g Graph) Sankey() Sankey {
+	s := Sankey{Nodes: []GraphNode{}, Links: []SankeyLink{}}
+	index := map[string]int{}
+	next := map[string][]string{}
+	nodeIndex := func(name string, views int) int {
+		i, ok := index[name]
+		if !ok {
+			i = len(s.Nodes)
+			index[name] = i
+			s.Nodes = append(s.Nodes, GraphNode{Name: name, Views: views})
+		}
+		return i
+	}
+	views := map[string]int{}
+	for _, n := range g.Nodes {
+		views[n.Name] = n.Views
+	}
+	// Edges are sorted by count, so the busiest edges of a loop are kept
+	for _, e := range g.Edges {
+		if reaches(next, e.To, e.From) {
+			continue
+		}
+		next[e.From] = append(next[e.From], e.To)
+		s.Links = append(s.Links, SankeyLink{Source: nodeIndex(e.From, views[e.From]), Target: nodeIndex(e.To, views[e.To]), Value: e.Count})
+	}
+	return s
+}
+
+// reaches reports whether to can be reached from from along next
+func reaches(next map[string][]string, from string, to string) bool {
+	seen := map[string]bool{}
+	stack := []string{from}
+	for len(stack) > 0 {
+		n := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if n == to {
+			return true
+		}
+		if seen[n] {
+			continue
+		}
+		seen[n] = true
+		stack = append(stack, next[n]...)
+	}
+	return false
+}
+
+// WriteGraph writes the graph as DOT or as Sankey JSON
+func (g Graph) WriteGraph(w io.Writer, format string) error {
+	switch format {
+	case GraphDOT:
+		return g.WriteDOT(w)
+	case GraphSankey:
+		e := json.NewEncoder(w)
+		e.SetEscapeHTML(false)
+		e.SetIndent("", "  ")
+		return e.Encode(g.Sankey())
+	}
+	return fmt.Errorf("unknown graph format %q, use dot or sankey", format)
+}
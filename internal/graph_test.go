@@ -0,0 +1,87 @@
+/*
+Copyright © 2021 willbenica
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package internal
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestSection(t *testing.T) {
+	cases := []struct {
+		path     string
+		depth    int
+		expected string
+	}{
+		{"/blog/seo/tips", 1, "/blog/*"},
+		{"/blog/seo/tips", 2, "/blog/seo/*"},
+		{"/blog/seo/tips?utm=x", 0, "/blog/seo/tips?utm=x"},
+		{"/pricing?plan=pro", 1, "/pricing"},
+		{"/", 1, "/"},
+	}
+	for _, c := range cases {
+		if got := Section(c.path, c.depth); got != c.expected {
+			t.Errorf("Section(%q, %d): expected %q, got %q", c.path, c.depth, c.expected, got)
+		}
+	}
+}
+
+func TestNavigationGraph(t *testing.T) {
+	r := Paths(pathsTestVisits(), PathFilter{}, nil)
+	g := NavigationGraph(r, 2)
+	expected := Graph{
+		Nodes: []GraphNode{{Entrance, 3}, {"/", 3}, {Exit, 3}},
+		Edges: []Transition{{From: Entrance, To: "/", Count: 2, Share: 66.67}, {From: "/", To: Exit, Count: 2, Share: 66.67}},
+	}
+	if !reflect.DeepEqual(g, expected) {
+		t.Errorf("expected\n%+v\ngot\n%+v", expected, g)
+	}
+
+	var buf bytes.Buffer
+	if err := g.WriteDOT(&buf); err != nil {
+		t.Fatal(err)
+	}
+	dot := `digraph navigation {
+  rankdir=LR;
+  node [shape=box, fontname="Helvetica"];
+  "(entrance)" [label="(entrance)\n3 views", shape=oval];
+  "/" [label="/\n3 views"];
+  "(exit)" [label="(exit)\n3 views", shape=oval];
+  "(entrance)" -> "/" [label="2", penwidth=8];
+  "/" -> "(exit)" [label="2", penwidth=8];
+}
+`
+	if buf.String() != dot {
+		t.Errorf("expected\n%s\ngot\n%s", dot, buf.String())
+	}
+}
+
+func TestSankeyLeavesOutLoops(t *testing.T) {
+	g := Graph{
+		Nodes: []GraphNode{{"/", 5}, {"/pricing", 4}, {"/blog", 1}},
+		Edges: []Transition{{From: "/", To: "/pricing", Count: 4}, {From: "/pricing", To: "/blog", Count: 2}, {From: "/blog", To: "/", Count: 1}},
+	}
+	expected := Sankey{
+		Nodes: []GraphNode{{"/", 5}, {"/pricing", 4}, {"/blog", 1}},
+		Links: []SankeyLink{{0, 1, 4}, {1, 2, 2}},
+	}
+	if got := g.Sankey(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %+v, got %+v", expected, got)
+	}
+}
@@ -0,0 +1,151 @@
+/*
+Copyright © 2021 willbenica
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// ContentGroupRule assigns pages to a content group. Pages match by a
+// regular expression or a glob on the page path, where * matches within a
+// path segment and ** across segments. Hostname optionally limits the rule
+// to one host.
+type ContentGroupRule struct {
+	Group    string `yaml:"group"`
+	Regex    string `yaml:"regex"`
+	Glob     string `yaml:"glob"`
+	Hostname string `yaml:"hostname"`
+	re       *regexp.Regexp
+}
+
+// ContentGroups is a rules file, the first matching rule names the group of a page
+type ContentGroups struct {
+	Groups []ContentGroupRule `yaml:"groups"`
+}
+
+// LoadContentGroups reads a content group rules file, e.g.
+//
+//	groups:
+//	  - group: Pricing
+//	    glob: /pricing*
+//	  - group: Blog
+//	    regex: ^/(blog|news)/
+func LoadContentGroups(path string) (*ContentGroups, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	g, err := ParseContentGroups(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return g, nil
+}
+
+// ParseContentGroups parses and checks the rules of a content group rules file
+func ParseContentGroups(data []byte) (*ContentGroups, error) {
+	var g ContentGroups
+	if err := yaml.UnmarshalStrict(data, &g); err != nil {
+		return nil, err
+	}
+	if len(g.Groups) == 0 {
+		return nil, errors.New("no groups defined")
+	}
+	for i := range g.Groups {
+		r := &g.Groups[i]
+		if r.Group == "" {
+			return nil, fmt.Errorf("rule %d has no group", i+1)
+		}
+		if (r.Regex == "") == (r.Glob == "") {
+			return nil, fmt.Errorf("rule %d (%s) needs either a regex or a glob", i+1, r.Group)
+		}
+		pattern := r.Regex
+		if r.Glob != "" {
+			pattern = globToRegex(r.Glob)
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("rule %d (%s): %w", i+1, r.Group, err)
+		}
+		r.re = re
+	}
+	return &g, nil
+}
+
+// globToRegex translates a glob on page paths to an anchored regular expression
+func globToRegex(glob string) string {
+	var b strings.Builder
+	b.WriteString("^")
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; c {
+		case '*':
+			if i+1 < len(glob) && glob[i+1] == '*' {
+				b.WriteString(".*")
+				i++
+			} else {
+				b.WriteString("[^/]*")
+			}
+		case '?':
+			b.WriteString("[^/]")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+	return b.String()
+}
+
+// Group returns the content group of a route step, "" if no rule matches
+func (g *ContentGroups) Group(step VisitRoute) string {
+	for _, r := range g.Groups {
+		if r.Hostname != "" && !strings.EqualFold(r.Hostname, step.Hostname) {
+			continue
+		}
+		if r.re.MatchString(step.PagePath) {
+			return r.Group
+		}
+	}
+	return ""
+}
+
+// Apply sets the page group of every route step and the sorted set of
+// groups of each visit, changing visits in place
+func (g *ContentGroups) Apply(visits []VisitData) {
+	for i := range visits {
+		seen := map[string]bool{}
+		var groups []string
+		route := visits[i].Attributes.VisitRoute
+		for j := range route {
+			group := g.Group(route[j])
+			route[j].PageGroup = group
+			if group != "" && !seen[group] {
+				seen[group] = true
+				groups = append(groups, group)
+			}
+		}
+		sort.Strings(groups)
+		visits[i].Attributes.PageGroups = groups
+	}
+}
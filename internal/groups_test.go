@@ -0,0 +1,110 @@
+/*
+Copyright © 2021 willbenica
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package internal
+
+import (
+	"reflect"
+	"testing"
+)
+
+const testContentGroups = `
+groups:
+  - group: Pricing
+    glob: /pricing*
+  - group: Docs
+    glob: /**
+    hostname: docs.example.com
+  - group: Blog
+    regex: ^/(blog|news)/
+  - group: Product
+    glob: /product/*
+`
+
+func TestContentGroup(t *testing.T) {
+	g, err := ParseContentGroups([]byte(testContentGroups))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		host     string
+		path     string
+		expected string
+	}{
+		{"www.example.com", "/pricing", "Pricing"},
+		{"www.example.com", "/pricing?plan=pro", "Pricing"},
+		{"docs.example.com", "/api/leads", "Docs"},
+		{"www.example.com", "/news/2021/launch", "Blog"},
+		{"www.example.com", "/product/tracker", "Product"},
+		{"www.example.com", "/product/tracker/setup", ""},
+		{"www.example.com", "/", ""},
+	}
+	for _, c := range cases {
+		if got := g.Group(VisitRoute{Hostname: c.host, PagePath: c.path}); got != c.expected {
+			t.Errorf("%s%s: expected %q, got %q", c.host, c.path, c.expected, got)
+		}
+	}
+}
+
+func TestContentGroupsApply(t *testing.T) {
+	g, err := ParseContentGroups([]byte(testContentGroups))
+	if err != nil {
+		t.Fatal(err)
+	}
+	visits := []VisitData{{Attributes: VisitAttributes{VisitRoute: []VisitRoute{{PagePath: "/pricing"}, {PagePath: "/blog/seo"}, {PagePath: "/"}, {PagePath: "/pricing"}}}}}
+	g.Apply(visits)
+
+	if expected := []string{"Blog", "Pricing"}; !reflect.DeepEqual(visits[0].Attributes.PageGroups, expected) {
+		t.Errorf("expected page groups %v, got %v", expected, visits[0].Attributes.PageGroups)
+	}
+	var steps []string
+	for _, step := range visits[0].Attributes.VisitRoute {
+		steps = append(steps, step.PageGroup)
+	}
+	if expected := []string{"Pricing", "Blog", "", "Pricing"}; !reflect.DeepEqual(steps, expected) {
+		t.Errorf("expected step groups %v, got %v", expected, steps)
+	}
+
+	stats, err := VisitStats(visits, DimensionGroups)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stats) != 2 || stats[0].Name != "Blog" || stats[1].Name != "Pricing" {
+		t.Errorf("expected the visit to count for Blog and Pricing, got %+v", stats)
+	}
+}
+
+func TestParseContentGroupsErrors(t *testing.T) {
+	cases := []struct {
+		name string
+		yaml string
+	}{
+		{"no groups", "groups: []"},
+		{"unknown key", "groups:\n  - group: A\n    glob: /a\n    path: /a"},
+		{"missing group", "groups:\n  - glob: /a"},
+		{"regex and glob", "groups:\n  - group: A\n    glob: /a\n    regex: ^/a"},
+		{"neither regex nor glob", "groups:\n  - group: A"},
+		{"invalid regex", "groups:\n  - group: A\n    regex: ("},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if _, err := ParseContentGroups([]byte(c.yaml)); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,209 @@
+/*
+Copyright © 2021 willbenica
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package internal
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Metrics of a heatmap
+const (
+	HeatmapVisits      = "visits"
+	HeatmapVisitLength = "visit_length"
+)
+
+// Weekdays are the rows of a heatmap, starting on Monday
+var Weekdays = []string{"Mon", "Tue", "Wed", "Thu", "Fri", "Sat", "Sun"}
+
+// heatColors are the 256 color backgrounds of the heatmap from cold to hot
+var heatColors = []int{22, 28, 34, 40, 46}
+
+// HeatmapFilter selects the visits of a heatmap, empty fields select everything
+type HeatmapFilter struct {
+	Campaign string
+	// Industry keeps the visits of leads in this industry, ignoring case
+	Industry string
+}
+
+// Heatmap is the number of visits or their total length by weekday and hour
+type Heatmap struct {
+	Metric   string     `json:"metric"`
+	Weekdays []string   `json:"weekdays"`
+	Values   [7][24]int `json:"values"`
+}
+
+// VisitHeatmap adds up the visits, or their length, by the weekday of their
+// date and their hour, both in the time zone of the account. leads are only
+// needed to filter by industry.
+func VisitHeatmap(visits []VisitData, leads []LeadData, f HeatmapFilter, metric string) (Heatmap, error) {
+	if metric != HeatmapVisits && metric != HeatmapVisitLength {
+		return Heatmap{}, fmt.Errorf("unknown metric %q, use %s or %s", metric, HeatmapVisits, HeatmapVisitLength)
+	}
+	industries := map[string]string{}
+	for _, l := range leads {
+		industries[l.ID] = l.Attributes.Industry
+	}
+	h := Heatmap{Metric: metric, Weekdays: Weekdays}
+	for _, v := range visits {
+		a := v.Attributes
+		if f.Campaign != "" && a.Campaign != f.Campaign {
+			continue
+		}
+		if f.Industry != "" && !strings.EqualFold(industries[a.LeadID], f.Industry) {
+			continue
+		}
+		day, hour, ok := visitTime(a)
+		if !ok {
+			continue
+		}
+		if metric == HeatmapVisits {
+			h.Values[day][hour]++
+		} else {
+			h.Values[day][hour] += a.VisitLength
+		}
+	}
+	return h, nil
+}
+
+// visitTime returns the weekday (0 is Monday) and hour of a visit from its
+// date and hour, or from when it started if the date is missing
+func visitTime(a VisitAttributes) (int, int, bool) {
+	t, hour := a.StartedAt, a.StartedAt.Hour()
+	if a.Date != "" {
+		d, err := time.Parse("2006-01-02", a.Date)
+		if err != nil {
+			return 0, 0, false
+		}
+		t, hour = d, a.Hour
+	} else if t.IsZero() {
+		return 0, 0, false
+	}
+	if hour < 0 || hour > 23 {
+		return 0, 0, false
+	}
+	return (int(t.Weekday()) + 6) % 7, hour, true
+}
+
+// Max returns the highest value of the heatmap
+func (h Heatmap) Max() int {
+	max := 0
+	for _, hours := range h.Values {
+		for _, v := range hours {
+			if v > max {
+				max = v
+			}
+		}
+	}
+	return max
+}
+
+// Table flattens the heatmap with a row per weekday and a column per hour
+func (h Heatmap) Table() Table {
+	t := Table{Columns: []string{"weekday"}}
+	for hour := 0; hour < 24; hour++ {
+		t.Columns = append(t.Columns, strconv.Itoa(hour))
+	}
+	t.Columns = append(t.Columns, "total")
+	for day, hours := range h.Values {
+		row := []string{h.Weekdays[day]}
+		total := 0
+		for _, v := range hours {
+			row = append(row, strconv.Itoa(v))
+			total += v
+		}
+		t.Rows = append(t.Rows, append(row, strconv.Itoa(total)))
+	}
+	return t
+}
+
+// WriteGrid draws the heatmap as a grid of weekdays by hours. With color the
+// cells are shaded from cold to hot, empty cells are left blank. A width
+// above 0 is the width of the terminal: the cells lose their space if the
+// grid doesn't fit, then the last hours are left out.
+func (h Heatmap) WriteGrid(w io.Writer, color bool, width int) error {
+	// Cells fit the 4 characters of compactNumber and a space
+	cell, hours := 5, 24
+	if width > 0 && gridWidth(cell, hours) > width {
+		cell = 4
+		for hours > 1 && gridWidth(cell, hours) > width {
+			hours--
+		}
+	}
+	max := h.Max()
+	var b strings.Builder
+	b.WriteString("   ")
+	for hour := 0; hour < hours; hour++ {
+		fmt.Fprintf(&b, "%*d", cell, hour)
+	}
+	fmt.Fprintf(&b, "%*s\n", cell+3, "total")
+	for day, values := range h.Values {
+		b.WriteString(h.Weekdays[day])
+		total := 0
+		for hour, v := range values {
+			total += v
+			if hour >= hours {
+				continue
+			}
+			value := ""
+			if v > 0 {
+				value = compactNumber(v)
+			}
+			if !color || v == 0 {
+				fmt.Fprintf(&b, "%*s", cell, value)
+				continue
+			}
+			level := (v*len(heatColors) - 1) / max
+			fmt.Fprintf(&b, "\x1b[38;5;16;48;5;%dm%*s\x1b[0m", heatColors[level], cell, value)
+		}
+		fmt.Fprintf(&b, "%*s\n", cell+3, compactNumber(total))
+	}
+	unit := "visits"
+	if h.Metric == HeatmapVisitLength {
+		unit = "seconds of visits"
+	}
+	fmt.Fprintf(&b, "%s by weekday and hour, at most %s in an hour\n", unit, compactNumber(max))
+	if hours < 24 {
+		fmt.Fprintf(&b, "hours %d to 23 don't fit the terminal and count only in the totals, -o csv shows all hours\n", hours)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// gridWidth is the width of a grid line with hours cells of width cell
+func gridWidth(cell int, hours int) int {
+	return 3 + hours*cell + cell + 3
+}
+
+// compactNumber shortens numbers from 1000 on to e.g. 1.2k or 15k to fit a cell
+func compactNumber(n int) string {
+	switch {
+	case n >= 10000000:
+		return strconv.Itoa(n/1000000) + "M"
+	case n >= 1000000:
+		return strconv.FormatFloat(float64(n/100000)/10, 'f', -1, 64) + "M"
+	case n >= 10000:
+		return strconv.Itoa(n/1000) + "k"
+	case n >= 1000:
+		return strconv.FormatFloat(float64(n/100)/10, 'f', -1, 64) + "k"
+	}
+	return strconv.Itoa(n)
+}
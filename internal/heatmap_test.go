@@ -0,0 +1,166 @@
+/*
+Copyright © 2021 willbenica
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package internal
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func heatmapTestVisits() []VisitData {
+	return []VisitData{
+		// 2021-05-24 is a Monday, the hour is in the account's time zone
+		{Attributes: VisitAttributes{LeadID: "a", Date: "2021-05-24", Hour: 6, VisitLength: 30, Campaign: "spring", StartedAt: time.Date(2021, 5, 24, 13, 0, 0, 0, time.UTC)}},
+		{Attributes: VisitAttributes{LeadID: "a", Date: "2021-05-24", Hour: 6, VisitLength: 10}},
+		{Attributes: VisitAttributes{LeadID: "b", Date: "2021-05-30", Hour: 23, VisitLength: 1500, Campaign: "spring"}},
+		// Without a date the start is used
+		{Attributes: VisitAttributes{LeadID: "b", StartedAt: time.Date(2021, 5, 26, 9, 30, 0, 0, time.UTC), VisitLength: 5}},
+		{Attributes: VisitAttributes{LeadID: "b", Date: "not a date"}},
+	}
+}
+
+func TestVisitHeatmap(t *testing.T) {
+	leads := []LeadData{
+		{ID: "a", Attributes: LeadAttributes{Industry: "Software"}},
+		{ID: "b", Attributes: LeadAttributes{Industry: "Retail"}},
+	}
+	cases := []struct {
+		name     string
+		filter   HeatmapFilter
+		metric   string
+		expected map[[2]int]int
+	}{
+		{"visits", HeatmapFilter{}, HeatmapVisits, map[[2]int]int{{0, 6}: 2, {6, 23}: 1, {2, 9}: 1}},
+		{"visit length", HeatmapFilter{}, HeatmapVisitLength, map[[2]int]int{{0, 6}: 40, {6, 23}: 1500, {2, 9}: 5}},
+		{"campaign", HeatmapFilter{Campaign: "spring"}, HeatmapVisits, map[[2]int]int{{0, 6}: 1, {6, 23}: 1}},
+		{"industry", HeatmapFilter{Industry: "software"}, HeatmapVisits, map[[2]int]int{{0, 6}: 2}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			h, err := VisitHeatmap(heatmapTestVisits(), leads, c.filter, c.metric)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for day, hours := range h.Values {
+				for hour, v := range hours {
+					if expected := c.expected[[2]int{day, hour}]; v != expected {
+						t.Errorf("%s %d:00: expected %d, got %d", Weekdays[day], hour, expected, v)
+					}
+				}
+			}
+		})
+	}
+
+	if _, err := VisitHeatmap(nil, nil, HeatmapFilter{}, "pages"); err == nil {
+		t.Error("expected an error for an unknown metric")
+	}
+}
+
+func TestHeatmapTable(t *testing.T) {
+	h, err := VisitHeatmap(heatmapTestVisits(), nil, HeatmapFilter{}, HeatmapVisits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	table := h.Table()
+	if len(table.Columns) != 26 || table.Columns[0] != "weekday" || table.Columns[1] != "0" || table.Columns[25] != "total" {
+		t.Errorf("expected weekday, the hours and total as columns, got %v", table.Columns)
+	}
+	if row := table.Rows[0]; row[0] != "Mon" || row[7] != "2" || row[25] != "2" {
+		t.Errorf("expected 2 visits on Monday at 6, got %v", row)
+	}
+}
+
+func TestHeatmapWriteGrid(t *testing.T) {
+	h, err := VisitHeatmap(heatmapTestVisits(), nil, HeatmapFilter{}, HeatmapVisitLength)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b bytes.Buffer
+	if err := h.WriteGrid(&b, false, 0); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(b.String(), "\n")
+	if len(lines) != 10 || !strings.HasPrefix(lines[1], "Mon") || !strings.HasPrefix(lines[7], "Sun") {
+		t.Fatalf("expected a header, 7 weekdays and a legend, got\n%s", b.String())
+	}
+	if expected := "Sun" + strings.Repeat(" ", 5*23) + " 1.5k    1.5k"; lines[7] != expected {
+		t.Errorf("expected\n%q\ngot\n%q", expected, lines[7])
+	}
+	if strings.Contains(b.String(), "\x1b[") {
+		t.Error("expected no colors")
+	}
+
+	b.Reset()
+	if err := h.WriteGrid(&b, true, 0); err != nil {
+		t.Fatal(err)
+	}
+	// The hottest cell gets the last color, the others the first
+	if !strings.Contains(b.String(), "\x1b[38;5;16;48;5;46m 1.5k\x1b[0m") || !strings.Contains(b.String(), "\x1b[38;5;16;48;5;22m   40\x1b[0m") {
+		t.Errorf("expected shaded cells, got %q", b.String())
+	}
+}
+
+func TestHeatmapWriteGridWidth(t *testing.T) {
+	h, err := VisitHeatmap(heatmapTestVisits(), nil, HeatmapFilter{}, HeatmapVisitLength)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		name   string
+		width  int
+		header string
+		legend bool
+	}{
+		{"wide terminal", 140, "    0    1", false},
+		{"compact cells", 110, "   0   1", false},
+		{"last hours left out", 80, "   0   1", true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var b bytes.Buffer
+			if err := h.WriteGrid(&b, false, c.width); err != nil {
+				t.Fatal(err)
+			}
+			lines := strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n")
+			if !strings.HasPrefix(lines[0], "   "+c.header) {
+				t.Errorf("expected the header to start with %q, got %q", c.header, lines[0])
+			}
+			for _, line := range lines[:8] {
+				if len(line) > c.width {
+					t.Errorf("expected at most %d characters, got %d in %q", c.width, len(line), line)
+				}
+			}
+			if !strings.HasSuffix(lines[7], "1.5k") {
+				t.Errorf("expected the total of all hours, got %q", lines[7])
+			}
+			if legend := strings.Contains(b.String(), "don't fit the terminal"); legend != c.legend {
+				t.Errorf("expected the note on left out hours to be %v, got\n%s", c.legend, b.String())
+			}
+		})
+	}
+}
+
+func TestCompactNumber(t *testing.T) {
+	for n, expected := range map[int]string{0: "0", 999: "999", 1000: "1k", 1250: "1.2k", 15400: "15k", 999999: "999k", 1500000: "1.5M", 25000000: "25M"} {
+		if got := compactNumber(n); got != expected {
+			t.Errorf("expected %d as %q, got %q", n, expected, got)
+		}
+	}
+}
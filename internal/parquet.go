@@ -0,0 +1,231 @@
+/*
+Copyright © 2021 willbenica
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package internal
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/writer"
+	"go.uber.org/zap"
+)
+
+// FormatParquet writes leads, locations and visits as Parquet files
+const FormatParquet = "parquet"
+
+// parquetWriters is the number of goroutines used to encode a row group
+const parquetWriters = 4
+
+// ParquetCompressions maps the values of --parquet-compression to codecs
+var ParquetCompressions = map[string]parquet.CompressionCodec{
+	"none":   parquet.CompressionCodec_UNCOMPRESSED,
+	"snappy": parquet.CompressionCodec_SNAPPY,
+	"gzip":   parquet.CompressionCodec_GZIP,
+	"zstd":   parquet.CompressionCodec_ZSTD,
+}
+
+// ParquetOptions controls how Parquet files are written
+type ParquetOptions struct {
+	// Compression is one of the keys of ParquetCompressions
+	Compression string
+	// RowGroupSize is the size of a row group in bytes
+	RowGroupSize int64
+}
+
+// DefaultParquetOptions are used when no options are given
+var DefaultParquetOptions = ParquetOptions{Compression: "snappy", RowGroupSize: 128 * 1024 * 1024}
+
+type parquetLead struct {
+	ID                string   `parquet:"name=id, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Name              string   `parquet:"name=name, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Status            string   `parquet:"name=status, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Industry          string   `parquet:"name=industry, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Quality           int32    `parquet:"name=quality, type=INT32"`
+	Visits            int32    `parquet:"name=visits, type=INT32"`
+	EmployeeCount     int32    `parquet:"name=employee_count, type=INT32"`
+	FirstVisitDate    *int32   `parquet:"name=first_visit_date, type=INT32, convertedtype=DATE, repetitiontype=OPTIONAL"`
+	LastVisitDate     *int32   `parquet:"name=last_visit_date, type=INT32, convertedtype=DATE, repetitiontype=OPTIONAL"`
+	WebsiteURL        string   `parquet:"name=website_url, type=BYTE_ARRAY, convertedtype=UTF8"`
+	LinkedinURL       string   `parquet:"name=linkedin_url, type=BYTE_ARRAY, convertedtype=UTF8"`
+	FacebookURL       string   `parquet:"name=facebook_url, type=BYTE_ARRAY, convertedtype=UTF8"`
+	TwitterHandle     string   `parquet:"name=twitter_handle, type=BYTE_ARRAY, convertedtype=UTF8"`
+	BusinessID        string   `parquet:"name=business_id, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Revenue           string   `parquet:"name=revenue, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Assignee          string   `parquet:"name=assignee, type=BYTE_ARRAY, convertedtype=UTF8"`
+	EmailedTo         string   `parquet:"name=emailed_to, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Phone             string   `parquet:"name=phone, type=BYTE_ARRAY, convertedtype=UTF8"`
+	CrmLeadID         string   `parquet:"name=crm_lead_id, type=BYTE_ARRAY, convertedtype=UTF8"`
+	CrmOrganizationID string   `parquet:"name=crm_organization_id, type=BYTE_ARRAY, convertedtype=UTF8"`
+	LogoURL           string   `parquet:"name=logo_url, type=BYTE_ARRAY, convertedtype=UTF8"`
+	ViewInLeadfeeder  string   `parquet:"name=view_in_leadfeeder, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Tags              []string `parquet:"name=tags, type=BYTE_ARRAY, convertedtype=UTF8, repetitiontype=REPEATED"`
+	LocationID        *string  `parquet:"name=location_id, type=BYTE_ARRAY, convertedtype=UTF8, repetitiontype=OPTIONAL"`
+}
+
+type parquetLocation struct {
+	ID          string `parquet:"name=id, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Country     string `parquet:"name=country, type=BYTE_ARRAY, convertedtype=UTF8"`
+	CountryCode string `parquet:"name=country_code, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Region      string `parquet:"name=region, type=BYTE_ARRAY, convertedtype=UTF8"`
+	RegionCode  string `parquet:"name=region_code, type=BYTE_ARRAY, convertedtype=UTF8"`
+	City        string `parquet:"name=city, type=BYTE_ARRAY, convertedtype=UTF8"`
+	StateCode   string `parquet:"name=state_code, type=BYTE_ARRAY, convertedtype=UTF8"`
+}
+
+type parquetVisit struct {
+	ID           string             `parquet:"name=id, type=BYTE_ARRAY, convertedtype=UTF8"`
+	LeadID       *string            `parquet:"name=lead_id, type=BYTE_ARRAY, convertedtype=UTF8, repetitiontype=OPTIONAL"`
+	StartedAt    int64              `parquet:"name=started_at, type=INT64, convertedtype=TIMESTAMP_MILLIS"`
+	Date         *int32             `parquet:"name=date, type=INT32, convertedtype=DATE, repetitiontype=OPTIONAL"`
+	Hour         int32              `parquet:"name=hour, type=INT32"`
+	Source       string             `parquet:"name=source, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Medium       string             `parquet:"name=medium, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Campaign     string             `parquet:"name=campaign, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Keyword      string             `parquet:"name=keyword, type=BYTE_ARRAY, convertedtype=UTF8"`
+	QueryTerm    string             `parquet:"name=query_term, type=BYTE_ARRAY, convertedtype=UTF8"`
+	ReferringURL string             `parquet:"name=referring_url, type=BYTE_ARRAY, convertedtype=UTF8"`
+	PageDepth    int32              `parquet:"name=page_depth, type=INT32"`
+	VisitLength  int32              `parquet:"name=visit_length, type=INT32"`
+	LfClientID   string             `parquet:"name=lf_client_id, type=BYTE_ARRAY, convertedtype=UTF8"`
+	GaClientIDs  []string           `parquet:"name=ga_client_ids, type=BYTE_ARRAY, convertedtype=UTF8, repetitiontype=REPEATED"`
+	VisitRoute   []parquetRouteStep `parquet:"name=visit_route, repetitiontype=REPEATED"`
+	PageGroups   []string           `parquet:"name=page_groups, type=BYTE_ARRAY, convertedtype=UTF8, repetitiontype=REPEATED"`
+}
+
+type parquetRouteStep struct {
+	Hostname         string  `parquet:"name=hostname, type=BYTE_ARRAY, convertedtype=UTF8"`
+	PagePath         string  `parquet:"name=page_path, type=BYTE_ARRAY, convertedtype=UTF8"`
+	PreviousPagePath string  `parquet:"name=previous_page_path, type=BYTE_ARRAY, convertedtype=UTF8"`
+	TimeOnPage       int32   `parquet:"name=time_on_page, type=INT32"`
+	PageTitle        string  `parquet:"name=page_title, type=BYTE_ARRAY, convertedtype=UTF8"`
+	PageURL          string  `parquet:"name=page_url, type=BYTE_ARRAY, convertedtype=UTF8"`
+	DisplayPageName  string  `parquet:"name=display_page_name, type=BYTE_ARRAY, convertedtype=UTF8"`
+	PageGroup        *string `parquet:"name=page_group, type=BYTE_ARRAY, convertedtype=UTF8, repetitiontype=OPTIONAL"`
+}
+
+// WriteParquet writes Leads, Locations or Visits to w as a Parquet file
+func WriteParquet(w io.Writer, data interface{}, opts ParquetOptions) error {
+	Init()
+	codec, ok := ParquetCompressions[strings.ToLower(opts.Compression)]
+	if !ok {
+		return fmt.Errorf("unknown parquet compression %q, use none, snappy, gzip or zstd", opts.Compression)
+	}
+
+	var schema interface{}
+	var rows []interface{}
+	switch d := data.(type) {
+	case Leads:
+		schema = new(parquetLead)
+		for _, l := range d.Data {
+			rows = append(rows, toParquetLead(l))
+		}
+	case Locations:
+		schema = new(parquetLocation)
+		for _, l := range d.Data {
+			a := l.Attributes
+			rows = append(rows, parquetLocation{l.ID, a.Country, a.CountryCode, a.Region, a.RegionCode, a.City, a.StateCode})
+		}
+	case Visits:
+		schema = new(parquetVisit)
+		for _, v := range d.Data {
+			rows = append(rows, toParquetVisit(v))
+		}
+	default:
+		return fmt.Errorf("unable to write %T as parquet", data)
+	}
+
+	pw, err := writer.NewParquetWriterFromWriter(w, schema, parquetWriters)
+	if err != nil {
+		return err
+	}
+	pw.CompressionType = codec
+	if opts.RowGroupSize > 0 {
+		pw.RowGroupSize = opts.RowGroupSize
+	}
+	for _, row := range rows {
+		if err := pw.Write(row); err != nil {
+			return err
+		}
+	}
+	if err := pw.WriteStop(); err != nil {
+		return err
+	}
+	logger.Debug("Parquet data written", zap.Int("rows", len(rows)), zap.String("compression", opts.Compression))
+	return nil
+}
+
+// WriteParquetFile writes data as Parquet to filename under path
+func WriteParquetFile(path string, filename string, data interface{}, opts ParquetOptions) error {
+	file, err := CreateFile(path, filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if err := WriteParquet(file, data, opts); err != nil {
+		return err
+	}
+	return file.Sync()
+}
+
+func toParquetLead(l LeadData) parquetLead {
+	a := l.Attributes
+	return parquetLead{
+		ID: l.ID, Name: a.Name, Status: a.Status, Industry: a.Industry,
+		Quality: int32(a.Quality), Visits: int32(a.Visits), EmployeeCount: int32(a.EmployeeCount),
+		FirstVisitDate: parquetDate(a.FirstVisitDate), LastVisitDate: parquetDate(a.LastVisitDate),
+		WebsiteURL: a.WebsiteURL, LinkedinURL: a.LinkedinURL, FacebookURL: a.FacebookURL, TwitterHandle: a.TwitterHandle,
+		BusinessID: a.BusinessID, Revenue: a.Revenue, Assignee: a.Assignee, EmailedTo: a.EmailedTo, Phone: a.Phone,
+		CrmLeadID: a.CrmLeadID, CrmOrganizationID: a.CrmOrganizationID, LogoURL: a.LogoURL, ViewInLeadfeeder: a.ViewInLeadfeeder,
+		Tags: a.Tags, LocationID: optionalString(l.Relationships.Location.Data.ID),
+	}
+}
+
+func toParquetVisit(v VisitData) parquetVisit {
+	a := v.Attributes
+	route := make([]parquetRouteStep, len(a.VisitRoute))
+	for i, r := range a.VisitRoute {
+		route[i] = parquetRouteStep{r.Hostname, r.PagePath, r.PreviousPagePath, int32(r.TimeOnPage), r.PageTitle, r.PageURL, r.DisplayPageName, optionalString(r.PageGroup)}
+	}
+	return parquetVisit{
+		ID: v.ID, LeadID: optionalString(a.LeadID), StartedAt: a.StartedAt.UnixNano() / int64(time.Millisecond),
+		Date: parquetDate(a.Date), Hour: int32(a.Hour), Source: a.Source, Medium: a.Medium, Campaign: a.Campaign,
+		Keyword: a.Keyword, QueryTerm: a.QueryTerm, ReferringURL: a.ReferringURL,
+		PageDepth: int32(a.PageDepth), VisitLength: int32(a.VisitLength), LfClientID: a.LfClientID,
+		GaClientIDs: a.GaClientIDs, VisitRoute: route, PageGroups: a.PageGroups,
+	}
+}
+
+// parquetDate converts a YYYY-MM-DD date to days since the epoch, nil if the date is missing or invalid
+func parquetDate(date string) *int32 {
+	d, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		return nil
+	}
+	days := int32(d.Unix() / 86400)
+	return &days
+}
+
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
@@ -0,0 +1,105 @@
+/*
+Copyright © 2021 willbenica
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package internal
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/xitongsys/parquet-go-source/buffer"
+	"github.com/xitongsys/parquet-go/reader"
+)
+
+func TestWriteParquetRoundTrip(t *testing.T) {
+	ds, err := ReadDataFiles(TEST_FOLDER+L1, TEST_FOLDER+V1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ds.Visits[0].Attributes.PageGroups = []string{"Pricing", "Product"}
+	ds.Visits[0].Attributes.VisitRoute[0].PageGroup = "Pricing"
+
+	for compression := range ParquetCompressions {
+		var buf bytes.Buffer
+		if err := WriteParquet(&buf, Visits{Data: ds.Visits}, ParquetOptions{Compression: compression, RowGroupSize: 1024}); err != nil {
+			t.Fatalf("%s: writing visits failed: %q", compression, err)
+		}
+		file, _ := buffer.NewBufferFile(buf.Bytes())
+		pr, err := reader.NewParquetReader(file, new(parquetVisit), 1)
+		if err != nil {
+			t.Fatalf("%s: reading visits failed: %q", compression, err)
+		}
+		visits := make([]parquetVisit, pr.GetNumRows())
+		if err := pr.Read(&visits); err != nil {
+			t.Fatal(err)
+		}
+		pr.ReadStop()
+
+		if len(visits) != len(ds.Visits) {
+			t.Fatalf("%s: expected %d visits, got %d", compression, len(ds.Visits), len(visits))
+		}
+		for i, v := range visits {
+			expected := ds.Visits[i]
+			if v.ID != expected.ID || len(v.VisitRoute) != len(expected.Attributes.VisitRoute) {
+				t.Errorf("%s: visit %d was not read back: %+v", compression, i, v)
+			}
+			if started := time.Unix(0, v.StartedAt*int64(time.Millisecond)); !started.Equal(expected.Attributes.StartedAt) {
+				t.Errorf("%s: expected started_at %s, got %s", compression, expected.Attributes.StartedAt, started)
+			}
+		}
+		if groups := visits[0].PageGroups; !reflect.DeepEqual(groups, ds.Visits[0].Attributes.PageGroups) {
+			t.Errorf("%s: expected page_groups %v, got %v", compression, ds.Visits[0].Attributes.PageGroups, groups)
+		}
+		if group := visits[0].VisitRoute[0].PageGroup; group == nil || *group != "Pricing" {
+			t.Errorf("%s: expected the first step in the page group Pricing, got %v", compression, group)
+		}
+		if group := visits[0].VisitRoute[1].PageGroup; group != nil {
+			t.Errorf("%s: expected no page group for the second step, got %q", compression, *group)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := WriteParquet(&buf, Leads{Data: ds.Leads}, DefaultParquetOptions); err != nil {
+		t.Fatal(err)
+	}
+	file, _ := buffer.NewBufferFile(buf.Bytes())
+	pr, err := reader.NewParquetReader(file, new(parquetLead), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leads := make([]parquetLead, pr.GetNumRows())
+	if err := pr.Read(&leads); err != nil {
+		t.Fatal(err)
+	}
+	pr.ReadStop()
+	if len(leads) != len(ds.Leads) {
+		t.Fatalf("expected %d leads, got %d", len(ds.Leads), len(leads))
+	}
+	first := leads[0].FirstVisitDate
+	if expected := parquetDate(ds.Leads[0].Attributes.FirstVisitDate); first == nil || expected == nil || *first != *expected {
+		t.Errorf("expected first_visit_date %s to be stored as a date", ds.Leads[0].Attributes.FirstVisitDate)
+	}
+}
+
+func TestWriteParquetUnknownCompression(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteParquet(&buf, Visits{}, ParquetOptions{Compression: "lzma"}); err == nil {
+		t.Error("expected an error for an unknown compression")
+	}
+}
@@ -0,0 +1,270 @@
+/*
+Copyright © 2021 willbenica
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package internal
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Ends of a visit in transitions, like in Google Analytics
+const (
+	Entrance = "(entrance)"
+	Exit     = "(exit)"
+)
+
+// Views of a PathReport
+const (
+	PathsEntries     = "entries"
+	PathsExits       = "exits"
+	PathsPages       = "pages"
+	PathsTransitions = "transitions"
+)
+
+// PathViews lists the views of a PathReport
+var PathViews = []string{PathsEntries, PathsExits, PathsPages, PathsTransitions}
+
+// PathFilter selects the visits and route steps of a PathReport, empty
+// fields select everything
+type PathFilter struct {
+	// Hostname keeps only the steps on this host
+	Hostname string
+	LeadID   string
+	Campaign string
+}
+
+// PageStats are the metrics of a page over all visits. Rates are percentages.
+type PageStats struct {
+	Page          string  `json:"page"`
+	Views         int     `json:"views"`
+	Entrances     int     `json:"entrances"`
+	Exits         int     `json:"exits"`
+	Bounces       int     `json:"bounces"`
+	BounceRate    float64 `json:"bounce_rate"`
+	ExitRate      float64 `json:"exit_rate"`
+	AvgTimeOnPage float64 `json:"avg_time_on_page"`
+	timeOnPage    int
+}
+
+// Transition counts how often visitors went from one page to the next.
+// Share is the percentage of all transitions leaving From.
+type Transition struct {
+	From  string  `json:"from"`
+	To    string  `json:"to"`
+	Count int     `json:"count"`
+	Share float64 `json:"share"`
+}
+
+// PathReport describes how visitors move through the site
+type PathReport struct {
+	Visits      int          `json:"visits"`
+	Pages       []PageStats  `json:"pages"`
+	Transitions []Transition `json:"transitions"`
+}
+
+// Match reports whether the visit is selected by the filter
+func (f PathFilter) Match(v VisitData) bool {
+	return (f.LeadID == "" || v.Attributes.LeadID == f.LeadID) &&
+		(f.Campaign == "" || v.Attributes.Campaign == f.Campaign)
+}
+
+// Route returns the steps of the visit on the filtered host
+func (f PathFilter) Route(v VisitData) []VisitRoute {
+	if f.Hostname == "" {
+		return v.Attributes.VisitRoute
+	}
+	var route []VisitRoute
+	for _, step := range v.Attributes.VisitRoute {
+		if strings.EqualFold(step.Hostname, f.Hostname) {
+			route = append(route, step)
+		}
+	}
+	return route
+}
+
+// Paths follows the route of each visit. Pages are identified by their path,
+// or by what group returns for the step if group is not nil, e.g. a Section.
+// The route steps are taken in the order the API returns them. Pages are
+// sorted by views, transitions by count.
+func Paths(visits []VisitData, f PathFilter, group func(step VisitRoute) string) PathReport {
+	var r PathReport
+	pages := map[string]*PageStats{}
+	page := func(path string) *PageStats {
+		p, ok := pages[path]
+		if !ok {
+			p = &PageStats{Page: path}
+			pages[path] = p
+		}
+		return p
+	}
+	transitions := map[[2]string]int{}
+	outgoing := map[string]int{}
+
+	for _, v := range visits {
+		if !f.Match(v) {
+			continue
+		}
+		route := f.Route(v)
+		if len(route) == 0 {
+			continue
+		}
+		r.Visits++
+		from := Entrance
+		for i, step := range route {
+			path := step.PagePath
+			if group != nil {
+				path = group(step)
+			}
+			p := page(path)
+			p.Views++
+			p.timeOnPage += step.TimeOnPage
+			if i == 0 {
+				p.Entrances++
+				if len(route) == 1 {
+					p.Bounces++
+				}
+			}
+			transitions[[2]string{from, path}]++
+			outgoing[from]++
+			from = path
+		}
+		page(from).Exits++
+		transitions[[2]string{from, Exit}]++
+		outgoing[from]++
+	}
+
+	for _, p := range pages {
+		p.AvgTimeOnPage = round2(float64(p.timeOnPage) / float64(p.Views))
+		p.ExitRate = percent(p.Exits, p.Views)
+		p.BounceRate = percent(p.Bounces, p.Entrances)
+		r.Pages = append(r.Pages, *p)
+	}
+	SortPages(r.Pages, PathsPages)
+	for key, count := range transitions {
+		r.Transitions = append(r.Transitions, Transition{From: key[0], To: key[1], Count: count, Share: percent(count, outgoing[key[0]])})
+	}
+	sort.Slice(r.Transitions, func(i, j int) bool {
+		a, b := r.Transitions[i], r.Transitions[j]
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		if a.From != b.From {
+			return a.From < b.From
+		}
+		return a.To < b.To
+	})
+	return r
+}
+
+// SortPages sorts pages for a view: by entrances, exits or views, the most first
+func SortPages(pages []PageStats, view string) {
+	metric := func(p PageStats) int {
+		switch view {
+		case PathsEntries:
+			return p.Entrances
+		case PathsExits:
+			return p.Exits
+		}
+		return p.Views
+	}
+	sort.Slice(pages, func(i, j int) bool {
+		if a, b := metric(pages[i]), metric(pages[j]); a != b {
+			return a > b
+		}
+		return pages[i].Page < pages[j].Page
+	})
+}
+
+// PathView returns the rows of a view as table and as the values for JSON.
+// entries and exits only contain pages visits started or ended on.
+func (r PathReport) PathView(view string, top int) (Table, interface{}, error) {
+	if view == PathsTransitions {
+		transitions := r.Transitions
+		if top > 0 && len(transitions) > top {
+			transitions = transitions[:top]
+		}
+		t := Table{Columns: []string{"from", "to", "count", "share"}}
+		for _, tr := range transitions {
+			t.Rows = append(t.Rows, []string{tr.From, tr.To, strconv.Itoa(tr.Count), formatPercent(tr.Share)})
+		}
+		return t, transitions, nil
+	}
+
+	var columns []string
+	pages := append([]PageStats{}, r.Pages...)
+	SortPages(pages, view)
+	switch view {
+	case PathsEntries:
+		columns = []string{"page", "entrances", "bounces", "bounce_rate", "avg_time_on_page"}
+		pages = pagesWith(pages, func(p PageStats) bool { return p.Entrances > 0 })
+	case PathsExits:
+		columns = []string{"page", "exits", "views", "exit_rate", "avg_time_on_page"}
+		pages = pagesWith(pages, func(p PageStats) bool { return p.Exits > 0 })
+	case PathsPages:
+		columns = []string{"page", "views", "entrances", "exits", "avg_time_on_page", "bounce_rate", "exit_rate"}
+	default:
+		return Table{}, nil, fmt.Errorf("unknown view %q, use %s", view, strings.Join(PathViews, ", "))
+	}
+	if top > 0 && len(pages) > top {
+		pages = pages[:top]
+	}
+
+	t := Table{Columns: columns}
+	for _, p := range pages {
+		values := map[string]string{
+			"page":             p.Page,
+			"views":            strconv.Itoa(p.Views),
+			"entrances":        strconv.Itoa(p.Entrances),
+			"exits":            strconv.Itoa(p.Exits),
+			"bounces":          strconv.Itoa(p.Bounces),
+			"bounce_rate":      formatPercent(p.BounceRate),
+			"exit_rate":        formatPercent(p.ExitRate),
+			"avg_time_on_page": formatFloat(p.AvgTimeOnPage),
+		}
+		row := make([]string, len(columns))
+		for i, c := range columns {
+			row[i] = values[c]
+		}
+		t.Rows = append(t.Rows, row)
+	}
+	return t, pages, nil
+}
+
+func pagesWith(pages []PageStats, keep func(PageStats) bool) []PageStats {
+	var out []PageStats
+	for _, p := range pages {
+		if keep(p) {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// percent returns n of total in percent, rounded to two decimals
+func percent(n int, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return round2(float64(n) * 100 / float64(total))
+}
+
+func formatPercent(f float64) string {
+	return formatFloat(f) + "%"
+}
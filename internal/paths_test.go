@@ -0,0 +1,86 @@
+/*
+Copyright © 2021 willbenica
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package internal
+
+import (
+	"reflect"
+	"testing"
+)
+
+func pathsTestVisits() []VisitData {
+	step := func(host, path string, seconds int) VisitRoute {
+		return VisitRoute{Hostname: host, PagePath: path, TimeOnPage: seconds}
+	}
+	return []VisitData{
+		{Attributes: VisitAttributes{LeadID: "a", Campaign: "spring", VisitRoute: []VisitRoute{
+			step("www.example.com", "/", 10), step("www.example.com", "/pricing", 30), step("docs.example.com", "/api", 50)}}},
+		{Attributes: VisitAttributes{LeadID: "b", VisitRoute: []VisitRoute{step("www.example.com", "/", 4)}}},
+		{Attributes: VisitAttributes{LeadID: "a", VisitRoute: []VisitRoute{step("www.example.com", "/pricing", 20), step("www.example.com", "/", 0)}}},
+	}
+}
+
+func TestPathsPages(t *testing.T) {
+	r := Paths(pathsTestVisits(), PathFilter{}, nil)
+	if r.Visits != 3 {
+		t.Errorf("expected 3 visits, got %d", r.Visits)
+	}
+	type counts struct{ views, entrances, exits, bounces int }
+	expected := map[string]counts{"/": {3, 2, 2, 1}, "/pricing": {2, 1, 0, 0}, "/api": {1, 0, 1, 0}}
+	for _, p := range r.Pages {
+		if got := (counts{p.Views, p.Entrances, p.Exits, p.Bounces}); got != expected[p.Page] {
+			t.Errorf("%s: expected %+v, got %+v", p.Page, expected[p.Page], got)
+		}
+	}
+	home := r.Pages[0]
+	if home.Page != "/" || home.BounceRate != 50 || home.ExitRate != 66.67 || home.AvgTimeOnPage != 4.67 {
+		t.Errorf("unexpected stats of /: %+v", home)
+	}
+}
+
+func TestPathsTransitions(t *testing.T) {
+	r := Paths(pathsTestVisits(), PathFilter{Hostname: "www.example.com", LeadID: "a"}, nil)
+	expected := []Transition{
+		{From: Entrance, To: "/", Count: 1, Share: 50},
+		{From: Entrance, To: "/pricing", Count: 1, Share: 50},
+		{From: "/", To: Exit, Count: 1, Share: 50},
+		{From: "/", To: "/pricing", Count: 1, Share: 50},
+		{From: "/pricing", To: Exit, Count: 1, Share: 50},
+		{From: "/pricing", To: "/", Count: 1, Share: 50},
+	}
+	if !reflect.DeepEqual(r.Transitions, expected) {
+		t.Errorf("expected\n%+v\ngot\n%+v", expected, r.Transitions)
+	}
+}
+
+func TestPathView(t *testing.T) {
+	r := Paths(pathsTestVisits(), PathFilter{Campaign: "spring"}, nil)
+	table, _, err := r.PathView(PathsExits, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := Table{
+		Columns: []string{"page", "exits", "views", "exit_rate", "avg_time_on_page"},
+		Rows:    [][]string{{"/api", "1", "1", "100%", "50"}},
+	}
+	if !reflect.DeepEqual(table, expected) {
+		t.Errorf("expected %v, got %v", expected, table)
+	}
+	if _, _, err := r.PathView("sources", 0); err == nil {
+		t.Error("expected an error for an unknown view")
+	}
+}
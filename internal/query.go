@@ -0,0 +1,131 @@
+/*
+Copyright © 2021 willbenica
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/itchyny/gojq"
+)
+
+// Query is a compiled jq expression
+type Query struct {
+	source string
+	code   *gojq.Code
+}
+
+// QueryOptions control how the results of a query are written
+type QueryOptions struct {
+	// Raw writes strings without quotes, like jq -r
+	Raw bool
+	// Compact writes each result on one line, like jq -c
+	Compact bool
+}
+
+// haltError is implemented by the error of halt and halt_error
+type haltError interface {
+	IsHaltError() bool
+	IsEmptyError() bool
+}
+
+// NewQuery parses and compiles a jq expression
+func NewQuery(expr string) (*Query, error) {
+	parsed, err := gojq.Parse(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid query %q: %w", expr, err)
+	}
+	code, err := gojq.Compile(parsed)
+	if err != nil {
+		return nil, fmt.Errorf("invalid query %q: %w", expr, err)
+	}
+	return &Query{source: expr, code: code}, nil
+}
+
+// Run applies the query to input, a value as decoded by encoding/json, and
+// writes every result to w
+func (q *Query) Run(w io.Writer, input interface{}, opts QueryOptions) error {
+	iter := q.code.Run(input)
+	for {
+		v, ok := iter.Next()
+		if !ok {
+			return nil
+		}
+		if err, ok := v.(error); ok {
+			if h, ok := err.(haltError); ok && h.IsHaltError() && h.IsEmptyError() {
+				return nil
+			}
+			return fmt.Errorf("query %s: %w", q.source, err)
+		}
+		if err := writeQueryResult(w, v, opts); err != nil {
+			return err
+		}
+	}
+}
+
+// RunValue applies the query to the JSON encoding of v, e.g. a LeadsResponse
+func (q *Query) RunValue(w io.Writer, v interface{}, opts QueryOptions) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	var input interface{}
+	if err := json.Unmarshal(b, &input); err != nil {
+		return err
+	}
+	return q.Run(w, input, opts)
+}
+
+func writeQueryResult(w io.Writer, v interface{}, opts QueryOptions) error {
+	if s, ok := v.(string); ok && opts.Raw {
+		_, err := fmt.Fprintln(w, s)
+		return err
+	}
+	var buf bytes.Buffer
+	e := json.NewEncoder(&buf)
+	e.SetEscapeHTML(false)
+	if !opts.Compact {
+		e.SetIndent("", "  ")
+	}
+	if err := e.Encode(v); err != nil {
+		return err
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// ReadJSONValues decodes the JSON values in r one after another, e.g. the
+// lines of a file written by get, and hands each to fn
+func ReadJSONValues(r io.Reader, fn func(interface{}) error) error {
+	d := json.NewDecoder(r)
+	for {
+		var v interface{}
+		err := d.Decode(&v)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := fn(v); err != nil {
+			return err
+		}
+	}
+}
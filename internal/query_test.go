@@ -0,0 +1,88 @@
+/*
+Copyright © 2021 willbenica
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package internal
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestQueryRunValue(t *testing.T) {
+	response := LeadsResponse{Data: []LeadData{
+		{ID: "a", Attributes: LeadAttributes{Name: "Acme", Quality: 4}},
+		{ID: "b", Attributes: LeadAttributes{Name: "Bolt", Quality: 1}},
+	}}
+	cases := []struct {
+		expr     string
+		opts     QueryOptions
+		expected string
+	}{
+		{`.data[] | select(.attributes.quality >= 3) | .attributes.name`, QueryOptions{}, "\"Acme\"\n"},
+		{`.data[].attributes.name`, QueryOptions{Raw: true}, "Acme\nBolt\n"},
+		{`[.data[].id]`, QueryOptions{Compact: true}, "[\"a\",\"b\"]\n"},
+		{`{n: (.data | length)}`, QueryOptions{}, "{\n  \"n\": 2\n}\n"},
+		{`"<&>"`, QueryOptions{}, "\"<&>\"\n"},
+		{`.data[0].id, halt`, QueryOptions{Raw: true}, "a\n"},
+	}
+	for _, c := range cases {
+		t.Run(c.expr, func(t *testing.T) {
+			q, err := NewQuery(c.expr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var buf bytes.Buffer
+			if err := q.RunValue(&buf, response, c.opts); err != nil {
+				t.Fatal(err)
+			}
+			if buf.String() != c.expected {
+				t.Errorf("expected %q, got %q", c.expected, buf.String())
+			}
+		})
+	}
+}
+
+func TestQueryErrors(t *testing.T) {
+	if _, err := NewQuery(".data["); err == nil {
+		t.Error("expected a parse error")
+	}
+	if _, err := NewQuery("nosuchfunction"); err == nil {
+		t.Error("expected a compile error")
+	}
+	q, err := NewQuery(".name + 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Run(&bytes.Buffer{}, map[string]interface{}{"name": "Acme"}, QueryOptions{}); err == nil {
+		t.Error("expected an error adding a string and a number")
+	}
+}
+
+func TestReadJSONValues(t *testing.T) {
+	var ids []interface{}
+	err := ReadJSONValues(strings.NewReader("{\"id\":\"a\"}\n{\"id\":\"b\"}\n"), func(v interface{}) error {
+		ids = append(ids, v.(map[string]interface{})["id"])
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 2 || ids[0] != "a" || ids[1] != "b" {
+		t.Errorf("expected [a b], got %v", ids)
+	}
+}
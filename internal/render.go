@@ -0,0 +1,220 @@
+/*
+Copyright © 2021 willbenica
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Ways to print data to the terminal
+const (
+	OutputTable  = "table"
+	OutputWide   = "wide"
+	OutputJSON   = "json"
+	OutputNDJSON = "ndjson"
+	OutputYAML   = "yaml"
+)
+
+// Columns shown by OutputTable and OutputWide. location and source_medium
+// are added by LeadsView and VisitsView, the others come from LeadsTable and
+// VisitsTable.
+var (
+	LeadTableColumns  = []string{"name", "status", "quality", "visits", "last_visit_date", "location"}
+	LeadWideColumns   = []string{"id", "name", "status", "industry", "quality", "visits", "employee_count", "first_visit_date", "last_visit_date", "location", "website_url"}
+	VisitTableColumns = []string{"started_at", "lead_id", "source_medium", "page_depth", "visit_length"}
+	VisitWideColumns  = []string{"id", "started_at", "lead_id", "source_medium", "campaign", "page_depth", "visit_length", "entry_page", "exit_page", "referring_url"}
+)
+
+// minColumnWidth is the width below which columns are not shrunk to fit the terminal
+const minColumnWidth = 6
+
+// columnGap separates the columns of an aligned table
+const columnGap = "  "
+
+// IsValidOutput returns `true` if output is a supported terminal output
+func IsValidOutput(output string) bool {
+	switch output {
+	case OutputTable, OutputWide, OutputJSON, OutputNDJSON, OutputYAML:
+		return true
+	}
+	return false
+}
+
+// LeadsView is LeadsTable with a location column combining city and country
+func LeadsView(leads []LeadData, locations []Location) Table {
+	t := LeadsTable(leads, locations)
+	city, country := t.Index("city"), t.Index("country")
+	return t.AddColumn("location", func(row []string) string {
+		return joinNonEmpty(", ", row[city], row[country])
+	})
+}
+
+// VisitsView is VisitsTable with a source_medium column as in Google Analytics
+func VisitsView(visits []VisitData) Table {
+	t := VisitsTable(visits, VisitRowsVisit)
+	source, medium := t.Index("source"), t.Index("medium")
+	return t.AddColumn("source_medium", func(row []string) string {
+		return joinNonEmpty(" / ", row[source], row[medium])
+	})
+}
+
+// Index returns the position of column, -1 if the table has no such column
+func (t Table) Index(column string) int {
+	for i, c := range t.Columns {
+		if c == column {
+			return i
+		}
+	}
+	return -1
+}
+
+// AddColumn returns a copy of the table with a column computed from each row
+func (t Table) AddColumn(name string, value func(row []string) string) Table {
+	out := Table{Columns: append(append([]string{}, t.Columns...), name)}
+	for _, row := range t.Rows {
+		out.Rows = append(out.Rows, append(append([]string{}, row...), value(row)))
+	}
+	return out
+}
+
+// Select returns a table with only the given columns in the given order
+func (t Table) Select(columns ...string) (Table, error) {
+	index := make([]int, len(columns))
+	for i, c := range columns {
+		if index[i] = t.Index(c); index[i] < 0 {
+			return Table{}, fmt.Errorf("unknown column %q, available columns: %s", c, strings.Join(t.Columns, ", "))
+		}
+	}
+	out := Table{Columns: columns}
+	for _, row := range t.Rows {
+		selected := make([]string, len(index))
+		for i, j := range index {
+			selected[i] = row[j]
+		}
+		out.Rows = append(out.Rows, selected)
+	}
+	return out, nil
+}
+
+// WriteAligned writes the table with aligned columns and an upper case
+// header. If width is positive, the widest columns are shortened so that
+// lines fit, cut off values end with "…".
+func (t Table) WriteAligned(w io.Writer, width int) error {
+	widths := make([]int, len(t.Columns))
+	for i, c := range t.Columns {
+		widths[i] = utf8.RuneCountInString(c)
+	}
+	for _, row := range t.Rows {
+		for i, cell := range row {
+			if n := utf8.RuneCountInString(cell); n > widths[i] {
+				widths[i] = n
+			}
+		}
+	}
+	if width > 0 {
+		fitColumns(widths, width)
+	}
+
+	writeRow := func(cells []string) error {
+		line := make([]string, len(cells))
+		for i, cell := range cells {
+			cell = truncate(cell, widths[i])
+			if i < len(cells)-1 {
+				cell += strings.Repeat(" ", widths[i]-utf8.RuneCountInString(cell))
+			}
+			line[i] = cell
+		}
+		_, err := fmt.Fprintln(w, strings.TrimRight(strings.Join(line, columnGap), " "))
+		return err
+	}
+	header := make([]string, len(t.Columns))
+	for i, c := range t.Columns {
+		header[i] = strings.ToUpper(c)
+	}
+	if err := writeRow(header); err != nil {
+		return err
+	}
+	for _, row := range t.Rows {
+		if err := writeRow(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fitColumns shrinks the widest column until the line fits into width or
+// every column reached minColumnWidth
+func fitColumns(widths []int, width int) {
+	total := func() int {
+		sum := len(columnGap) * (len(widths) - 1)
+		for _, w := range widths {
+			sum += w
+		}
+		return sum
+	}
+	for total() > width {
+		widest := 0
+		for i, w := range widths {
+			if w > widths[widest] {
+				widest = i
+			}
+		}
+		if widths[widest] <= minColumnWidth {
+			return
+		}
+		widths[widest]--
+	}
+}
+
+// truncate shortens s to width runes, marking the cut with "…"
+func truncate(s string, width int) string {
+	if utf8.RuneCountInString(s) <= width {
+		return s
+	}
+	runes := []rune(s)
+	return string(runes[:width-1]) + "…"
+}
+
+func joinNonEmpty(sep string, values ...string) string {
+	var parts []string
+	for _, v := range values {
+		if v != "" {
+			parts = append(parts, v)
+		}
+	}
+	return strings.Join(parts, sep)
+}
+
+// JSONToYAML converts a JSON document to YAML
+func JSONToYAML(data string) (string, error) {
+	var v interface{}
+	if err := json.Unmarshal([]byte(data), &v); err != nil {
+		return "", err
+	}
+	out, err := yaml.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
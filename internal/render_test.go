@@ -0,0 +1,70 @@
+/*
+Copyright © 2021 willbenica
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package internal
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestWriteAligned(t *testing.T) {
+	table := Table{
+		Columns: []string{"name", "quality"},
+		Rows:    [][]string{{"A very long company name", "3"}, {"Short", "10"}},
+	}
+
+	cases := []struct {
+		width    int
+		expected string
+	}{
+		{0, "NAME                      QUALITY\nA very long company name  3\nShort                     10\n"},
+		{20, "NAME         QUALITY\nA very lon…  3\nShort        10\n"},
+	}
+	for _, c := range cases {
+		var buf bytes.Buffer
+		if err := table.WriteAligned(&buf, c.width); err != nil {
+			t.Fatal(err)
+		}
+		if buf.String() != c.expected {
+			t.Errorf("width %d: expected\n%s\ngot\n%s", c.width, c.expected, buf.String())
+		}
+	}
+}
+
+func TestLeadsViewSelect(t *testing.T) {
+	ds, err := ReadDataFiles(TEST_FOLDER+L1, TEST_FOLDER+V1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	view, err := LeadsView(ds.Leads, ds.Locations).Select(LeadTableColumns...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(view.Rows) != len(ds.Leads) {
+		t.Fatalf("expected %d rows, got %d", len(ds.Leads), len(view.Rows))
+	}
+	loc := LocationIndex(ds.Locations)[ds.Leads[0].Relationships.Location.Data.ID].Attributes
+	if location := view.Rows[0][view.Index("location")]; location != joinNonEmpty(", ", loc.City, loc.Country) {
+		t.Errorf("unexpected location %q", location)
+	}
+
+	if _, err := VisitsView(ds.Visits).Select("started_at", "nope"); err == nil || !strings.Contains(err.Error(), "nope") {
+		t.Errorf("expected an error for an unknown column, got %v", err)
+	}
+}
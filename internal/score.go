@@ -0,0 +1,322 @@
+/*
+Copyright © 2021 willbenica
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// DefaultScoreWindowDays is the window of visit based rules if the rules file sets none
+const DefaultScoreWindowDays = 30
+
+// Range is an inclusive range, a missing bound is open
+type Range struct {
+	Min *int `yaml:"min"`
+	Max *int `yaml:"max"`
+}
+
+// Contains reports whether n is within the range
+func (r Range) Contains(n int) bool {
+	return (r.Min == nil || n >= *r.Min) && (r.Max == nil || n <= *r.Max)
+}
+
+func (r Range) isSet() bool {
+	return r.Min != nil || r.Max != nil
+}
+
+// PageViewRule counts the views of pages matching a glob on the page path or
+// of a content group, see ContentGroups
+type PageViewRule struct {
+	Path  string `yaml:"path"`
+	Group string `yaml:"group"`
+	Range `yaml:",inline"`
+	re    *regexp.Regexp
+}
+
+// ScoreRule gives a lead Points if its condition holds. Each rule has exactly
+// one condition.
+type ScoreRule struct {
+	Name   string `yaml:"name"`
+	Points int    `yaml:"points"`
+	// Industry matches one of the industries, ignoring case
+	Industry []string `yaml:"industry"`
+	// Employees is the band of the employee count
+	Employees *Range `yaml:"employees"`
+	// Country matches one of the countries by name or code, ignoring case
+	Country []string `yaml:"country"`
+	// Visits is the number of visits in the window
+	Visits *Range `yaml:"visits"`
+	// PageViews is the number of views of some pages in the window
+	PageViews *PageViewRule `yaml:"page_views"`
+	// LastVisitDays is the number of days since the last visit
+	LastVisitDays *Range `yaml:"last_visit_days"`
+}
+
+// ScoreRules is a lead scoring rules file
+type ScoreRules struct {
+	// WindowDays is how many days before the reference date visits count
+	WindowDays int         `yaml:"window_days"`
+	Rules      []ScoreRule `yaml:"rules"`
+}
+
+// RuleResult is the outcome of a rule for a lead
+type RuleResult struct {
+	Rule    string `json:"rule"`
+	Matched bool   `json:"matched"`
+	Points  int    `json:"points"`
+	// Detail is the value the rule looked at, e.g. "4 visits"
+	Detail string `json:"detail"`
+}
+
+// LeadScore is the score of a lead and how it came about
+type LeadScore struct {
+	Rank      int          `json:"rank"`
+	ID        string       `json:"id"`
+	Name      string       `json:"name"`
+	Score     int          `json:"score"`
+	Breakdown []RuleResult `json:"breakdown"`
+}
+
+// LoadScoreRules reads a lead scoring rules file
+func LoadScoreRules(path string) (*ScoreRules, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	r, err := ParseScoreRules(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return r, nil
+}
+
+// ParseScoreRules parses and checks the rules of a lead scoring rules file
+func ParseScoreRules(data []byte) (*ScoreRules, error) {
+	var r ScoreRules
+	if err := yaml.UnmarshalStrict(data, &r); err != nil {
+		return nil, err
+	}
+	if len(r.Rules) == 0 {
+		return nil, errors.New("no rules defined")
+	}
+	if r.WindowDays < 0 {
+		return nil, errors.New("window_days must not be negative")
+	}
+	if r.WindowDays == 0 {
+		r.WindowDays = DefaultScoreWindowDays
+	}
+	names := map[string]bool{}
+	for i := range r.Rules {
+		rule := &r.Rules[i]
+		if rule.Name == "" {
+			return nil, fmt.Errorf("rule %d has no name", i+1)
+		}
+		if names[rule.Name] {
+			return nil, fmt.Errorf("the rule name %q is used twice", rule.Name)
+		}
+		names[rule.Name] = true
+		if n := rule.conditions(); n != 1 {
+			return nil, fmt.Errorf("rule %q needs exactly one of industry, employees, country, visits, page_views or last_visit_days, it has %d", rule.Name, n)
+		}
+		if pv := rule.PageViews; pv != nil {
+			if (pv.Path == "") == (pv.Group == "") {
+				return nil, fmt.Errorf("rule %q: page_views needs either a path or a group", rule.Name)
+			}
+			if pv.Path != "" {
+				pv.re = regexp.MustCompile(globToRegex(pv.Path))
+			}
+			if !pv.isSet() {
+				one := 1
+				pv.Min = &one
+			}
+		}
+	}
+	return &r, nil
+}
+
+func (rule ScoreRule) conditions() int {
+	n := 0
+	for _, set := range []bool{len(rule.Industry) > 0, rule.Employees != nil, len(rule.Country) > 0,
+		rule.Visits != nil, rule.PageViews != nil, rule.LastVisitDays != nil} {
+		if set {
+			n++
+		}
+	}
+	return n
+}
+
+// NeedsContentGroups reports whether a rule counts the views of a content group
+func (r *ScoreRules) NeedsContentGroups() bool {
+	for _, rule := range r.Rules {
+		if rule.PageViews != nil && rule.PageViews.Group != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// ScoreLeads scores the leads and ranks them, the highest score first. Visits
+// count if they started in the window of days before asOf, recency is
+// measured until asOf.
+func (r *ScoreRules) ScoreLeads(leads []LeadData, locations []Location, visits []VisitData, asOf time.Time) []LeadScore {
+	index := LocationIndex(locations)
+	end := time.Date(asOf.Year(), asOf.Month(), asOf.Day(), 0, 0, 0, 0, time.UTC).AddDate(0, 0, 1)
+	start := end.AddDate(0, 0, -r.WindowDays)
+	byLead := map[string][]VisitData{}
+	lastVisit := map[string]time.Time{}
+	for _, v := range visits {
+		id := v.Attributes.LeadID
+		if t := v.Attributes.StartedAt; t.Before(end) && t.After(lastVisit[id]) {
+			lastVisit[id] = t
+		}
+		if t := v.Attributes.StartedAt; !t.Before(start) && t.Before(end) {
+			byLead[id] = append(byLead[id], v)
+		}
+	}
+
+	scores := make([]LeadScore, 0, len(leads))
+	for _, l := range leads {
+		s := LeadScore{ID: l.ID, Name: l.Attributes.Name}
+		location := index[l.Relationships.Location.Data.ID]
+		for _, rule := range r.Rules {
+			matched, detail := rule.evaluate(l, location, byLead[l.ID], lastVisit[l.ID], end)
+			result := RuleResult{Rule: rule.Name, Matched: matched, Detail: detail}
+			if matched {
+				result.Points = rule.Points
+				s.Score += rule.Points
+			}
+			s.Breakdown = append(s.Breakdown, result)
+		}
+		scores = append(scores, s)
+	}
+	sort.SliceStable(scores, func(i, j int) bool {
+		if scores[i].Score != scores[j].Score {
+			return scores[i].Score > scores[j].Score
+		}
+		return scores[i].Name < scores[j].Name
+	})
+	for i := range scores {
+		scores[i].Rank = i + 1
+	}
+	return scores
+}
+
+// LastVisitDate returns the day of the newest visit, or of the newest
+// last_visit_date of the leads if there are no visits
+func LastVisitDate(leads []LeadData, visits []VisitData) (time.Time, bool) {
+	var last time.Time
+	for _, v := range visits {
+		if v.Attributes.StartedAt.After(last) {
+			last = v.Attributes.StartedAt
+		}
+	}
+	if last.IsZero() {
+		for _, l := range leads {
+			if t, err := time.Parse("2006-01-02", l.Attributes.LastVisitDate); err == nil && t.After(last) {
+				last = t
+			}
+		}
+	}
+	if last.IsZero() {
+		return last, false
+	}
+	return time.Date(last.Year(), last.Month(), last.Day(), 0, 0, 0, 0, time.UTC), true
+}
+
+// evaluate checks the condition of the rule, end is the day after the reference date
+func (rule ScoreRule) evaluate(l LeadData, location Location, visits []VisitData, lastVisit time.Time, end time.Time) (bool, string) {
+	a := l.Attributes
+	switch {
+	case len(rule.Industry) > 0:
+		return containsFold(rule.Industry, a.Industry), a.Industry
+	case rule.Employees != nil:
+		return rule.Employees.Contains(a.EmployeeCount), strconv.Itoa(a.EmployeeCount) + " employees"
+	case len(rule.Country) > 0:
+		c := location.Attributes
+		return containsFold(rule.Country, c.Country) || containsFold(rule.Country, c.CountryCode), c.Country
+	case rule.Visits != nil:
+		return rule.Visits.Contains(len(visits)), strconv.Itoa(len(visits)) + " visits"
+	case rule.PageViews != nil:
+		n := rule.PageViews.count(visits)
+		return rule.PageViews.Contains(n), strconv.Itoa(n) + " views"
+	case rule.LastVisitDays != nil:
+		last := lastVisit
+		if d, err := time.Parse("2006-01-02", a.LastVisitDate); err == nil && d.After(last) {
+			last = d
+		}
+		if last.IsZero() {
+			return false, "no visit"
+		}
+		last = time.Date(last.Year(), last.Month(), last.Day(), 0, 0, 0, 0, time.UTC)
+		days := int(end.AddDate(0, 0, -1).Sub(last).Hours() / 24)
+		if days < 0 {
+			days = 0
+		}
+		return rule.LastVisitDays.Contains(days), strconv.Itoa(days) + " days ago"
+	}
+	return false, ""
+}
+
+func (pv PageViewRule) count(visits []VisitData) int {
+	n := 0
+	for _, v := range visits {
+		for _, step := range v.Attributes.VisitRoute {
+			if (pv.re != nil && pv.re.MatchString(step.PagePath)) || (pv.Group != "" && step.PageGroup == pv.Group) {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+// ScoreTable flattens scores with one column per rule holding the points it gave
+func ScoreTable(scores []LeadScore, rules *ScoreRules) Table {
+	t := Table{Columns: []string{"rank", "id", "name", "score"}}
+	for _, rule := range rules.Rules {
+		t.Columns = append(t.Columns, rule.Name)
+	}
+	for _, s := range scores {
+		row := []string{strconv.Itoa(s.Rank), s.ID, s.Name, strconv.Itoa(s.Score)}
+		for _, b := range s.Breakdown {
+			row = append(row, strconv.Itoa(b.Points))
+		}
+		t.Rows = append(t.Rows, row)
+	}
+	return t
+}
+
+func containsFold(values []string, value string) bool {
+	if value == "" {
+		return false
+	}
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,155 @@
+/*
+Copyright © 2021 willbenica
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package internal
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+const testScoreRules = `
+window_days: 7
+rules:
+  - name: Software
+    industry: [software, IT]
+    points: 20
+  - name: Mid-size
+    employees: {min: 50, max: 500}
+    points: 15
+  - name: DACH
+    country: [DE, AT, CH]
+    points: 10
+  - name: Engaged
+    visits: {min: 2}
+    points: 10
+  - name: Pricing
+    page_views: {path: "/pricing*"}
+    points: 25
+  - name: Recent
+    last_visit_days: {max: 3}
+    points: 5
+`
+
+func TestScoreLeads(t *testing.T) {
+	rules, err := ParseScoreRules([]byte(testScoreRules))
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := func(day int) time.Time { return time.Date(2021, 5, day, 10, 0, 0, 0, time.UTC) }
+	leads := []LeadData{
+		{ID: "a", Attributes: LeadAttributes{Name: "Acme", Industry: "Software", EmployeeCount: 120},
+			Relationships: Relationships{Location: RelatedLocaction{Data: LocData{ID: "de"}}}},
+		{ID: "b", Attributes: LeadAttributes{Name: "Bolt", Industry: "Retail", EmployeeCount: 5000, LastVisitDate: "2021-05-30"}},
+	}
+	locations := []Location{{ID: "de", Attributes: LocationAttributes{Country: "Germany", CountryCode: "DE"}}}
+	visits := []VisitData{
+		{Attributes: VisitAttributes{LeadID: "a", StartedAt: at(28), VisitRoute: []VisitRoute{{PagePath: "/pricing"}, {PagePath: "/pricing/enterprise"}}}},
+		{Attributes: VisitAttributes{LeadID: "a", StartedAt: at(20), VisitRoute: []VisitRoute{{PagePath: "/pricing"}}}},
+		{Attributes: VisitAttributes{LeadID: "b", StartedAt: at(27)}},
+	}
+
+	scores := rules.ScoreLeads(leads, locations, visits, time.Date(2021, 5, 31, 0, 0, 0, 0, time.UTC))
+	if len(scores) != 2 || scores[0].ID != "a" || scores[1].ID != "b" {
+		t.Fatalf("expected a ranked before b, got %+v", scores)
+	}
+	expected := []RuleResult{
+		{Rule: "Software", Matched: true, Points: 20, Detail: "Software"},
+		{Rule: "Mid-size", Matched: true, Points: 15, Detail: "120 employees"},
+		{Rule: "DACH", Matched: true, Points: 10, Detail: "Germany"},
+		{Rule: "Engaged", Matched: false, Points: 0, Detail: "1 visits"},
+		{Rule: "Pricing", Matched: true, Points: 25, Detail: "1 views"},
+		{Rule: "Recent", Matched: true, Points: 5, Detail: "3 days ago"},
+	}
+	if !reflect.DeepEqual(scores[0].Breakdown, expected) {
+		t.Errorf("expected\n%+v\ngot\n%+v", expected, scores[0].Breakdown)
+	}
+	if scores[0].Score != 75 || scores[0].Rank != 1 {
+		t.Errorf("expected rank 1 with 75 points, got %+v", scores[0])
+	}
+	// The last visit date of the lead is more recent than its visits
+	if recent := scores[1].Breakdown[5]; !recent.Matched || recent.Detail != "1 days ago" {
+		t.Errorf("expected the last visit date to count, got %+v", recent)
+	}
+
+	table := ScoreTable(scores, rules)
+	if expected := []string{"rank", "id", "name", "score", "Software", "Mid-size", "DACH", "Engaged", "Pricing", "Recent"}; !reflect.DeepEqual(table.Columns, expected) {
+		t.Errorf("expected columns %v, got %v", expected, table.Columns)
+	}
+	if expected := []string{"1", "a", "Acme", "75", "20", "15", "10", "0", "25", "5"}; !reflect.DeepEqual(table.Rows[0], expected) {
+		t.Errorf("expected row %v, got %v", expected, table.Rows[0])
+	}
+}
+
+func TestParseScoreRulesErrors(t *testing.T) {
+	cases := []struct {
+		name string
+		yaml string
+	}{
+		{"no rules", "rules: []"},
+		{"unknown condition", "rules:\n  - name: A\n    revenue: [x]\n    points: 1"},
+		{"no condition", "rules:\n  - name: A\n    points: 1"},
+		{"two conditions", "rules:\n  - name: A\n    industry: [IT]\n    country: [DE]\n    points: 1"},
+		{"missing name", "rules:\n  - industry: [IT]\n    points: 1"},
+		{"duplicate name", "rules:\n  - name: A\n    industry: [IT]\n  - name: A\n    country: [DE]"},
+		{"page views without pages", "rules:\n  - name: A\n    page_views: {min: 1}"},
+		{"negative window", "window_days: -1\nrules:\n  - name: A\n    industry: [IT]"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if _, err := ParseScoreRules([]byte(c.yaml)); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestLastVisitDate(t *testing.T) {
+	leads := []LeadData{
+		{ID: "a", Attributes: LeadAttributes{LastVisitDate: "2021-05-30"}},
+		{ID: "b", Attributes: LeadAttributes{LastVisitDate: "2021-05-12"}},
+	}
+	visits := []VisitData{
+		{Attributes: VisitAttributes{StartedAt: time.Date(2021, 5, 20, 23, 0, 0, 0, time.UTC)}},
+		{Attributes: VisitAttributes{StartedAt: time.Date(2021, 5, 3, 10, 0, 0, 0, time.UTC)}},
+	}
+	cases := []struct {
+		name     string
+		leads    []LeadData
+		visits   []VisitData
+		expected string
+	}{
+		{"newest visit", leads, visits, "2021-05-20"},
+		{"leads without visits", leads, nil, "2021-05-30"},
+		{"no data", nil, nil, ""},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, ok := LastVisitDate(c.leads, c.visits)
+			if !ok {
+				if c.expected != "" {
+					t.Errorf("expected %s, got none", c.expected)
+				}
+				return
+			}
+			if got.Format("2006-01-02") != c.expected {
+				t.Errorf("expected %s, got %s", c.expected, got.Format("2006-01-02"))
+			}
+		})
+	}
+}
@@ -0,0 +1,291 @@
+/*
+Copyright © 2021 willbenica
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package internal
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	// Registers the sqlite3 driver
+	_ "github.com/mattn/go-sqlite3"
+	"go.uber.org/zap"
+)
+
+// FormatSQLite writes all records to a SQLite database
+const FormatSQLite = "sqlite"
+
+// sqliteSchema creates the tables if they don't exist yet. The foreign keys
+// document the relations, they are not enforced because visits are often
+// exported without the matching leads.
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS locations (
+	id           TEXT PRIMARY KEY,
+	country      TEXT,
+	country_code TEXT,
+	region       TEXT,
+	region_code  TEXT,
+	city         TEXT,
+	state_code   TEXT
+);
+CREATE TABLE IF NOT EXISTS leads (
+	id                  TEXT PRIMARY KEY,
+	name                TEXT,
+	status              TEXT,
+	industry            TEXT,
+	quality             INTEGER,
+	visits              INTEGER,
+	employee_count      INTEGER,
+	first_visit_date    TEXT,
+	last_visit_date     TEXT,
+	website_url         TEXT,
+	linkedin_url        TEXT,
+	facebook_url        TEXT,
+	twitter_handle      TEXT,
+	business_id         TEXT,
+	revenue             TEXT,
+	assignee            TEXT,
+	emailed_to          TEXT,
+	phone               TEXT,
+	crm_lead_id         TEXT,
+	crm_organization_id TEXT,
+	logo_url            TEXT,
+	view_in_leadfeeder  TEXT,
+	location_id         TEXT REFERENCES locations(id)
+);
+CREATE TABLE IF NOT EXISTS lead_tags (
+	lead_id TEXT NOT NULL REFERENCES leads(id) ON DELETE CASCADE,
+	tag     TEXT NOT NULL,
+	PRIMARY KEY (lead_id, tag)
+);
+CREATE TABLE IF NOT EXISTS visits (
+	id            TEXT PRIMARY KEY,
+	lead_id       TEXT REFERENCES leads(id),
+	started_at    TEXT,
+	date          TEXT,
+	hour          INTEGER,
+	source        TEXT,
+	medium        TEXT,
+	campaign      TEXT,
+	keyword       TEXT,
+	query_term    TEXT,
+	referring_url TEXT,
+	page_depth    INTEGER,
+	visit_length  INTEGER,
+	lf_client_id  TEXT
+);
+CREATE INDEX IF NOT EXISTS visits_lead_id ON visits(lead_id);
+CREATE TABLE IF NOT EXISTS visit_routes (
+	visit_id           TEXT NOT NULL REFERENCES visits(id) ON DELETE CASCADE,
+	step               INTEGER NOT NULL,
+	hostname           TEXT,
+	page_path          TEXT,
+	previous_page_path TEXT,
+	time_on_page       INTEGER,
+	page_title         TEXT,
+	page_url           TEXT,
+	display_page_name  TEXT,
+	page_group         TEXT,
+	PRIMARY KEY (visit_id, step)
+);
+CREATE TABLE IF NOT EXISTS visit_ga_client_ids (
+	visit_id     TEXT NOT NULL REFERENCES visits(id) ON DELETE CASCADE,
+	ga_client_id TEXT NOT NULL,
+	PRIMARY KEY (visit_id, ga_client_id)
+);
+CREATE TABLE IF NOT EXISTS visit_page_groups (
+	visit_id   TEXT NOT NULL REFERENCES visits(id) ON DELETE CASCADE,
+	page_group TEXT NOT NULL,
+	PRIMARY KEY (visit_id, page_group)
+);
+`
+
+// sqliteColumns are columns added to tables after their first release, they
+// are added to databases created before
+var sqliteColumns = []struct{ table, column, definition string }{
+	{"visit_routes", "page_group", "TEXT"},
+}
+
+// WriteSQLite upserts the dataset into the SQLite database at path, creating
+// the database and its tables if needed. Records are matched by ID, so
+// repeated exports into the same database are merged. The lists of a record
+// (tags, route, GA client IDs, page groups) are replaced as a whole.
+func WriteSQLite(path string, ds Dataset) error {
+	Init()
+	logger.Debug("Opening SQLite database", zap.String("file", path))
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		return fmt.Errorf("creating the SQLite schema failed: %w", err)
+	}
+	if err := addSQLiteColumns(db); err != nil {
+		return fmt.Errorf("updating the SQLite schema failed: %w", err)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	if err := writeSQLiteTx(tx, ds); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	logger.Info("SQLite database written", zap.String("file", path),
+		zap.Int("leads", len(ds.Leads)), zap.Int("locations", len(ds.Locations)), zap.Int("visits", len(ds.Visits)))
+	return nil
+}
+
+func writeSQLiteTx(tx *sql.Tx, ds Dataset) error {
+	upsertLocation, err := tx.Prepare(upsertStatement("locations",
+		"id", "country", "country_code", "region", "region_code", "city", "state_code"))
+	if err != nil {
+		return err
+	}
+	for _, l := range ds.Locations {
+		a := l.Attributes
+		if _, err := upsertLocation.Exec(l.ID, a.Country, a.CountryCode, a.Region, a.RegionCode, a.City, a.StateCode); err != nil {
+			return fmt.Errorf("writing location %s failed: %w", l.ID, err)
+		}
+	}
+
+	upsertLead, err := tx.Prepare(upsertStatement("leads",
+		"id", "name", "status", "industry", "quality", "visits", "employee_count", "first_visit_date", "last_visit_date",
+		"website_url", "linkedin_url", "facebook_url", "twitter_handle", "business_id", "revenue", "assignee", "emailed_to",
+		"phone", "crm_lead_id", "crm_organization_id", "logo_url", "view_in_leadfeeder", "location_id"))
+	if err != nil {
+		return err
+	}
+	for _, l := range ds.Leads {
+		a := l.Attributes
+		if _, err := upsertLead.Exec(l.ID, a.Name, a.Status, a.Industry, a.Quality, a.Visits, a.EmployeeCount, a.FirstVisitDate, a.LastVisitDate,
+			a.WebsiteURL, a.LinkedinURL, a.FacebookURL, a.TwitterHandle, a.BusinessID, a.Revenue, a.Assignee, a.EmailedTo,
+			a.Phone, a.CrmLeadID, a.CrmOrganizationID, a.LogoURL, a.ViewInLeadfeeder, nullIfEmpty(l.Relationships.Location.Data.ID)); err != nil {
+			return fmt.Errorf("writing lead %s failed: %w", l.ID, err)
+		}
+		if _, err := tx.Exec("DELETE FROM lead_tags WHERE lead_id = ?", l.ID); err != nil {
+			return err
+		}
+		for _, tag := range a.Tags {
+			if _, err := tx.Exec("INSERT OR IGNORE INTO lead_tags (lead_id, tag) VALUES (?, ?)", l.ID, tag); err != nil {
+				return fmt.Errorf("writing the tags of lead %s failed: %w", l.ID, err)
+			}
+		}
+	}
+
+	upsertVisit, err := tx.Prepare(upsertStatement("visits",
+		"id", "lead_id", "started_at", "date", "hour", "source", "medium", "campaign", "keyword", "query_term",
+		"referring_url", "page_depth", "visit_length", "lf_client_id"))
+	if err != nil {
+		return err
+	}
+	insertStep, err := tx.Prepare(`INSERT INTO visit_routes (visit_id, step, hostname, page_path, previous_page_path,
+		time_on_page, page_title, page_url, display_page_name, page_group) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return err
+	}
+	for _, v := range ds.Visits {
+		a := v.Attributes
+		if _, err := upsertVisit.Exec(v.ID, nullIfEmpty(a.LeadID), a.StartedAt.Format(time.RFC3339), a.Date, a.Hour, a.Source, a.Medium,
+			a.Campaign, a.Keyword, a.QueryTerm, a.ReferringURL, a.PageDepth, a.VisitLength, a.LfClientID); err != nil {
+			return fmt.Errorf("writing visit %s failed: %w", v.ID, err)
+		}
+		if _, err := tx.Exec("DELETE FROM visit_routes WHERE visit_id = ?", v.ID); err != nil {
+			return err
+		}
+		for i, r := range a.VisitRoute {
+			if _, err := insertStep.Exec(v.ID, i+1, r.Hostname, r.PagePath, r.PreviousPagePath, r.TimeOnPage, r.PageTitle, r.PageURL, r.DisplayPageName, nullIfEmpty(r.PageGroup)); err != nil {
+				return fmt.Errorf("writing the route of visit %s failed: %w", v.ID, err)
+			}
+		}
+		if _, err := tx.Exec("DELETE FROM visit_ga_client_ids WHERE visit_id = ?", v.ID); err != nil {
+			return err
+		}
+		for _, id := range a.GaClientIDs {
+			if _, err := tx.Exec("INSERT OR IGNORE INTO visit_ga_client_ids (visit_id, ga_client_id) VALUES (?, ?)", v.ID, id); err != nil {
+				return fmt.Errorf("writing the GA client IDs of visit %s failed: %w", v.ID, err)
+			}
+		}
+		if _, err := tx.Exec("DELETE FROM visit_page_groups WHERE visit_id = ?", v.ID); err != nil {
+			return err
+		}
+		for _, group := range a.PageGroups {
+			if _, err := tx.Exec("INSERT OR IGNORE INTO visit_page_groups (visit_id, page_group) VALUES (?, ?)", v.ID, group); err != nil {
+				return fmt.Errorf("writing the page groups of visit %s failed: %w", v.ID, err)
+			}
+		}
+	}
+	return nil
+}
+
+// addSQLiteColumns adds the columns of sqliteColumns which a table lacks
+func addSQLiteColumns(db *sql.DB) error {
+	for _, c := range sqliteColumns {
+		rows, err := db.Query("SELECT name FROM pragma_table_info(?)", c.table)
+		if err != nil {
+			return err
+		}
+		found := false
+		for rows.Next() {
+			var name string
+			if err := rows.Scan(&name); err != nil {
+				rows.Close()
+				return err
+			}
+			found = found || name == c.column
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		if found {
+			continue
+		}
+		if _, err := db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", c.table, c.column, c.definition)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// upsertStatement builds an INSERT which updates all columns if a row with
+// the same id (the first column) exists
+func upsertStatement(table string, columns ...string) string {
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(columns)), ", ")
+	updates := make([]string, 0, len(columns)-1)
+	for _, c := range columns[1:] {
+		updates = append(updates, c+" = excluded."+c)
+	}
+	return fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s) ON CONFLICT(%s) DO UPDATE SET %s",
+		table, strings.Join(columns, ", "), placeholders, columns[0], strings.Join(updates, ", "))
+}
+
+// nullIfEmpty stores missing references as NULL instead of an empty string
+func nullIfEmpty(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
@@ -0,0 +1,114 @@
+/*
+Copyright © 2021 willbenica
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package internal
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteSQLiteIsIdempotent(t *testing.T) {
+	ds, err := ReadDataFiles(TEST_FOLDER+L1, TEST_FOLDER+L2, TEST_FOLDER+V1, TEST_FOLDER+V2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ds.Leads[0].Attributes.Tags = []string{"hot", "ICP"}
+	ds.Visits[0].Attributes.PageGroups = []string{"Pricing", "Product"}
+	ds.Visits[0].Attributes.VisitRoute[0].PageGroup = "Pricing"
+	path := filepath.Join(t.TempDir(), "data.db")
+
+	// Writing the same data twice must not duplicate any rows
+	for i := 0; i < 2; i++ {
+		if err := WriteSQLite(path, ds); err != nil {
+			t.Fatalf("writing the database failed: %q", err)
+		}
+	}
+	// An updated record replaces the stored one
+	ds.Leads[0].Attributes.Name = "renamed"
+	ds.Leads[0].Attributes.Tags = []string{"hot"}
+	if err := WriteSQLite(path, Dataset{Leads: ds.Leads[:1]}); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	cases := []struct {
+		query    string
+		expected string
+	}{
+		{"SELECT COUNT(*) FROM leads", "4"},
+		{"SELECT COUNT(*) FROM locations", "4"},
+		{"SELECT COUNT(*) FROM visits", "4"},
+		{"SELECT COUNT(*) FROM visit_routes", "10"},
+		{"SELECT COUNT(*) FROM visit_ga_client_ids", "4"},
+		{"SELECT COUNT(*) FROM lead_tags", "1"},
+		{"SELECT name FROM leads WHERE id = 'myLeadId'", "renamed"},
+		{"SELECT c.city FROM leads l JOIN locations c ON c.id = l.location_id WHERE l.id = 'myLeadId'", "Dresden"},
+		{"SELECT page_path FROM visit_routes WHERE visit_id = 'visitID_3' AND step = 3", "/coolSolutions/"},
+		{"SELECT COUNT(*) FROM visit_page_groups", "2"},
+		{"SELECT page_group FROM visit_routes WHERE page_group IS NOT NULL", "Pricing"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.query, func(t *testing.T) {
+			var got string
+			if err := db.QueryRow(c.query).Scan(&got); err != nil {
+				t.Fatal(err)
+			}
+			if got != c.expected {
+				t.Errorf("got %q, wanted %q", got, c.expected)
+			}
+		})
+	}
+}
+
+func TestWriteSQLiteAddsColumns(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data.db")
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	// visit_routes as written before page groups
+	if _, err := db.Exec(`CREATE TABLE visit_routes (
+		visit_id TEXT NOT NULL, step INTEGER NOT NULL, hostname TEXT, page_path TEXT, previous_page_path TEXT,
+		time_on_page INTEGER, page_title TEXT, page_url TEXT, display_page_name TEXT, PRIMARY KEY (visit_id, step))`); err != nil {
+		t.Fatal(err)
+	}
+
+	ds, err := ReadDataFiles(TEST_FOLDER + V1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ds.Visits[0].Attributes.VisitRoute[0].PageGroup = "Home"
+	if err := WriteSQLite(path, ds); err != nil {
+		t.Fatalf("writing to the older database failed: %q", err)
+	}
+	var got string
+	if err := db.QueryRow("SELECT page_group FROM visit_routes WHERE page_group IS NOT NULL").Scan(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got != "Home" {
+		t.Errorf("got %q, wanted Home", got)
+	}
+}
@@ -0,0 +1,168 @@
+/*
+Copyright © 2021 willbenica
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package internal
+
+import (
+	"fmt"
+	"math"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Dimensions visits are grouped by in stats
+const (
+	DimensionCampaigns = "campaigns"
+	DimensionSources   = "sources"
+	DimensionMediums   = "mediums"
+	DimensionKeywords  = "keywords"
+	// DimensionDomains groups by the host of the referring URL
+	DimensionDomains = "domains"
+	// DimensionGroups groups by the content groups of the route, see ContentGroups
+	DimensionGroups = "groups"
+)
+
+// Dimensions lists the dimensions in the order they are documented
+var Dimensions = []string{DimensionCampaigns, DimensionSources, DimensionMediums, DimensionKeywords, DimensionDomains, DimensionGroups}
+
+// NoValue stands for an empty campaign, source, etc. in reports
+const NoValue = "(none)"
+
+// ChannelStats are the visit metrics of one campaign, source, medium, keyword,
+// referring domain or content group
+type ChannelStats struct {
+	Name             string  `json:"name"`
+	Visits           int     `json:"visits"`
+	UniqueLeads      int     `json:"unique_leads"`
+	TotalVisitLength int     `json:"total_visit_length"`
+	AvgVisitLength   float64 `json:"avg_visit_length"`
+	AvgPageDepth     float64 `json:"avg_page_depth"`
+}
+
+// IsValidDimension returns `true` if visits can be grouped by dimension
+func IsValidDimension(dimension string) bool {
+	for _, d := range Dimensions {
+		if d == dimension {
+			return true
+		}
+	}
+	return false
+}
+
+// DimensionValues returns the campaign, source, medium, keyword, referring
+// domain or content groups of a visit, NoValue if there is none. Only a visit
+// can be in several content groups.
+func DimensionValues(v VisitData, dimension string) []string {
+	var value string
+	switch dimension {
+	case DimensionCampaigns:
+		value = v.Attributes.Campaign
+	case DimensionSources:
+		value = v.Attributes.Source
+	case DimensionMediums:
+		value = v.Attributes.Medium
+	case DimensionKeywords:
+		value = v.Attributes.Keyword
+	case DimensionDomains:
+		value = ReferringDomain(v.Attributes.ReferringURL)
+	case DimensionGroups:
+		if len(v.Attributes.PageGroups) > 0 {
+			return v.Attributes.PageGroups
+		}
+	}
+	if strings.TrimSpace(value) == "" {
+		return []string{NoValue}
+	}
+	return []string{value}
+}
+
+// ReferringDomain returns the host of a referring URL without "www."
+func ReferringDomain(referringURL string) string {
+	if referringURL == "" {
+		return ""
+	}
+	if !strings.Contains(referringURL, "://") {
+		referringURL = "http://" + referringURL
+	}
+	u, err := url.Parse(referringURL)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+}
+
+// VisitStats groups visits by dimension, the busiest first. A visit in
+// several content groups counts for each of them.
+func VisitStats(visits []VisitData, dimension string) ([]ChannelStats, error) {
+	if !IsValidDimension(dimension) {
+		return nil, fmt.Errorf("unknown dimension %q, use %s", dimension, strings.Join(Dimensions, ", "))
+	}
+	index := map[string]int{}
+	leads := map[string]map[string]bool{}
+	var stats []ChannelStats
+	depth := map[string]int{}
+	for _, v := range visits {
+		for _, name := range DimensionValues(v, dimension) {
+			i, ok := index[name]
+			if !ok {
+				i = len(stats)
+				index[name] = i
+				stats = append(stats, ChannelStats{Name: name})
+				leads[name] = map[string]bool{}
+			}
+			stats[i].Visits++
+			stats[i].TotalVisitLength += v.Attributes.VisitLength
+			depth[name] += v.Attributes.PageDepth
+			if v.Attributes.LeadID != "" {
+				leads[name][v.Attributes.LeadID] = true
+			}
+		}
+	}
+	for i, s := range stats {
+		stats[i].UniqueLeads = len(leads[s.Name])
+		stats[i].AvgVisitLength = round2(float64(s.TotalVisitLength) / float64(s.Visits))
+		stats[i].AvgPageDepth = round2(float64(depth[s.Name]) / float64(s.Visits))
+	}
+	sort.SliceStable(stats, func(i, j int) bool {
+		if stats[i].Visits != stats[j].Visits {
+			return stats[i].Visits > stats[j].Visits
+		}
+		return stats[i].Name < stats[j].Name
+	})
+	return stats, nil
+}
+
+// StatsTable flattens stats, the first column is named after the dimension, e.g. campaign
+func StatsTable(stats []ChannelStats, dimension string) Table {
+	t := Table{Columns: []string{strings.TrimSuffix(dimension, "s"), "visits", "unique_leads", "total_visit_length", "avg_visit_length", "avg_page_depth"}}
+	for _, s := range stats {
+		t.Rows = append(t.Rows, []string{s.Name, strconv.Itoa(s.Visits), strconv.Itoa(s.UniqueLeads),
+			strconv.Itoa(s.TotalVisitLength), formatFloat(s.AvgVisitLength), formatFloat(s.AvgPageDepth)})
+	}
+	return t
+}
+
+// round2 rounds to two decimals for reports
+func round2(f float64) float64 {
+	return math.Round(f*100) / 100
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
@@ -0,0 +1,72 @@
+/*
+Copyright © 2021 willbenica
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package internal
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestVisitStats(t *testing.T) {
+	visits := []VisitData{
+		{Attributes: VisitAttributes{Campaign: "spring", LeadID: "a", VisitLength: 30, PageDepth: 2, ReferringURL: "https://www.Google.com/search?q=x"}},
+		{Attributes: VisitAttributes{Campaign: "spring", LeadID: "a", VisitLength: 10, PageDepth: 1}},
+		{Attributes: VisitAttributes{Campaign: "spring", LeadID: "b", VisitLength: 5, PageDepth: 1, ReferringURL: "news.example.com/post"}},
+		{Attributes: VisitAttributes{LeadID: "c", VisitLength: 100, PageDepth: 6}},
+	}
+	cases := []struct {
+		dimension string
+		expected  []ChannelStats
+	}{
+		{DimensionCampaigns, []ChannelStats{
+			{Name: "spring", Visits: 3, UniqueLeads: 2, TotalVisitLength: 45, AvgVisitLength: 15, AvgPageDepth: 1.33},
+			{Name: NoValue, Visits: 1, UniqueLeads: 1, TotalVisitLength: 100, AvgVisitLength: 100, AvgPageDepth: 6},
+		}},
+		{DimensionDomains, []ChannelStats{
+			{Name: NoValue, Visits: 2, UniqueLeads: 2, TotalVisitLength: 110, AvgVisitLength: 55, AvgPageDepth: 3.5},
+			{Name: "google.com", Visits: 1, UniqueLeads: 1, TotalVisitLength: 30, AvgVisitLength: 30, AvgPageDepth: 2},
+			{Name: "news.example.com", Visits: 1, UniqueLeads: 1, TotalVisitLength: 5, AvgVisitLength: 5, AvgPageDepth: 1},
+		}},
+	}
+	for _, c := range cases {
+		t.Run(c.dimension, func(t *testing.T) {
+			stats, err := VisitStats(visits, c.dimension)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(stats, c.expected) {
+				t.Errorf("expected\n%+v\ngot\n%+v", c.expected, stats)
+			}
+		})
+	}
+
+	if _, err := VisitStats(visits, "countries"); err == nil {
+		t.Error("expected an error for an unknown dimension")
+	}
+}
+
+func TestStatsTable(t *testing.T) {
+	table := StatsTable([]ChannelStats{{Name: "google", Visits: 2, UniqueLeads: 1, TotalVisitLength: 9, AvgVisitLength: 4.5, AvgPageDepth: 1}}, DimensionSources)
+	expected := Table{
+		Columns: []string{"source", "visits", "unique_leads", "total_visit_length", "avg_visit_length", "avg_page_depth"},
+		Rows:    [][]string{{"google", "2", "1", "9", "4.5", "1"}},
+	}
+	if !reflect.DeepEqual(table, expected) {
+		t.Errorf("expected %v, got %v", expected, table)
+	}
+}
@@ -0,0 +1,179 @@
+/*
+Copyright © 2021 willbenica
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package internal
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Timeline is the engagement of one company: the lead with its location and
+// its visits in the order they started
+type Timeline struct {
+	ID         string              `json:"id"`
+	Attributes LeadAttributes      `json:"attributes"`
+	Location   *LocationAttributes `json:"location,omitempty"`
+	// TotalTimeOnSite is the sum of the visit lengths in seconds
+	TotalTimeOnSite int `json:"total_time_on_site"`
+	PagesViewed     int `json:"pages_viewed"`
+	// Sources are the distinct "source / medium" of the visits, in the order they first appeared
+	Sources []string    `json:"sources"`
+	Visits  []VisitData `json:"visits"`
+}
+
+// Timelines joins visits to leads by lead ID. The companies visited most
+// recently come first, leads without visits last. It also returns the number
+// of visits whose lead is not among leads.
+func Timelines(leads []LeadData, locations []Location, visits []VisitData) ([]Timeline, int) {
+	index := LocationIndex(locations)
+	timelines := make([]Timeline, len(leads))
+	byID := map[string]int{}
+	for i, l := range leads {
+		timelines[i] = Timeline{ID: l.ID, Attributes: l.Attributes, Sources: []string{}, Visits: []VisitData{}}
+		if loc, ok := index[l.Relationships.Location.Data.ID]; ok {
+			timelines[i].Location = &loc.Attributes
+		}
+		byID[l.ID] = i
+	}
+	unmatched := 0
+	for _, v := range visits {
+		i, ok := byID[v.Attributes.LeadID]
+		if !ok {
+			unmatched++
+			continue
+		}
+		timelines[i].Visits = append(timelines[i].Visits, v)
+	}
+
+	for i := range timelines {
+		t := &timelines[i]
+		sort.SliceStable(t.Visits, func(a, b int) bool {
+			return t.Visits[a].Attributes.StartedAt.Before(t.Visits[b].Attributes.StartedAt)
+		})
+		seen := map[string]bool{}
+		for _, v := range t.Visits {
+			t.TotalTimeOnSite += v.Attributes.VisitLength
+			t.PagesViewed += pagesViewed(v)
+			source := visitSource(v)
+			if !seen[source] {
+				seen[source] = true
+				t.Sources = append(t.Sources, source)
+			}
+		}
+	}
+	sort.SliceStable(timelines, func(i, j int) bool {
+		a, b := timelines[i].lastVisit(), timelines[j].lastVisit()
+		if !a.Equal(b) {
+			return a.After(b)
+		}
+		return timelines[i].Attributes.Name < timelines[j].Attributes.Name
+	})
+	return timelines, unmatched
+}
+
+// lastVisit returns when the last visit started, the zero time without visits
+func (t Timeline) lastVisit() time.Time {
+	if len(t.Visits) == 0 {
+		return time.Time{}
+	}
+	return t.Visits[len(t.Visits)-1].Attributes.StartedAt
+}
+
+// pagesViewed counts the steps of the route, or the page depth if the route is missing
+func pagesViewed(v VisitData) int {
+	if n := len(v.Attributes.VisitRoute); n > 0 {
+		return n
+	}
+	return v.Attributes.PageDepth
+}
+
+// visitSource returns "source / medium" of a visit, NoValue if both are empty
+func visitSource(v VisitData) string {
+	if s := joinNonEmpty(" / ", v.Attributes.Source, v.Attributes.Medium); s != "" {
+		return s
+	}
+	return NoValue
+}
+
+// WriteTimelines writes the timelines for reading in a terminal: a heading
+// per company followed by one line per visit and its route. Lines are cut to
+// width if it is not 0.
+func WriteTimelines(w io.Writer, timelines []Timeline, width int) error {
+	var b strings.Builder
+	line := func(format string, args ...interface{}) {
+		s := fmt.Sprintf(format, args...)
+		if width > 0 {
+			s = truncate(s, width)
+		}
+		b.WriteString(s + "\n")
+	}
+	for i, t := range timelines {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		a := t.Attributes
+		var location []string
+		if t.Location != nil {
+			location = append(location, t.Location.City, t.Location.Country)
+		}
+		employees := ""
+		if a.EmployeeCount > 0 {
+			employees = strconv.Itoa(a.EmployeeCount) + " employees"
+		}
+		line("%s", joinNonEmpty(" · ", a.Name, a.Industry, employees, joinNonEmpty(", ", location...), a.WebsiteURL))
+		line("  %d visits, %d pages, %s on site, quality %d%s", len(t.Visits), t.PagesViewed,
+			formatDuration(t.TotalTimeOnSite), a.Quality, labelled(" sources: ", strings.Join(t.Sources, ", ")))
+		for _, v := range t.Visits {
+			va := v.Attributes
+			line("  %s  %s  %s, %d pages%s", va.StartedAt.Format("2006-01-02 15:04"), visitSource(v),
+				formatDuration(va.VisitLength), pagesViewed(v), labelled(" campaign ", va.Campaign)+labelled(" keyword ", va.Keyword))
+			var route []string
+			for _, step := range va.VisitRoute {
+				route = append(route, step.PagePath)
+			}
+			if len(route) > 0 {
+				line("      %s", strings.Join(route, " → "))
+			}
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func labelled(label string, value string) string {
+	if value == "" {
+		return ""
+	}
+	return "," + label + value
+}
+
+// formatDuration formats seconds as e.g. 1h 2m, 3m 10s or 45s
+func formatDuration(seconds int) string {
+	d := time.Duration(seconds) * time.Second
+	switch {
+	case d >= time.Hour:
+		return fmt.Sprintf("%dh %dm", int(d.Hours()), int(d.Minutes())%60)
+	case d >= time.Minute:
+		return fmt.Sprintf("%dm %ds", int(d.Minutes()), seconds%60)
+	}
+	return strconv.Itoa(seconds) + "s"
+}
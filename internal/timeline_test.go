@@ -0,0 +1,119 @@
+/*
+Copyright © 2021 willbenica
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package internal
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func timelineTestData() ([]LeadData, []Location, []VisitData) {
+	at := func(day int, hour int) time.Time { return time.Date(2021, 5, day, hour, 0, 0, 0, time.UTC) }
+	leads := []LeadData{
+		{ID: "a", Attributes: LeadAttributes{Name: "Acme", Industry: "Software", EmployeeCount: 120, Quality: 3},
+			Relationships: Relationships{Location: RelatedLocaction{Data: LocData{ID: "de"}}}},
+		{ID: "b", Attributes: LeadAttributes{Name: "Bolt"}},
+		{ID: "c", Attributes: LeadAttributes{Name: "Cobalt"}},
+	}
+	locations := []Location{{ID: "de", Attributes: LocationAttributes{City: "Berlin", Country: "Germany"}}}
+	visits := []VisitData{
+		{ID: "v2", Attributes: VisitAttributes{LeadID: "a", StartedAt: at(20, 9), Source: "linkedin", Medium: "social", VisitLength: 190, Campaign: "spring",
+			VisitRoute: []VisitRoute{{PagePath: "/"}, {PagePath: "/pricing"}}}},
+		{ID: "v1", Attributes: VisitAttributes{LeadID: "a", StartedAt: at(18, 14), Source: "google", Medium: "organic", VisitLength: 45, PageDepth: 1}},
+		{ID: "v3", Attributes: VisitAttributes{LeadID: "a", StartedAt: at(21, 8), Source: "google", Medium: "organic", VisitLength: 3700,
+			VisitRoute: []VisitRoute{{PagePath: "/blog"}}}},
+		{ID: "v4", Attributes: VisitAttributes{LeadID: "b", StartedAt: at(25, 11), VisitLength: 5, PageDepth: 1}},
+		{ID: "v5", Attributes: VisitAttributes{LeadID: "unknown", StartedAt: at(25, 11)}},
+	}
+	return leads, locations, visits
+}
+
+func TestTimelines(t *testing.T) {
+	timelines, unmatched := Timelines(timelineTestData())
+	if unmatched != 1 {
+		t.Errorf("expected 1 unmatched visit, got %d", unmatched)
+	}
+	var order []string
+	for _, tl := range timelines {
+		order = append(order, tl.ID)
+	}
+	if expected := []string{"b", "a", "c"}; !reflect.DeepEqual(order, expected) {
+		t.Fatalf("expected the most recently visited first %v, got %v", expected, order)
+	}
+
+	a := timelines[1]
+	var visits []string
+	for _, v := range a.Visits {
+		visits = append(visits, v.ID)
+	}
+	if expected := []string{"v1", "v2", "v3"}; !reflect.DeepEqual(visits, expected) {
+		t.Errorf("expected visits in order %v, got %v", expected, visits)
+	}
+	if a.TotalTimeOnSite != 3935 || a.PagesViewed != 4 {
+		t.Errorf("expected 3935 seconds and 4 pages, got %d and %d", a.TotalTimeOnSite, a.PagesViewed)
+	}
+	if expected := []string{"google / organic", "linkedin / social"}; !reflect.DeepEqual(a.Sources, expected) {
+		t.Errorf("expected sources %v, got %v", expected, a.Sources)
+	}
+	if a.Location == nil || a.Location.City != "Berlin" {
+		t.Errorf("expected the location of the lead, got %+v", a.Location)
+	}
+	if b := timelines[0]; !reflect.DeepEqual(b.Sources, []string{NoValue}) || b.Location != nil {
+		t.Errorf("expected a visit without source and no location, got %+v", b)
+	}
+	if c := timelines[2]; c.Visits == nil || len(c.Visits) != 0 {
+		t.Errorf("expected an empty list of visits, got %v", c.Visits)
+	}
+}
+
+func TestWriteTimelines(t *testing.T) {
+	timelines, _ := Timelines(timelineTestData())
+	var b bytes.Buffer
+	if err := WriteTimelines(&b, timelines[1:2], 0); err != nil {
+		t.Fatal(err)
+	}
+	expected := `Acme · Software · 120 employees · Berlin, Germany
+  3 visits, 4 pages, 1h 5m on site, quality 3, sources: google / organic, linkedin / social
+  2021-05-18 14:00  google / organic  45s, 1 pages
+  2021-05-20 09:00  linkedin / social  3m 10s, 2 pages, campaign spring
+      / → /pricing
+  2021-05-21 08:00  google / organic  1h 1m, 1 pages
+      /blog
+`
+	if b.String() != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, b.String())
+	}
+
+	b.Reset()
+	if err := WriteTimelines(&b, timelines[1:2], 20); err != nil {
+		t.Fatal(err)
+	}
+	if line := bytes.SplitN(b.Bytes(), []byte("\n"), 2)[0]; string(line) != "Acme · Software · 1…" {
+		t.Errorf("expected the heading cut to 20 characters, got %q", line)
+	}
+}
+
+func TestFormatDuration(t *testing.T) {
+	for seconds, expected := range map[int]string{0: "0s", 59: "59s", 60: "1m 0s", 190: "3m 10s", 3600: "1h 0m", 3935: "1h 5m"} {
+		if got := formatDuration(seconds); got != expected {
+			t.Errorf("expected %d seconds as %q, got %q", seconds, expected, got)
+		}
+	}
+}
@@ -18,12 +18,13 @@ along with this program. If not, see <http://www.gnu.org/licenses/>.
 package internal
 
 import (
+	"bytes"
 	"fmt"
-	"io"
 	"io/ioutil"
 	"net/http"
 	"os"
 	"strings"
+	"text/template"
 	"time"
 
 	"go.uber.org/zap"
@@ -34,6 +35,9 @@ var (
 	logger      *zap.Logger
 	LogConfig   *zap.Config
 	initialized bool
+	// HTTPClient is used for every request to leadfeeder, so that diagnostics
+	// use the same transport settings (proxy, TLS) as the data requests
+	HTTPClient = http.DefaultClient
 )
 
 // // initLogger is used to initialize logging for the program
@@ -88,9 +92,9 @@ func GetEndPointData(ep string, baseURL string, token string, accountID string,
 	request.Header.Add("User-Agent", "lf-cli")
 	request.Header.Add("Accept", "*/*")
 
-	response, err := http.DefaultClient.Do(request)
+	response, err := HTTPClient.Do(request)
 	if err != nil {
-		logger.Error("Issue with http.DefaultClient", zap.Error(err))
+		logger.Error("Issue with HTTPClient", zap.Error(err))
 		return nil, err
 	}
 	defer response.Body.Close()
@@ -151,10 +155,15 @@ func baseURLBuilder(rawBaseURL string) (baseURL string) {
 
 // WriteToFile should write data to the file provided under path
 func WriteToFile(path string, filename string, data string) error {
+	return WriteCompressedFile(path, filename, data, CompressNone)
+}
+
+// CreateFile creates filename under path, creating the directory if needed
+func CreateFile(path string, filename string) (*os.File, error) {
 	Init()
 	cwd, err := os.Getwd()
 	if err != nil {
-		return err
+		return nil, err
 	}
 	if path == "" {
 		path = "."
@@ -164,38 +173,56 @@ func WriteToFile(path string, filename string, data string) error {
 	file, err := os.Create(path + "/" + filename)
 	if err != nil {
 		logger.Error("Failed to create file", zap.String("file", fmt.Sprintf("%s/%s", path, filename)), zap.Error(err))
-		return err
+		return nil, err
 	}
 	logger.Info("File created successfully")
-	defer file.Close()
-
-	_, err = io.WriteString(file, data)
-	if err != nil {
-		return err
-	}
-	file.Sync()
-	return nil
+	return file, nil
 }
 
 func CreateDirectoryIfNotExists(name string) {
 	Init()
-	path, _ := os.Getwd()
-	logger.Info("Trying to create a folder", zap.String("folder", fmt.Sprintf("%s/%s", path, name)))
-	if _, err := os.Stat(fmt.Sprintf("%s/%s", path, name)); os.IsNotExist(err) {
-		err = os.Mkdir(name, os.ModePerm)
+	logger.Info("Trying to create a folder", zap.String("folder", name))
+	if _, err := os.Stat(name); os.IsNotExist(err) {
+		err = os.MkdirAll(name, os.ModePerm)
 		if err != nil {
 			logger.Error("creating folder failed", zap.Error(err))
 		}
 	}
 }
 
-// CreateFileName is used to create standardized file names for outputs
-func CreateFileName(ep string, f Flags) string {
-	Init()
-	if f.StartDate == f.EndDate {
-		return fmt.Sprintf("%s_from_%s.json", ep, f.StartDate)
+// DefaultFileNameTemplate names files like leads_from_2021-05-01_to_2021-05-31.json,
+// a part number is added when the output is split into chunks
+const DefaultFileNameTemplate = "{{.Endpoint}}_from_{{.StartDate}}{{if ne .StartDate .EndDate}}_to_{{.EndDate}}{{end}}{{if .Chunk}}_part{{.Chunk}}{{end}}.{{.Ext}}"
+
+// FileNameData holds the values available in a file name template
+type FileNameData struct {
+	// Endpoint is the resource in the file: leads, locations or visits
+	Endpoint  string
+	Account   string
+	StartDate string
+	EndDate   string
+	// Chunk is the 1-based part number when the output is split, otherwise 0
+	Chunk int
+	// Ext is the file extension of the format, without the dot
+	Ext string
+}
+
+// FileName is used to create standardized file names for outputs from a
+// text/template, see DefaultFileNameTemplate
+func FileName(tmpl string, data FileNameData) (string, error) {
+	t, err := template.New("filename").Option("missingkey=error").Parse(tmpl)
+	if err != nil {
+		return "", fmt.Errorf("invalid file name template: %w", err)
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("invalid file name template: %w", err)
+	}
+	name := buf.String()
+	if name == "" || strings.ContainsRune(name, os.PathSeparator) {
+		return "", fmt.Errorf("file name template %q must produce a file name without directories, got %q", tmpl, name)
 	}
-	return fmt.Sprintf("%s_from_%s_to_%s.json", ep, f.StartDate, f.EndDate)
+	return name, nil
 }
 
 func TodayOrDate(possibleDate string) string {
@@ -147,6 +147,43 @@ func TestTodayOrDate(t *testing.T) {
 	}
 }
 
+func TestFileName(t *testing.T) {
+	data := FileNameData{Endpoint: "leads", Account: "123456", StartDate: "2021-05-01", EndDate: "2021-05-31", Ext: "csv"}
+
+	cases := []struct {
+		name     string
+		template string
+		chunk    int
+		expected string
+		err      bool
+	}{
+		{name: "default template", template: DefaultFileNameTemplate, expected: "leads_from_2021-05-01_to_2021-05-31.csv"},
+		{name: "default template with a chunk", template: DefaultFileNameTemplate, chunk: 3, expected: "leads_from_2021-05-01_to_2021-05-31_part3.csv"},
+		{name: "custom template", template: "{{.Account}}-{{.Endpoint}}-{{.Chunk}}.{{.Ext}}", chunk: 1, expected: "123456-leads-1.csv"},
+		{name: "unknown field", template: "{{.Nope}}", err: true},
+		{name: "directories are rejected", template: "{{.Account}}/{{.Endpoint}}.{{.Ext}}", err: true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			d := data
+			d.Chunk = c.chunk
+			got, err := FileName(c.template, d)
+			if (err != nil) != c.err {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != c.expected {
+				t.Errorf("got %q, wanted %q", got, c.expected)
+			}
+		})
+	}
+
+	data.EndDate = data.StartDate
+	if got, _ := FileName(DefaultFileNameTemplate, data); got != "leads_from_2021-05-01.csv" {
+		t.Errorf("a single day should not repeat the date, got %q", got)
+	}
+}
+
 func TestParseApiResponseToLeadsStruct(t *testing.T) {
 	// Setup
 	leads_one, err := os.Open(TEST_FOLDER + L1)
@@ -0,0 +1,304 @@
+/*
+Copyright © 2021 willbenica
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package internal
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v2"
+)
+
+// Levels of a ConfigProblem
+const (
+	LevelError   = "error"
+	LevelWarning = "warning"
+)
+
+// profileKeys are the keys allowed at the top level and in every profile
+var profileKeys = map[string]bool{
+	"account":         true,
+	"token":           true,
+	"lf-url":          true,
+	"token_command":   true,
+	"credential_file": true,
+}
+
+// topLevelKeys are only allowed at the top level of the config file
+var topLevelKeys = map[string]bool{
+	"default-profile": true,
+	"profiles":        true,
+}
+
+// ConfigProblem is an issue found while validating a config file
+type ConfigProblem struct {
+	Level   string
+	Key     string
+	Message string
+}
+
+// ValidateConfig checks the contents of a config file for unknown keys and
+// missing values. Profiles inherit missing values from the top level.
+func ValidateConfig(raw []byte) ([]ConfigProblem, error) {
+	Init()
+	var doc map[string]interface{}
+	if err := yaml.Unmarshal(raw, &doc); err != nil {
+		return nil, fmt.Errorf("config file is not valid YAML: %w", err)
+	}
+
+	var problems []ConfigProblem
+	for _, key := range sortedKeys(doc) {
+		k := strings.ToLower(key)
+		if !profileKeys[k] && !topLevelKeys[k] {
+			problems = append(problems, ConfigProblem{LevelWarning, key, "unknown key"})
+		}
+	}
+
+	var file ConfigFile
+	file.Account = stringValue(doc, "account")
+	file.Token = stringValue(doc, "token")
+	file.TokenCommand = stringValue(doc, "token_command")
+	file.CredentialFile = stringValue(doc, "credential_file")
+	file.DefaultProfile = stringValue(doc, "default-profile")
+
+	profiles, _ := doc["profiles"].(map[interface{}]interface{})
+	if _, ok := doc["profiles"]; ok && profiles == nil {
+		problems = append(problems, ConfigProblem{LevelError, "profiles", "must be a map of profile names to settings"})
+	}
+
+	profileNames := make([]string, 0, len(profiles))
+	for name := range profiles {
+		profileNames = append(profileNames, fmt.Sprint(name))
+	}
+	sort.Strings(profileNames)
+
+	if len(profileNames) == 0 {
+		problems = append(problems, missingValues("", file.Profile, file.Profile)...)
+	}
+	for _, name := range profileNames {
+		settings, ok := profiles[name].(map[interface{}]interface{})
+		if !ok {
+			problems = append(problems, ConfigProblem{LevelError, "profiles." + name, "must be a map of settings"})
+			continue
+		}
+		p := map[string]interface{}{}
+		for k, v := range settings {
+			p[fmt.Sprint(k)] = v
+		}
+		for _, key := range sortedKeys(p) {
+			if !profileKeys[strings.ToLower(key)] {
+				problems = append(problems, ConfigProblem{LevelWarning, "profiles." + name + "." + key, "unknown key"})
+			}
+		}
+		problems = append(problems, missingValues("profiles."+name+".", Profile{
+			Account:        stringValue(p, "account"),
+			Token:          stringValue(p, "token"),
+			TokenCommand:   stringValue(p, "token_command"),
+			CredentialFile: stringValue(p, "credential_file"),
+		}, file.Profile)...)
+	}
+
+	if file.DefaultProfile != "" {
+		found := false
+		for _, name := range profileNames {
+			found = found || strings.EqualFold(name, file.DefaultProfile)
+		}
+		if !found {
+			problems = append(problems, ConfigProblem{LevelError, "default-profile", fmt.Sprintf("profile %q does not exist", file.DefaultProfile)})
+		}
+	}
+	return problems, nil
+}
+
+// missingValues reports an account or token that is set neither in p nor in fallback
+func missingValues(prefix string, p Profile, fallback Profile) []ConfigProblem {
+	var problems []ConfigProblem
+	if p.Account == "" && fallback.Account == "" {
+		problems = append(problems, ConfigProblem{LevelError, prefix + "account", "missing value"})
+	}
+	if p.Token == "" && p.TokenCommand == "" && fallback.Token == "" && fallback.TokenCommand == "" {
+		problems = append(problems, ConfigProblem{LevelWarning, prefix + "token", "no token or token_command, the token must come from LF_CLI_TOKEN or 'lf-cli login'"})
+	}
+	return problems
+}
+
+// CheckFilePermissions reports a config file which can be read by other users
+func CheckFilePermissions(path string) (*ConfigProblem, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.Mode().Perm()&0077 != 0 {
+		return &ConfigProblem{LevelError, path, fmt.Sprintf("insecure permissions %v, run 'chmod 600 %s'", info.Mode().Perm(), path)}, nil
+	}
+	return nil, nil
+}
+
+// CheckReachable reports whether the leadfeeder API at baseURL answers at all
+func CheckReachable(baseURL string, timeout time.Duration) error {
+	Init()
+	url := "https://" + baseURLBuilder(baseURL) + "/"
+	logger.Debug("Checking if URL is reachable", zap.String("URL", url))
+	client := *HTTPClient
+	client.Timeout = timeout
+	response, err := client.Get(url)
+	if err != nil {
+		return err
+	}
+	response.Body.Close()
+	return nil
+}
+
+// CheckCredentials makes a single small request to verify that token grants
+// access to the account
+func CheckCredentials(baseURL string, token string, accountID string) error {
+	Init()
+	today := time.Now().Format("2006-01-02")
+	url, err := EndpointURLBuilder(baseURL, "leads", accountID, today, today, 1, 1)
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Authorization", "Bearer "+token)
+	request.Header.Add("User-Agent", "lf-cli")
+	request.Header.Add("Accept", "*/*")
+
+	response, err := HTTPClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	ioutil.ReadAll(response.Body)
+
+	switch response.StatusCode {
+	case http.StatusOK:
+		return nil
+	case http.StatusUnauthorized:
+		return fmt.Errorf("the token was rejected (%s)", response.Status)
+	case http.StatusForbidden, http.StatusNotFound:
+		return fmt.Errorf("the token has no access to account %s (%s)", accountID, response.Status)
+	default:
+		return fmt.Errorf("unexpected response from leadfeeder: %s", response.Status)
+	}
+}
+
+// WriteConfigFile sets values in the config file at path, creating it if
+// needed. With a profile name the values are written to profiles.<name>,
+// names are case-insensitive like in GetProfile. The file is always left
+// with 0600 permissions.
+func WriteConfigFile(path string, profile string, values map[string]string) error {
+	Init()
+	doc := yaml.MapSlice{}
+	if raw, err := ioutil.ReadFile(path); err == nil {
+		if err := yaml.Unmarshal(raw, &doc); err != nil {
+			return fmt.Errorf("existing config file %s is not valid YAML: %w", path, err)
+		}
+	}
+
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var settings yaml.MapSlice
+	if profile != "" {
+		profiles, _ := getMapSliceItem(doc, "profiles").(yaml.MapSlice)
+		profile = profileKey(profiles, profile)
+		settings, _ = getMapSliceItem(profiles, profile).(yaml.MapSlice)
+		for _, k := range keys {
+			settings = setMapSliceItem(settings, k, values[k])
+		}
+		profiles = setMapSliceItem(profiles, profile, settings)
+		doc = setMapSliceItem(doc, "profiles", profiles)
+	} else {
+		for _, k := range keys {
+			doc = setMapSliceItem(doc, k, values[k])
+		}
+	}
+
+	raw, err := yaml.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	logger.Debug("Writing config file", zap.String("file", path))
+	if err := ioutil.WriteFile(path, raw, 0600); err != nil {
+		return err
+	}
+	return os.Chmod(path, 0600)
+}
+
+// profileKey returns the key of the profile called name, an existing key in
+// any case or else the lowercased name
+func profileKey(profiles yaml.MapSlice, name string) string {
+	for _, item := range profiles {
+		if key := fmt.Sprint(item.Key); strings.EqualFold(key, name) {
+			return key
+		}
+	}
+	return strings.ToLower(name)
+}
+
+func getMapSliceItem(m yaml.MapSlice, key string) interface{} {
+	for _, item := range m {
+		if fmt.Sprint(item.Key) == key {
+			return item.Value
+		}
+	}
+	return nil
+}
+
+func setMapSliceItem(m yaml.MapSlice, key string, value interface{}) yaml.MapSlice {
+	for i, item := range m {
+		if fmt.Sprint(item.Key) == key {
+			m[i].Value = value
+			return m
+		}
+	}
+	return append(m, yaml.MapItem{Key: key, Value: value})
+}
+
+func stringValue(m map[string]interface{}, key string) string {
+	for k, v := range m {
+		if strings.ToLower(k) == key && v != nil {
+			return fmt.Sprint(v)
+		}
+	}
+	return ""
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,134 @@
+/*
+Copyright © 2021 willbenica
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jarcoal/httpmock"
+)
+
+func TestValidateConfig(t *testing.T) {
+	cases := []struct {
+		name     string
+		yaml     string
+		expected []ConfigProblem
+	}{
+		{
+			name: "valid config",
+			yaml: "account: \"123456\"\ntoken: \"xxxx\"\n",
+		},
+		{
+			name: "unknown key and missing account",
+			yaml: "acount: \"123456\"\ntoken: \"xxxx\"\n",
+			expected: []ConfigProblem{
+				{LevelWarning, "acount", "unknown key"},
+				{LevelError, "account", "missing value"},
+			},
+		},
+		{
+			name: "profiles inherit from the top level",
+			yaml: "token_command: \"pass show lf\"\nprofiles:\n  clientA:\n    account: \"1\"\n  clientB:\n    acount: \"2\"\n",
+			expected: []ConfigProblem{
+				{LevelWarning, "profiles.clientB.acount", "unknown key"},
+				{LevelError, "profiles.clientB.account", "missing value"},
+			},
+		},
+		{
+			name: "unknown default profile",
+			yaml: "default-profile: clientC\nprofiles:\n  clientA:\n    account: \"1\"\n    token: \"x\"\n",
+			expected: []ConfigProblem{
+				{LevelError, "default-profile", "profile \"clientC\" does not exist"},
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := ValidateConfig([]byte(c.yaml))
+			if err != nil {
+				t.Fatalf("got an unexpected error: %q", err)
+			}
+			if len(got) != len(c.expected) {
+				t.Fatalf("got %v, wanted %v", got, c.expected)
+			}
+			for i := range got {
+				if got[i] != c.expected[i] {
+					t.Errorf("got %v, wanted %v", got[i], c.expected[i])
+				}
+			}
+		})
+	}
+}
+
+func TestWriteConfigFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lf-cli", ".lf-cli.yaml")
+	if err := WriteConfigFile(path, "", map[string]string{"account": "1", "token": "x"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteConfigFile(path, "clientA", map[string]string{"account": "2", "token": "x"}); err != nil {
+		t.Fatal(err)
+	}
+	// Profile names are case-insensitive, the same profile is updated
+	if err := WriteConfigFile(path, "CLIENTA", map[string]string{"token": "y"}); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("got permissions %v, wanted 0600", info.Mode().Perm())
+	}
+	raw, _ := os.ReadFile(path)
+	want := "account: \"1\"\ntoken: x\nprofiles:\n  clienta:\n    account: \"2\"\n    token: \"y\"\n"
+	if string(raw) != want {
+		t.Errorf("got %q, wanted %q", raw, want)
+	}
+	problems, _ := ValidateConfig(raw)
+	if len(problems) != 0 {
+		t.Errorf("the written config file is not valid: %v", problems)
+	}
+}
+
+func TestCheckCredentials(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	cases := []struct {
+		name    string
+		status  int
+		wantErr bool
+	}{
+		{name: "valid credentials", status: 200},
+		{name: "invalid token", status: 401, wantErr: true},
+		{name: "no access to the account", status: 403, wantErr: true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			httpmock.RegisterResponder("GET", URL+"/"+ACCOUNT_ID+"/"+ENDPOINT, httpmock.NewStringResponder(c.status, MOCK_RESPONSE))
+			err := CheckCredentials(URL, TOKEN, ACCOUNT_ID)
+			if (err != nil) != c.wantErr {
+				t.Errorf("got error %v, wanted an error: %v", err, c.wantErr)
+			}
+		})
+	}
+}
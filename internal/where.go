@@ -0,0 +1,794 @@
+/*
+Copyright © 2021 willbenica
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package internal
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Filter is a compiled --where expression. Names refer to the attributes of
+// a lead or visit (quality, status, visit_route), to the top level fields
+// (id, location for leads) or, with a leading dot, to the current element
+// inside any() and all(). Supported are
+//
+//	== != < <= > >=   comparison of numbers and strings (dates compare as strings)
+//	~ !~              regular expression match
+//	in                membership in a list or substring of a string
+//	&& || !           logic, with parentheses for grouping
+//	any(list, expr)   true if expr is true for any element, all() for every element
+//	len(x), lower(x)  length of a list or string, lower case string
+type Filter struct {
+	source   string
+	endpoint string
+	root     node
+}
+
+// NewFilter compiles expr and checks the names it uses against the fields of
+// leads or visits, depending on endpoint
+func NewFilter(endpoint string, expr string) (*Filter, error) {
+	if endpoint != "leads" && endpoint != "visits" {
+		return nil, fmt.Errorf("--where applies to leads and visits, not %s", endpoint)
+	}
+	p := &parser{lexer: lexer{input: expr}}
+	if err := p.next(); err != nil {
+		return nil, p.errorf("%s", err)
+	}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.tok.kind != tokEOF {
+		return nil, p.errorf("unexpected %q", p.tok.text)
+	}
+
+	schema, err := schemaDocument(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateNode(root, schema, nil); err != nil {
+		return nil, fmt.Errorf("--where %s: %w", expr, err)
+	}
+	return &Filter{source: expr, endpoint: endpoint, root: root}, nil
+}
+
+// String returns the expression the filter was compiled from
+func (f *Filter) String() string {
+	return f.source
+}
+
+// Endpoint returns whether the filter applies to leads or visits
+func (f *Filter) Endpoint() string {
+	return f.endpoint
+}
+
+// Match reports whether the expression is true for doc, the JSON form of a
+// record as built by toDocument and leadDocument
+func (f *Filter) Match(doc map[string]interface{}) (bool, error) {
+	v, err := f.root.eval(&evalEnv{doc: doc})
+	if err != nil {
+		return false, fmt.Errorf("--where %s: %w", f.source, err)
+	}
+	return truthy(v), nil
+}
+
+// FilterLeads returns the leads the expression is true for
+func (f *Filter) FilterLeads(leads []LeadData, locations []Location) ([]LeadData, error) {
+	index := LocationIndex(locations)
+	kept := make([]LeadData, 0, len(leads))
+	for _, l := range leads {
+		var location *Location
+		if loc, ok := index[l.Relationships.Location.Data.ID]; ok {
+			location = &loc
+		}
+		doc, err := leadDocument(l, location)
+		if err != nil {
+			return nil, err
+		}
+		ok, err := f.Match(doc)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			kept = append(kept, l)
+		}
+	}
+	return kept, nil
+}
+
+// LeadLocations returns the locations the leads refer to, in the order of locations
+func LeadLocations(leads []LeadData, locations []Location) []Location {
+	used := map[string]bool{}
+	for _, l := range leads {
+		used[l.Relationships.Location.Data.ID] = true
+	}
+	kept := make([]Location, 0, len(locations))
+	for _, loc := range locations {
+		if used[loc.ID] {
+			kept = append(kept, loc)
+		}
+	}
+	return kept
+}
+
+// FilterVisits returns the visits the expression is true for
+func (f *Filter) FilterVisits(visits []VisitData) ([]VisitData, error) {
+	kept := make([]VisitData, 0, len(visits))
+	for _, v := range visits {
+		doc, err := toDocument(v)
+		if err != nil {
+			return nil, err
+		}
+		ok, err := f.Match(doc)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			kept = append(kept, v)
+		}
+	}
+	return kept, nil
+}
+
+// ------------------------------------
+// Lexer
+
+const (
+	tokEOF = iota
+	tokName
+	tokPath
+	tokString
+	tokNumber
+	tokOp
+)
+
+type token struct {
+	kind int
+	text string
+	pos  int
+}
+
+type lexer struct {
+	input string
+	pos   int
+}
+
+var operators = []string{"&&", "||", "==", "!=", "<=", ">=", "!~", "<", ">", "~", "!", "(", ")", "[", "]", ","}
+
+func (l *lexer) next() (token, error) {
+	for l.pos < len(l.input) && unicode.IsSpace(rune(l.input[l.pos])) {
+		l.pos++
+	}
+	start := l.pos
+	if l.pos >= len(l.input) {
+		return token{tokEOF, "", start}, nil
+	}
+	c := l.input[l.pos]
+	switch {
+	case c == '"':
+		l.pos++
+		for l.pos < len(l.input) && l.input[l.pos] != '"' {
+			if l.input[l.pos] == '\\' {
+				l.pos++
+			}
+			l.pos++
+		}
+		if l.pos >= len(l.input) {
+			return token{}, fmt.Errorf("unterminated string at %d", start)
+		}
+		l.pos++
+		s, err := strconv.Unquote(l.input[start:l.pos])
+		if err != nil {
+			return token{}, fmt.Errorf("invalid string at %d: %w", start, err)
+		}
+		return token{tokString, s, start}, nil
+	case isDigit(c) || (c == '-' && l.pos+1 < len(l.input) && isDigit(l.input[l.pos+1])):
+		l.pos++
+		for l.pos < len(l.input) && (isDigit(l.input[l.pos]) || l.input[l.pos] == '.') {
+			l.pos++
+		}
+		return token{tokNumber, l.input[start:l.pos], start}, nil
+	case c == '.' || isNameStart(c):
+		l.pos++
+		for l.pos < len(l.input) && (isNameStart(l.input[l.pos]) || isDigit(l.input[l.pos]) || l.input[l.pos] == '.') {
+			l.pos++
+		}
+		text := l.input[start:l.pos]
+		if c == '.' || strings.Contains(text, ".") {
+			return token{tokPath, text, start}, nil
+		}
+		return token{tokName, text, start}, nil
+	}
+	for _, op := range operators {
+		if strings.HasPrefix(l.input[l.pos:], op) {
+			l.pos += len(op)
+			return token{tokOp, op, start}, nil
+		}
+	}
+	return token{}, fmt.Errorf("unexpected %q at %d", c, start)
+}
+
+func isDigit(c byte) bool     { return c >= '0' && c <= '9' }
+func isNameStart(c byte) bool { return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') }
+
+// ------------------------------------
+// Parser
+
+type parser struct {
+	lexer lexer
+	tok   token
+}
+
+func (p *parser) next() error {
+	t, err := p.lexer.next()
+	if err != nil {
+		return err
+	}
+	p.tok = t
+	return nil
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("--where %s: %s (at %d)", p.lexer.input, fmt.Sprintf(format, args...), p.tok.pos)
+}
+
+func (p *parser) isOp(op string) bool {
+	return p.tok.kind == tokOp && p.tok.text == op
+}
+
+func (p *parser) expect(op string) error {
+	if !p.isOp(op) {
+		return p.errorf("expected %q", op)
+	}
+	if err := p.next(); err != nil {
+		return p.errorf("%s", err)
+	}
+	return nil
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("||") {
+		if err := p.next(); err != nil {
+			return nil, p.errorf("%s", err)
+		}
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = logicNode{"||", left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("&&") {
+		if err := p.next(); err != nil {
+			return nil, p.errorf("%s", err)
+		}
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = logicNode{"&&", left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseNot() (node, error) {
+	if p.isOp("!") {
+		if err := p.next(); err != nil {
+			return nil, p.errorf("%s", err)
+		}
+		x, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{x}, nil
+	}
+	return p.parseCompare()
+}
+
+func (p *parser) parseCompare() (node, error) {
+	left, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	op := ""
+	switch {
+	case p.tok.kind == tokOp && compareOperators[p.tok.text]:
+		op = p.tok.text
+	case p.tok.kind == tokName && p.tok.text == "in":
+		op = "in"
+	default:
+		return left, nil
+	}
+	if err := p.next(); err != nil {
+		return nil, p.errorf("%s", err)
+	}
+	right, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	n := compareNode{op: op, left: left, right: right}
+	if op == "~" || op == "!~" {
+		if lit, ok := right.(literalNode); ok {
+			pattern, ok := lit.value.(string)
+			if !ok {
+				return nil, p.errorf("the pattern of %s must be a string", op)
+			}
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return nil, p.errorf("invalid pattern %q: %s", pattern, err)
+			}
+			n.re = re
+		}
+	}
+	return n, nil
+}
+
+// compareOperators are the operators between two values, besides in
+var compareOperators = map[string]bool{"==": true, "!=": true, "<": true, "<=": true, ">": true, ">=": true, "~": true, "!~": true}
+
+func (p *parser) parsePrimary() (node, error) {
+	t := p.tok
+	switch {
+	case t.kind == tokString:
+		return literalNode{t.text}, p.next()
+	case t.kind == tokNumber:
+		f, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, p.errorf("invalid number %q", t.text)
+		}
+		return literalNode{f}, p.next()
+	case t.kind == tokOp && t.text == "(":
+		if err := p.next(); err != nil {
+			return nil, p.errorf("%s", err)
+		}
+		x, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		return x, p.expect(")")
+	case t.kind == tokOp && t.text == "[":
+		if err := p.next(); err != nil {
+			return nil, p.errorf("%s", err)
+		}
+		var items []node
+		for !p.isOp("]") {
+			item, err := p.parsePrimary()
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+			if !p.isOp(",") {
+				break
+			}
+			if err := p.next(); err != nil {
+				return nil, p.errorf("%s", err)
+			}
+		}
+		return listNode{items}, p.expect("]")
+	case t.kind == tokPath:
+		return newPathNode(t.text), p.next()
+	case t.kind == tokName:
+		if err := p.next(); err != nil {
+			return nil, p.errorf("%s", err)
+		}
+		switch t.text {
+		case "true":
+			return literalNode{true}, nil
+		case "false":
+			return literalNode{false}, nil
+		case "null":
+			return literalNode{nil}, nil
+		}
+		if !p.isOp("(") {
+			return newPathNode(t.text), nil
+		}
+		return p.parseCall(t.text)
+	}
+	if t.kind == tokEOF {
+		return nil, p.errorf("unexpected end of the expression")
+	}
+	return nil, p.errorf("unexpected %q", t.text)
+}
+
+// filterFunctions maps the functions to their number of arguments
+var filterFunctions = map[string]int{"any": 2, "all": 2, "len": 1, "lower": 1}
+
+func (p *parser) parseCall(name string) (node, error) {
+	argc, ok := filterFunctions[name]
+	if !ok {
+		return nil, p.errorf("unknown function %s, use any, all, len or lower", name)
+	}
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	var args []node
+	for !p.isOp(")") {
+		arg, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+		if !p.isOp(",") {
+			break
+		}
+		if err := p.next(); err != nil {
+			return nil, p.errorf("%s", err)
+		}
+	}
+	if err := p.expect(")"); err != nil {
+		return nil, err
+	}
+	if len(args) != argc {
+		return nil, p.errorf("%s takes %d argument(s), got %d", name, argc, len(args))
+	}
+	if name == "any" || name == "all" {
+		if _, ok := args[0].(pathNode); !ok {
+			return nil, p.errorf("the first argument of %s must be a list field", name)
+		}
+	}
+	return callNode{name, args}, nil
+}
+
+// ------------------------------------
+// Evaluation
+
+type evalEnv struct {
+	doc map[string]interface{}
+	// current is the element of the list any() or all() iterate over
+	current interface{}
+}
+
+type node interface {
+	eval(env *evalEnv) (interface{}, error)
+}
+
+type literalNode struct{ value interface{} }
+
+func (n literalNode) eval(*evalEnv) (interface{}, error) { return n.value, nil }
+
+type listNode struct{ items []node }
+
+func (n listNode) eval(env *evalEnv) (interface{}, error) {
+	values := make([]interface{}, len(n.items))
+	for i, item := range n.items {
+		v, err := item.eval(env)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = v
+	}
+	return values, nil
+}
+
+// pathNode is a name like quality or location.city, or an element path like .page_path
+type pathNode struct {
+	relative bool
+	parts    []string
+}
+
+func newPathNode(text string) pathNode {
+	n := pathNode{relative: strings.HasPrefix(text, ".")}
+	if text = strings.Trim(text, "."); text != "" {
+		n.parts = strings.Split(text, ".")
+	}
+	return n
+}
+
+func (n pathNode) String() string {
+	s := strings.Join(n.parts, ".")
+	if n.relative {
+		return "." + s
+	}
+	return s
+}
+
+// start returns the value the path is resolved from: the current element,
+// the attributes if they have a field of that name, or the record itself
+func (n pathNode) start(doc map[string]interface{}, current interface{}) interface{} {
+	if n.relative {
+		return current
+	}
+	if attributes, ok := doc["attributes"].(map[string]interface{}); ok && len(n.parts) > 0 {
+		if _, ok := attributes[n.parts[0]]; ok {
+			return attributes
+		}
+	}
+	return doc
+}
+
+func (n pathNode) eval(env *evalEnv) (interface{}, error) {
+	start := n.start(env.doc, env.current)
+	if len(n.parts) == 0 {
+		return start, nil
+	}
+	return lookup(start, strings.Join(n.parts, ".")), nil
+}
+
+type notNode struct{ x node }
+
+func (n notNode) eval(env *evalEnv) (interface{}, error) {
+	v, err := n.x.eval(env)
+	return !truthy(v), err
+}
+
+type logicNode struct {
+	op          string
+	left, right node
+}
+
+func (n logicNode) eval(env *evalEnv) (interface{}, error) {
+	l, err := n.left.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	if truthy(l) == (n.op == "||") {
+		return truthy(l), nil
+	}
+	r, err := n.right.eval(env)
+	return truthy(r), err
+}
+
+type compareNode struct {
+	op          string
+	left, right node
+	re          *regexp.Regexp
+}
+
+func (n compareNode) eval(env *evalEnv) (interface{}, error) {
+	l, err := n.left.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	r, err := n.right.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	switch n.op {
+	case "==":
+		return equal(l, r), nil
+	case "!=":
+		return !equal(l, r), nil
+	case "~", "!~":
+		re := n.re
+		if re == nil {
+			pattern, ok := r.(string)
+			if !ok {
+				return nil, fmt.Errorf("the pattern of %s must be a string, got %s", n.op, typeName(r))
+			}
+			if re, err = regexp.Compile(pattern); err != nil {
+				return nil, err
+			}
+		}
+		s, ok := l.(string)
+		return ok && re.MatchString(s) == (n.op == "~"), nil
+	case "in":
+		switch r := r.(type) {
+		case []interface{}:
+			for _, item := range r {
+				if equal(l, item) {
+					return true, nil
+				}
+			}
+			return false, nil
+		case string:
+			s, ok := l.(string)
+			return ok && strings.Contains(r, s), nil
+		case nil:
+			return false, nil
+		}
+		return nil, fmt.Errorf("in needs a list or a string on the right, got %s", typeName(r))
+	}
+
+	if l == nil || r == nil {
+		return false, nil
+	}
+	var c int
+	switch l := l.(type) {
+	case float64:
+		rf, ok := r.(float64)
+		if !ok {
+			return nil, fmt.Errorf("cannot compare number and %s with %s", typeName(r), n.op)
+		}
+		c = compareFloats(l, rf)
+	case string:
+		rs, ok := r.(string)
+		if !ok {
+			return nil, fmt.Errorf("cannot compare string and %s with %s", typeName(r), n.op)
+		}
+		c = strings.Compare(l, rs)
+	default:
+		return nil, fmt.Errorf("cannot compare %s with %s", typeName(l), n.op)
+	}
+	switch n.op {
+	case "<":
+		return c < 0, nil
+	case "<=":
+		return c <= 0, nil
+	case ">":
+		return c > 0, nil
+	default:
+		return c >= 0, nil
+	}
+}
+
+type callNode struct {
+	name string
+	args []node
+}
+
+func (n callNode) eval(env *evalEnv) (interface{}, error) {
+	v, err := n.args[0].eval(env)
+	if err != nil {
+		return nil, err
+	}
+	switch n.name {
+	case "len":
+		switch v := v.(type) {
+		case string:
+			return float64(len([]rune(v))), nil
+		case []interface{}:
+			return float64(len(v)), nil
+		case nil:
+			return float64(0), nil
+		}
+		return nil, fmt.Errorf("len needs a list or a string, got %s", typeName(v))
+	case "lower":
+		s, ok := v.(string)
+		if !ok && v != nil {
+			return nil, fmt.Errorf("lower needs a string, got %s", typeName(v))
+		}
+		return strings.ToLower(s), nil
+	}
+
+	list, ok := v.([]interface{})
+	if !ok && v != nil {
+		return nil, fmt.Errorf("%s needs a list, got %s", n.name, typeName(v))
+	}
+	for _, item := range list {
+		match, err := n.args[1].eval(&evalEnv{doc: env.doc, current: item})
+		if err != nil {
+			return nil, err
+		}
+		if truthy(match) == (n.name == "any") {
+			return n.name == "any", nil
+		}
+	}
+	return n.name == "all", nil
+}
+
+// validateNode checks every name against schema, element is the schema of
+// the list element inside any() and all()
+func validateNode(n node, schema map[string]interface{}, element interface{}) error {
+	switch n := n.(type) {
+	case pathNode:
+		if n.relative && element == nil {
+			return fmt.Errorf("%s can only be used inside any() or all()", n)
+		}
+		start := n.start(schema, element)
+		if m, ok := start.(map[string]interface{}); ok && len(n.parts) > 0 && !pathExists(m, strings.Join(n.parts, ".")) {
+			return fmt.Errorf("unknown field %s", n)
+		}
+	case listNode:
+		for _, item := range n.items {
+			if err := validateNode(item, schema, element); err != nil {
+				return err
+			}
+		}
+	case notNode:
+		return validateNode(n.x, schema, element)
+	case logicNode:
+		if err := validateNode(n.left, schema, element); err != nil {
+			return err
+		}
+		return validateNode(n.right, schema, element)
+	case compareNode:
+		if err := validateNode(n.left, schema, element); err != nil {
+			return err
+		}
+		return validateNode(n.right, schema, element)
+	case callNode:
+		if err := validateNode(n.args[0], schema, element); err != nil {
+			return err
+		}
+		if n.name == "any" || n.name == "all" {
+			list := n.args[0].(pathNode)
+			items, _ := lookup(list.start(schema, element), strings.Join(list.parts, ".")).([]interface{})
+			if len(items) == 0 {
+				return fmt.Errorf("%s is not a list", list)
+			}
+			return validateNode(n.args[1], schema, items[0])
+		}
+	}
+	return nil
+}
+
+func truthy(v interface{}) bool {
+	switch v := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	case float64:
+		return v != 0
+	case string:
+		return v != ""
+	case []interface{}:
+		return len(v) > 0
+	}
+	return true
+}
+
+func equal(a, b interface{}) bool {
+	return reflect.DeepEqual(a, b)
+}
+
+func compareFloats(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func typeName(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "bool"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "list"
+	}
+	return "object"
+}
+
+// FilterResponse returns a copy of page with only the records the expression is true for
+func (f *Filter) FilterResponse(page EndPoint) (EndPoint, error) {
+	switch p := page.(type) {
+	case LeadsResponse:
+		leads, err := f.FilterLeads(p.Data, p.Included)
+		p.Data, p.Included = leads, LeadLocations(leads, p.Included)
+		return p, err
+	case VisitsResponse:
+		visits, err := f.FilterVisits(p.Data)
+		p.Data = visits
+		return p, err
+	}
+	return nil, fmt.Errorf("--where can't filter %s", page.Type())
+}
@@ -0,0 +1,162 @@
+/*
+Copyright © 2021 willbenica
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package internal
+
+import (
+	"testing"
+)
+
+func TestFilterLeads(t *testing.T) {
+	leads := []LeadData{
+		{ID: "a", Attributes: LeadAttributes{Name: "Acme", Quality: 4, Status: "new", Industry: "Software", Tags: []string{"hot"}},
+			Relationships: Relationships{Location: RelatedLocaction{Data: LocData{ID: "l1"}}}},
+		{ID: "b", Attributes: LeadAttributes{Name: "Bolt", Quality: 2, Status: "new", Industry: "IT"}},
+		{ID: "c", Attributes: LeadAttributes{Name: "Cogs", Quality: 5, Status: "hidden", Industry: "Retail", LastVisitDate: "2021-05-03"}},
+	}
+	locations := []Location{{ID: "l1", Attributes: LocationAttributes{City: "Tartu", Country: "Estonia"}}}
+
+	cases := []struct {
+		expr     string
+		expected string
+	}{
+		{`quality >= 3 && status == "new"`, "a"},
+		{`industry in ["Software","IT"]`, "ab"},
+		{`!(industry in ["Software","IT"])`, "c"},
+		{`quality > 4 || name ~ "^B"`, "bc"},
+		{`location.city == "Tartu"`, "a"},
+		{`"hot" in tags`, "a"},
+		{`any(tags, . == "hot")`, "a"},
+		{`len(tags) == 0`, "bc"},
+		{`lower(name) in "acme bolt"`, "ab"},
+		{`last_visit_date >= "2021-05-01"`, "c"},
+		{`quality != -1 && id !~ "[ab]"`, "c"},
+	}
+	for _, c := range cases {
+		t.Run(c.expr, func(t *testing.T) {
+			f, err := NewFilter("leads", c.expr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			kept, err := f.FilterLeads(leads, locations)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := ""
+			for _, l := range kept {
+				got += l.ID
+			}
+			if got != c.expected {
+				t.Errorf("expected %q, got %q", c.expected, got)
+			}
+		})
+	}
+}
+
+func TestFilterVisits(t *testing.T) {
+	visits := []VisitData{
+		{ID: "1", Attributes: VisitAttributes{Source: "google", VisitRoute: []VisitRoute{{PagePath: "/"}, {PagePath: "/pricing", TimeOnPage: 30}}}},
+		{ID: "2", Attributes: VisitAttributes{Source: "bing", VisitRoute: []VisitRoute{{PagePath: "/blog"}}}},
+	}
+	cases := []struct {
+		expr     string
+		expected string
+	}{
+		{`any(visit_route, .page_path ~ "/pricing")`, "1"},
+		{`all(visit_route, .time_on_page == 0)`, "2"},
+		{`source == "google" && any(visit_route, .page_path == "/pricing" && .time_on_page >= 30)`, "1"},
+		{`len(visit_route) > 1`, "1"},
+	}
+	for _, c := range cases {
+		t.Run(c.expr, func(t *testing.T) {
+			f, err := NewFilter("visits", c.expr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			kept, err := f.FilterVisits(visits)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := ""
+			for _, v := range kept {
+				got += v.ID
+			}
+			if got != c.expected {
+				t.Errorf("expected %q, got %q", c.expected, got)
+			}
+		})
+	}
+}
+
+func TestFilterResponseLocations(t *testing.T) {
+	page := LeadsResponse{
+		Data: []LeadData{
+			{ID: "a", Attributes: LeadAttributes{Quality: 4}, Relationships: Relationships{Location: RelatedLocaction{Data: LocData{ID: "l1"}}}},
+			{ID: "b", Attributes: LeadAttributes{Quality: 2}, Relationships: Relationships{Location: RelatedLocaction{Data: LocData{ID: "l2"}}}},
+		},
+		Included: []Location{{ID: "l1"}, {ID: "l2"}},
+	}
+	f, err := NewFilter("leads", `quality >= 3`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	filtered, err := f.FilterResponse(page)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, locations, _, _ := filtered.GetData()
+	if len(locations) != 1 || locations[0].ID != "l1" {
+		t.Errorf("expected only the location l1 of the kept lead, got %v", locations)
+	}
+}
+
+func TestNewFilterErrors(t *testing.T) {
+	cases := []struct {
+		name     string
+		endpoint string
+		expr     string
+	}{
+		{"unknown field", "leads", `qualty > 3`},
+		{"location of a visit", "visits", `location.city == "Tartu"`},
+		{"unknown element field", "visits", `any(visit_route, .path == "/")`},
+		{"element outside any", "visits", `.page_path == "/"`},
+		{"any over a plain value", "visits", `any(source, . == "x")`},
+		{"unknown function", "leads", `upper(name) == "X"`},
+		{"invalid pattern", "leads", `name ~ "("`},
+		{"unterminated string", "leads", `name == "x`},
+		{"missing operand", "leads", `quality >=`},
+		{"trailing tokens", "leads", `quality > 1 2`},
+		{"locations", "locations", `city == "Tartu"`},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if _, err := NewFilter(c.endpoint, c.expr); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestFilterTypeMismatch(t *testing.T) {
+	f, err := NewFilter("leads", `quality >= "3"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.FilterLeads([]LeadData{{}}, nil); err == nil {
+		t.Error("expected an error comparing a number and a string")
+	}
+}
@@ -0,0 +1,262 @@
+/*
+Copyright © 2021 willbenica
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package internal
+
+import (
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+	"go.uber.org/zap"
+)
+
+// FormatXLSX writes all records to an Excel workbook with one sheet per resource
+const FormatXLSX = "xlsx"
+
+// Sheets of the workbook written by WriteXLSX
+const (
+	SheetLeads       = "Leads"
+	SheetLocations   = "Locations"
+	SheetVisits      = "Visits"
+	SheetVisitRoutes = "Visit Routes"
+)
+
+// maxSheetHyperlinks is the number of hyperlinks Excel allows in a worksheet
+const maxSheetHyperlinks = 65530
+
+// Kinds of xlsx cells, columns not listed in xlsxColumnKinds are text
+const (
+	cellText = iota
+	cellNumber
+	cellDate
+	cellTimestamp
+	cellLink
+)
+
+// xlsxColumnKinds types the columns of the tables written to the workbook
+var xlsxColumnKinds = map[string]int{
+	"quality":            cellNumber,
+	"visits":             cellNumber,
+	"employee_count":     cellNumber,
+	"hour":               cellNumber,
+	"page_depth":         cellNumber,
+	"visit_length":       cellNumber,
+	"route_steps":        cellNumber,
+	"step":               cellNumber,
+	"time_on_page":       cellNumber,
+	"first_visit_date":   cellDate,
+	"last_visit_date":    cellDate,
+	"date":               cellDate,
+	"started_at":         cellTimestamp,
+	"website_url":        cellLink,
+	"view_in_leadfeeder": cellLink,
+}
+
+// xlsxStyles holds the style IDs registered in a workbook
+type xlsxStyles struct {
+	header, date, timestamp, link int
+}
+
+// WriteXLSX writes the dataset to w as a workbook with the sheets Leads,
+// Locations, Visits and Visit Routes. Every sheet has a frozen header row
+// and an autofilter, numbers and dates are typed and URLs are hyperlinks.
+func WriteXLSX(w io.Writer, ds Dataset) error {
+	Init()
+	f := excelize.NewFile()
+	defer f.Close()
+
+	styles, err := newXLSXStyles(f)
+	if err != nil {
+		return err
+	}
+	if err := f.SetSheetName("Sheet1", SheetLeads); err != nil {
+		return err
+	}
+	sheets := []struct {
+		name  string
+		table Table
+	}{
+		{SheetLeads, LeadsTable(ds.Leads, ds.Locations)},
+		{SheetLocations, LocationsTable(ds.Locations)},
+		{SheetVisits, VisitsTable(ds.Visits, VisitRowsVisit)},
+		{SheetVisitRoutes, VisitRoutesTable(ds.Visits)},
+	}
+	for _, s := range sheets {
+		if s.name != SheetLeads {
+			if _, err := f.NewSheet(s.name); err != nil {
+				return err
+			}
+		}
+		if err := writeXLSXSheet(f, s.name, s.table, columnKinds(s.table.Columns), styles); err != nil {
+			return err
+		}
+	}
+	if _, err := f.WriteTo(w); err != nil {
+		return err
+	}
+	logger.Debug("Workbook written", zap.Int("leads", len(ds.Leads)), zap.Int("locations", len(ds.Locations)), zap.Int("visits", len(ds.Visits)))
+	return nil
+}
+
+// WriteXLSXRecords writes records selected with --fields to w as a workbook
+// with the single sheet given. Cells are typed by the last segment of their
+// path, so renamed fields keep their type.
+func WriteXLSXRecords(w io.Writer, sheet string, records []Record, p Projection) error {
+	Init()
+	f := excelize.NewFile()
+	defer f.Close()
+
+	styles, err := newXLSXStyles(f)
+	if err != nil {
+		return err
+	}
+	if err := f.SetSheetName("Sheet1", sheet); err != nil {
+		return err
+	}
+	segments := make([]string, len(p.Paths))
+	for i, path := range p.Paths {
+		segments[i] = path[strings.LastIndex(path, ".")+1:]
+	}
+	if err := writeXLSXSheet(f, sheet, RecordsTable(records, p), columnKinds(segments), styles); err != nil {
+		return err
+	}
+	_, err = f.WriteTo(w)
+	return err
+}
+
+// WriteXLSXFile writes the dataset as a workbook to filename under path
+func WriteXLSXFile(path string, filename string, ds Dataset) error {
+	return writeXLSXFile(path, filename, func(w io.Writer) error { return WriteXLSX(w, ds) })
+}
+
+// WriteXLSXRecordsFile writes the records as a workbook to filename under path
+func WriteXLSXRecordsFile(path string, filename string, sheet string, records []Record, p Projection) error {
+	return writeXLSXFile(path, filename, func(w io.Writer) error { return WriteXLSXRecords(w, sheet, records, p) })
+}
+
+func writeXLSXFile(path string, filename string, write func(io.Writer) error) error {
+	file, err := CreateFile(path, filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if err := write(file); err != nil {
+		return err
+	}
+	return file.Sync()
+}
+
+// columnKinds looks up the kind of each column in xlsxColumnKinds
+func columnKinds(columns []string) []int {
+	kinds := make([]int, len(columns))
+	for i, c := range columns {
+		kinds[i] = xlsxColumnKinds[c]
+	}
+	return kinds
+}
+
+func newXLSXStyles(f *excelize.File) (xlsxStyles, error) {
+	var s xlsxStyles
+	var err error
+	if s.header, err = f.NewStyle(&excelize.Style{Font: &excelize.Font{Bold: true}}); err != nil {
+		return s, err
+	}
+	dateFormat, timestampFormat := "yyyy-mm-dd", "yyyy-mm-dd hh:mm:ss"
+	if s.date, err = f.NewStyle(&excelize.Style{CustomNumFmt: &dateFormat}); err != nil {
+		return s, err
+	}
+	if s.timestamp, err = f.NewStyle(&excelize.Style{CustomNumFmt: &timestampFormat}); err != nil {
+		return s, err
+	}
+	s.link, err = f.NewStyle(&excelize.Style{Font: &excelize.Font{Color: "0563C1", Underline: "single"}})
+	return s, err
+}
+
+// writeXLSXSheet writes the table to sheet, converting the cells of each
+// column according to its kind
+func writeXLSXSheet(f *excelize.File, sheet string, t Table, kinds []int, styles xlsxStyles) error {
+	header := make([]interface{}, len(t.Columns))
+	for i, c := range t.Columns {
+		header[i] = c
+	}
+	if err := f.SetSheetRow(sheet, "A1", &header); err != nil {
+		return err
+	}
+	lastColumn, err := excelize.ColumnNumberToName(len(t.Columns))
+	if err != nil {
+		return err
+	}
+	if err := f.SetCellStyle(sheet, "A1", lastColumn+"1", styles.header); err != nil {
+		return err
+	}
+
+	links := 0
+	for r, row := range t.Rows {
+		for c, value := range row {
+			if value == "" {
+				continue
+			}
+			cell, err := excelize.CoordinatesToCellName(c+1, r+2)
+			if err != nil {
+				return err
+			}
+			var v interface{} = value
+			style := 0
+			switch kinds[c] {
+			case cellNumber:
+				if n, err := strconv.Atoi(value); err == nil {
+					v = n
+				}
+			case cellDate:
+				if d, err := time.Parse("2006-01-02", value); err == nil {
+					v, style = d, styles.date
+				}
+			case cellTimestamp:
+				if ts, err := time.Parse(time.RFC3339, value); err == nil {
+					v, style = ts.UTC(), styles.timestamp
+				}
+			case cellLink:
+				if links < maxSheetHyperlinks {
+					if err := f.SetCellHyperLink(sheet, cell, value, "External"); err != nil {
+						return err
+					}
+					links++
+					style = styles.link
+				}
+			}
+			if err := f.SetCellValue(sheet, cell, v); err != nil {
+				return err
+			}
+			if style != 0 {
+				if err := f.SetCellStyle(sheet, cell, cell, style); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	if links == maxSheetHyperlinks {
+		logger.Warn("Excel allows only a limited number of hyperlinks per sheet, the remaining URLs are plain text", zap.String("sheet", sheet), zap.Int("hyperlinks", links))
+	}
+
+	if err := f.SetPanes(sheet, &excelize.Panes{Freeze: true, YSplit: 1, TopLeftCell: "A2", ActivePane: "bottomLeft"}); err != nil {
+		return err
+	}
+	return f.AutoFilter(sheet, "A1:"+lastColumn+strconv.Itoa(len(t.Rows)+1), nil)
+}
@@ -0,0 +1,148 @@
+/*
+Copyright © 2021 willbenica
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package internal
+
+import (
+	"bytes"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/xuri/excelize/v2"
+)
+
+func TestWriteXLSX(t *testing.T) {
+	ds, err := ReadDataFiles(TEST_FOLDER+L1, TEST_FOLDER+V1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := WriteXLSX(&buf, ds); err != nil {
+		t.Fatalf("writing the workbook failed: %q", err)
+	}
+
+	f, err := excelize.OpenReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	expectedSheets := []string{SheetLeads, SheetLocations, SheetVisits, SheetVisitRoutes}
+	if sheets := f.GetSheetList(); !reflect.DeepEqual(sheets, expectedSheets) {
+		t.Fatalf("expected sheets %v, got %v", expectedSheets, sheets)
+	}
+
+	leads := LeadsTable(ds.Leads, ds.Locations)
+	rows, err := f.GetRows(SheetLeads)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != len(ds.Leads)+1 {
+		t.Fatalf("expected %d rows in %s, got %d", len(ds.Leads)+1, SheetLeads, len(rows))
+	}
+
+	cell := func(column string, row int) string {
+		for i, c := range leads.Columns {
+			if c == column {
+				name, _ := excelize.CoordinatesToCellName(i+1, row)
+				return name
+			}
+		}
+		t.Fatalf("unknown column %s", column)
+		return ""
+	}
+
+	typ, _ := f.GetCellType(SheetLeads, cell("quality", 2))
+	quality, _ := f.GetCellValue(SheetLeads, cell("quality", 2), excelize.Options{RawCellValue: true})
+	if typ == excelize.CellTypeUnset && quality != "" {
+		// excelize leaves out t="n", the default type of a cell with a value
+		typ = excelize.CellTypeNumber
+	}
+	if typ != excelize.CellTypeNumber {
+		t.Errorf("expected quality to be a number, got cell type %v", typ)
+	}
+	if expected := strconv.Itoa(ds.Leads[0].Attributes.Quality); quality != expected {
+		t.Errorf("expected quality %s, got %q", expected, quality)
+	}
+	raw, _ := f.GetCellValue(SheetLeads, cell("first_visit_date", 2), excelize.Options{RawCellValue: true})
+	if raw == ds.Leads[0].Attributes.FirstVisitDate {
+		t.Errorf("expected first_visit_date to be stored as a date serial, got %q", raw)
+	}
+	if formatted, _ := f.GetCellValue(SheetLeads, cell("first_visit_date", 2)); formatted != ds.Leads[0].Attributes.FirstVisitDate {
+		t.Errorf("expected first_visit_date to be shown as %s, got %s", ds.Leads[0].Attributes.FirstVisitDate, formatted)
+	}
+	if url := ds.Leads[0].Attributes.ViewInLeadfeeder; url != "" {
+		if ok, link, _ := f.GetCellHyperLink(SheetLeads, cell("view_in_leadfeeder", 2)); !ok || link != url {
+			t.Errorf("expected a hyperlink to %s, got %q", url, link)
+		}
+	}
+
+	routes, err := f.GetRows(SheetVisitRoutes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := len(VisitRoutesTable(ds.Visits).Rows) + 1; len(routes) != expected {
+		t.Errorf("expected %d rows in %s, got %d", expected, SheetVisitRoutes, len(routes))
+	}
+}
+
+func TestWriteXLSXRecords(t *testing.T) {
+	ds, err := ReadDataFiles(TEST_FOLDER+L1, TEST_FOLDER+V1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := NewProjection("leads", []string{"id", "attributes.quality", "attributes.first_visit_date", "location.city"},
+		map[string]string{"attributes.quality": "q", "attributes.first_visit_date": "first_seen"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	records, err := ProjectLeads(ds.Leads, ds.Locations, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := WriteXLSXRecords(&buf, SheetLeads, records, p); err != nil {
+		t.Fatalf("writing the workbook failed: %q", err)
+	}
+	f, err := excelize.OpenReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	if sheets := f.GetSheetList(); !reflect.DeepEqual(sheets, []string{SheetLeads}) {
+		t.Errorf("expected only the sheet %s, got %v", SheetLeads, sheets)
+	}
+	rows, err := f.GetRows(SheetLeads)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"id", "q", "first_seen", "location.city"}; !reflect.DeepEqual(rows[0], expected) {
+		t.Errorf("expected the header %v, got %v", expected, rows[0])
+	}
+	if len(rows) != len(ds.Leads)+1 {
+		t.Errorf("expected %d rows, got %d", len(ds.Leads)+1, len(rows))
+	}
+	// Renamed fields are typed by their path
+	if raw, _ := f.GetCellValue(SheetLeads, "C2", excelize.Options{RawCellValue: true}); raw == ds.Leads[0].Attributes.FirstVisitDate {
+		t.Errorf("expected first_seen to be stored as a date serial, got %q", raw)
+	}
+	if formatted, _ := f.GetCellValue(SheetLeads, "C2"); formatted != ds.Leads[0].Attributes.FirstVisitDate {
+		t.Errorf("expected first_seen to be shown as %s, got %s", ds.Leads[0].Attributes.FirstVisitDate, formatted)
+	}
+}